@@ -2,14 +2,19 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"mime"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -18,18 +23,36 @@ import (
 	browserclient "github.com/alcounit/browser-service/pkg/client/browser"
 	browserconfigclient "github.com/alcounit/browser-service/pkg/client/browserconfig"
 	"github.com/alcounit/browser-service/pkg/event"
+	"github.com/alcounit/browser-ui/pkg/audit"
+	"github.com/alcounit/browser-ui/pkg/catalog"
+	"github.com/alcounit/browser-ui/pkg/certs"
+	"github.com/alcounit/browser-ui/pkg/cluster"
 	"github.com/alcounit/browser-ui/pkg/collector"
+	"github.com/alcounit/browser-ui/pkg/config"
+	"github.com/alcounit/browser-ui/pkg/csrf"
+	"github.com/alcounit/browser-ui/pkg/health"
+	"github.com/alcounit/browser-ui/pkg/history"
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	"github.com/alcounit/browser-ui/pkg/proxyauth"
+	"github.com/alcounit/browser-ui/pkg/ratelimit"
+	"github.com/alcounit/browser-ui/pkg/share"
+	"github.com/alcounit/browser-ui/pkg/tokens"
 	"github.com/alcounit/browser-ui/pkg/types"
+	"github.com/alcounit/browser-ui/pkg/vncconn"
+	"github.com/alcounit/browser-ui/pkg/vncpass"
+	"github.com/alcounit/browser-ui/pkg/webhook"
 	"github.com/alcounit/browser-ui/service"
 	"github.com/alcounit/seleniferous/v2/pkg/store"
 	"github.com/alcounit/selenosis/v2/pkg/auth"
-	"github.com/alcounit/selenosis/v2/pkg/env"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 
 	logctx "github.com/alcounit/browser-controller/pkg/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func init() {
@@ -38,9 +61,49 @@ func init() {
 	mime.AddExtensionType(".css", "text/css")
 }
 
-func cookieAuthMiddleware(authStore *auth.AuthStore, log zerolog.Logger) func(http.Handler) http.Handler {
+// authMiddleware accepts an API token as "Authorization: Bearer" or the
+// credentials in the auth cookie. Cookie failures count towards the per-IP
+// login lockout, so the cookie cannot be used to guess passwords past it;
+// they do not count against the user the cookie names.
+func authMiddleware(authStore *auth.AuthStore, proxyAuth *proxyauth.Authenticator, tokenManager *tokens.Manager, loginGuard *ratelimit.LoginGuard, clientIP *ratelimit.ClientIP, log zerolog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if scheme, value, ok := strings.Cut(req.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+				token, err := tokenManager.Authenticate(req.Context(), strings.TrimSpace(value))
+				if err != nil {
+					if !errors.Is(err, tokens.ErrInvalid) {
+						log.Error().Err(err).Msg("token lookup failed")
+					}
+					log.Warn().Str("sourceIP", ratelimit.RequestIP(req)).Msg("request with invalid token rejected")
+					http.Error(rw, "invalid or expired token", http.StatusUnauthorized)
+					return
+				}
+				ctx := tokens.WithToken(req.Context(), token)
+				ctx = auth.WithOwner(ctx, auth.Owner{Name: token.Owner})
+				next.ServeHTTP(rw, req.WithContext(ctx))
+				return
+			}
+
+			if proxyAuth != nil {
+				user, groups, err := proxyAuth.Authenticate(req)
+				switch {
+				case err == nil:
+					ctx := auth.WithOwner(req.Context(), auth.Owner{Name: user})
+					next.ServeHTTP(rw, req.WithContext(proxyauth.WithGroups(ctx, groups)))
+					return
+				case errors.Is(err, proxyauth.ErrGroup):
+					log.Warn().Str("user", user).Strs("groups", groups).Msg("proxy user is not in an allowed group")
+					http.Error(rw, "user is not allowed", http.StatusForbidden)
+					return
+				case errors.Is(err, proxyauth.ErrUntrusted) && req.Header.Get(proxyAuth.UserHeader()) != "":
+					log.Warn().Str("sourceIP", ratelimit.RequestIP(req)).Msg("proxy user header from untrusted address ignored")
+				}
+				if authStore == nil {
+					http.Error(rw, "authentication required", http.StatusUnauthorized)
+					return
+				}
+			}
+
 			cookie, err := req.Cookie("browser_ui_auth")
 			if err != nil {
 				http.Error(rw, "authentication required", http.StatusUnauthorized)
@@ -52,17 +115,160 @@ func cookieAuthMiddleware(authStore *auth.AuthStore, log zerolog.Logger) func(ht
 				return
 			}
 			parts := strings.SplitN(string(decoded), ":", 2)
-			if len(parts) != 2 || !authStore.Authenticate(parts[0], parts[1]) {
-				log.Error().Msg("request authentication failed")
+			if len(parts) != 2 {
+				http.Error(rw, "authentication required", http.StatusUnauthorized)
+				return
+			}
+			// Only the client IP is locked out here: locking the user would
+			// turn a stranger's forged cookies into 429s for their valid
+			// session.
+			ip := clientIP.IP(req)
+			if wait := loginGuard.CheckIP(ip); wait > 0 {
+				log.Warn().Str("sourceIP", ip).Dur("retryAfter", wait).Msg("request rejected, locked out")
+				metrics.LoginFailures.WithLabelValues("locked_out").Inc()
+				rw.Header().Set("Retry-After", ratelimit.RetryAfter(wait))
+				http.Error(rw, "too many failed login attempts, try again later", http.StatusTooManyRequests)
+				return
+			}
+			if !authStore.Authenticate(parts[0], parts[1]) {
+				loginGuard.FailedIP(ip)
+				log.Error().Str("sourceIP", ip).Msg("request authentication failed")
+				metrics.LoginFailures.WithLabelValues("invalid_credentials").Inc()
 				http.Error(rw, "authentication failed", http.StatusUnauthorized)
 				return
 			}
-			req = req.WithContext(auth.WithOwner(req.Context(), auth.Owner{Name: parts[0]}))
-			next.ServeHTTP(rw, req)
+			ctx := auth.WithOwner(req.Context(), auth.Owner{Name: parts[0]})
+			next.ServeHTTP(rw, req.WithContext(tokens.WithCredential(ctx, parts[1])))
 		})
 	}
 }
 
+// tokenScope is the scope an API token needs for req.
+func tokenScope(req *http.Request) tokens.Scope {
+	switch {
+	case strings.HasSuffix(strings.TrimSuffix(req.URL.Path, "/"), "/vnc"), strings.Contains(req.URL.Path, "/share"):
+		return tokens.ScopeVNC
+	case req.Method == http.MethodPost:
+		return tokens.ScopeCreate
+	case req.Method == http.MethodDelete:
+		return tokens.ScopeDelete
+	default:
+		return tokens.ScopeRead
+	}
+}
+
+// adminCheck accepts requests from users in admins or, behind an
+// authenticating proxy, in one of groups.
+func adminCheck(admins, groups []string) func(*http.Request) bool {
+	return func(req *http.Request) bool {
+		owner, ok := auth.OwnerFrom(req.Context())
+		if !ok {
+			return false
+		}
+		if slices.Contains(admins, owner.Name) {
+			return true
+		}
+		return slices.ContainsFunc(proxyauth.GroupsFrom(req.Context()), func(g string) bool {
+			return slices.Contains(groups, g)
+		})
+	}
+}
+
+// cookieUsername returns the user name stored in the auth cookie without
+// checking the password; it is only used to attribute audit events.
+func cookieUsername(req *http.Request) string {
+	cookie, err := req.Cookie("browser_ui_auth")
+	if err != nil {
+		return ""
+	}
+	decoded, err := base64.StdEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return ""
+	}
+	username, _, _ := strings.Cut(string(decoded), ":")
+	return username
+}
+
+// newAuditTrail builds the audit sinks listed in cfg.
+func newAuditTrail(cfg config.AuditConfig, log zerolog.Logger) (*audit.Trail, error) {
+	var result []audit.Sink
+	for _, name := range cfg.Sinks {
+		switch name {
+		case "stdout":
+			result = append(result, audit.NewWriterSink(name, os.Stdout))
+		case "stderr":
+			result = append(result, audit.NewWriterSink(name, os.Stderr))
+		case "file":
+			sink, err := audit.NewFileSink(cfg.File.Path, int64(cfg.File.MaxSizeMB)<<20, cfg.File.MaxBackups)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, sink)
+		case "webhook":
+			result = append(result, audit.NewWebhookSink(cfg.Webhook.URL, time.Duration(cfg.Webhook.Timeout), 1000, log))
+		default:
+			return nil, fmt.Errorf("unknown audit sink %q", name)
+		}
+	}
+	return audit.NewTrail(result...), nil
+}
+
+// newTLSConfig serves the configured certificate, reloading it while ctx is
+// alive, and verifies client certificates when a client CA is configured.
+func newTLSConfig(ctx context.Context, cfg config.TLSConfig) (*tls.Config, error) {
+	reloader, err := certs.NewReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := reloader.Watch(ctx); err != nil {
+			log := logctx.FromContext(ctx)
+			log.Error().Err(err).Str("certFile", cfg.CertFile).Msg("certificate watcher stopped")
+		}
+	}()
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if cfg.ClientCAFile != "" {
+		pool, err := certs.LoadCertPool(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if cfg.ClientAuth == "optional" {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return tlsConfig, nil
+}
+
+// pruneHistory drops history records older than retention once an hour.
+// retention is read on every run so config reloads apply to the next one.
+func pruneHistory(ctx context.Context, historyStore history.Store, retention func() time.Duration) {
+	log := logctx.FromContext(ctx)
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		removed, err := historyStore.DeleteBefore(ctx, time.Now().Add(-retention()))
+		if err != nil {
+			log.Error().Err(err).Msg("failed to prune session history")
+		} else if removed > 0 {
+			log.Info().Int("removed", removed).Msg("pruned session history")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func main() {
 	zerolog.TimeFieldFormat = time.RFC3339
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
@@ -71,14 +277,23 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	addr := env.GetEnvOrDefault("LISTEN_ADDR", ":8080")
-	apiURL := env.GetEnvOrDefault("BROWSER_SERVICE_URL", "http://browser-service:8080")
-	browserStartTimeout := env.GetEnvDurationOrDefault("BROWSER_STARTUP_TIMEOUT", 3*time.Minute)
-	namespace := env.GetEnvOrDefault("BROWSER_NAMESPACE", "default")
-	staticPath := env.GetEnvOrDefault("UI_STATIC_PATH", "/app/static")
+	configPath, _ := os.LookupEnv("CONFIG_FILE")
+	cfg, err := config.Load(configPath, os.LookupEnv)
+	if err != nil {
+		log.Fatal().Err(err).Str("path", configPath).Msg("invalid configuration")
+	}
+	level, _ := zerolog.ParseLevel(cfg.LogLevel)
+	zerolog.SetGlobalLevel(level)
+
+	addr := cfg.ListenAddr
+	namespace := cfg.Namespace
+	staticPath := cfg.StaticPath
+
+	var historyRetention atomic.Int64
+	historyRetention.Store(int64(cfg.History.Retention))
 
 	var authStore *auth.AuthStore
-	if authFilePath := env.GetEnvOrDefault("BASIC_AUTH_FILE", ""); authFilePath != "" {
+	if authFilePath := cfg.Auth.BasicAuthFile; authFilePath != "" {
 		var err error
 		if authStore, err = auth.LoadFromJSONFile(authFilePath); err != nil {
 			log.Fatal().Err(err).Str("path", authFilePath).Msg("BASIC_AUTH_FILE load error")
@@ -87,42 +302,301 @@ func main() {
 		log.Info().Str("path", authFilePath).Msg("basic auth enabled")
 	}
 
+	var proxyAuth *proxyauth.Authenticator
+	if proxyCfg := cfg.Auth.Proxy; proxyCfg.Enabled() {
+		var err error
+		proxyAuth, err = proxyauth.New(proxyCfg.TrustedProxies,
+			proxyauth.WithUserHeader(proxyCfg.UserHeader),
+			proxyauth.WithGroupsHeader(proxyCfg.GroupsHeader),
+			proxyauth.WithAllowedGroups(proxyCfg.AllowedGroups...))
+		if err != nil {
+			log.Fatal().Err(err).Msg("AUTH_PROXY_TRUSTED_CIDRS error")
+		}
+		log.Info().Strs("trustedProxies", proxyCfg.TrustedProxies).Str("userHeader", proxyCfg.UserHeader).Msg("proxy auth enabled")
+	}
+	authEnabled := authStore != nil || proxyAuth != nil
+
+	var tokenManager *tokens.Manager
+	if authEnabled {
+		var tokenStore tokens.Store = tokens.NewMemoryStore()
+		if tokensPath := cfg.Tokens.DBPath; tokensPath != "" {
+			boltStore, err := tokens.OpenBoltStore(tokensPath)
+			if err != nil {
+				log.Fatal().Err(err).Str("path", tokensPath).Msg("TOKENS_DB_PATH open error")
+			}
+			defer boltStore.Close()
+			tokenStore = boltStore
+		} else {
+			log.Warn().Msg("TOKENS_DB_PATH is not set, API tokens are lost on restart")
+		}
+		// Tokens of basic auth users stop working once the user is removed
+		// or their password changes. Proxy users cannot be looked up, so
+		// their tokens last while proxy auth is enabled.
+		tokenManager = tokens.NewManager(tokenStore, tokens.WithOwnerCheck(func(owner, credential string) bool {
+			if credential == "" {
+				return proxyAuth != nil
+			}
+			return authStore != nil && authStore.Authenticate(owner, credential)
+		}))
+	}
+
 	sessionStore := store.NewDefaultStore[*types.Session]()
 	browserStore := store.NewDefaultStore[types.BrowserVersions]()
+	passwordStore := store.NewDefaultStore[types.VNCPasswords]()
+	catalogStore := store.NewDefaultStore[types.BrowserConfigCatalog]()
+
+	prometheus.MustRegister(metrics.NewSessionCollector(sessionStore))
+
+	var historyStore history.Store
+	var recorders []history.Recorder
+	var serviceOpts []service.Option
+	if historyPath := cfg.History.DBPath; historyPath != "" {
+		boltStore, err := history.OpenBoltStore(historyPath)
+		if err != nil {
+			log.Fatal().Err(err).Str("path", historyPath).Msg("HISTORY_DB_PATH open error")
+		}
+		defer boltStore.Close()
+		historyStore = boltStore
+
+		recorders = append(recorders, historyStore)
 
-	clientConfig := client.ClientConfig{
-		BaseURL:    apiURL,
-		HTTPClient: http.DefaultClient,
-		Logger:     log,
+		retention := func() time.Duration { return time.Duration(historyRetention.Load()) }
+		go pruneHistory(logctx.IntoContext(ctx, log), historyStore, retention)
+		log.Info().Str("path", historyPath).Dur("retention", retention()).Msg("session history enabled")
 	}
 
-	browserClient, err := browserclient.NewClient(clientConfig)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to create Browser client")
+	var dispatcher *webhook.Dispatcher
+	if webhooksPath := cfg.Webhooks.File; webhooksPath != "" || len(cfg.Webhooks.Hooks) > 0 {
+		hooks := cfg.Webhooks.Hooks
+		if webhooksPath != "" {
+			fileHooks, err := webhook.LoadHooks(webhooksPath)
+			if err != nil {
+				log.Fatal().Err(err).Str("path", webhooksPath).Msg("WEBHOOKS_FILE load error")
+			}
+			hooks = append(slices.Clone(hooks), fileHooks...)
+		}
+
+		retry := webhook.Retry{
+			MaxAttempts: cfg.Webhooks.MaxAttempts,
+			Initial:     time.Duration(cfg.Webhooks.RetryInitial),
+			Max:         time.Duration(cfg.Webhooks.RetryMax),
+		}
+		webhookOpts := []webhook.Option{webhook.WithRetry(retry), webhook.WithLogger(log)}
+
+		if deadLetterPath := cfg.Webhooks.DeadLetterFile; deadLetterPath != "" {
+			deadLetter, err := os.OpenFile(deadLetterPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+			if err != nil {
+				log.Fatal().Err(err).Str("path", deadLetterPath).Msg("WEBHOOKS_DEAD_LETTER_FILE open error")
+			}
+			defer deadLetter.Close()
+			webhookOpts = append(webhookOpts, webhook.WithDeadLetter(deadLetter))
+		}
+
+		dispatcher, err = webhook.NewDispatcher(hooks, webhookOpts...)
+		if err != nil {
+			log.Fatal().Err(err).Str("path", webhooksPath).Msg("webhooks config error")
+		}
+		defer dispatcher.Close()
+
+		recorders = append(recorders, dispatcher)
+		log.Info().Str("path", webhooksPath).Int("webhooks", len(hooks)).Msg("webhooks enabled")
 	}
 
-	browserConfigClient, err := browserconfigclient.NewClient(clientConfig)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to create BrowserConfig client")
+	collectorOpts := []collector.CollectorOption{collector.WithPasswordStore(passwordStore), collector.WithCatalogStore(catalogStore)}
+	createNamespaces := cfg.CreateNamespaces
+	if watchNamespaces := cfg.WatchNamespaces; len(watchNamespaces) > 0 {
+		if slices.Contains(watchNamespaces, "*") {
+			watchNamespaces = []string{metav1.NamespaceAll}
+		} else if createNamespaces == nil {
+			createNamespaces = watchNamespaces
+		}
+		collectorOpts = append(collectorOpts, collector.WithNamespaces(watchNamespaces...))
+		log.Info().Strs("namespaces", watchNamespaces).Msg("watching browser namespaces")
+	}
+	if len(createNamespaces) > 0 {
+		serviceOpts = append(serviceOpts, service.WithNamespaces(createNamespaces...))
 	}
 
-	broadcaster := broadcast.NewBroadcaster[event.BrowserEvent](10)
-	col := collector.NewCollector(browserClient, browserConfigClient, namespace, sessionStore, browserStore, broadcaster)
+	if len(recorders) > 0 {
+		recorder := history.Multi(recorders...)
+		collectorOpts = append(collectorOpts, collector.WithRecorder(recorder))
+		serviceOpts = append(serviceOpts, service.WithRecorder(recorder))
+	}
+
+	auditor := audit.Discard
+	if auditSinks := cfg.Audit.Sinks; len(auditSinks) > 0 {
+		trail, err := newAuditTrail(cfg.Audit, log)
+		if err != nil {
+			log.Fatal().Err(err).Strs("sinks", auditSinks).Msg("audit trail setup error")
+		}
+		defer trail.Close()
+		auditor = trail
+
+		serviceOpts = append(serviceOpts, service.WithAuditor(trail))
+		log.Info().Strs("sinks", auditSinks).Msg("audit trail enabled")
+	}
 
-	collectorCtx := logctx.IntoContext(ctx, log)
+	vncPasswords, err := vncpass.New(
+		vncpass.WithFile(cfg.VNC.PasswordsFile),
+		vncpass.WithPasswords(cfg.VNC.Passwords),
+		vncpass.WithAnnotations(passwordStore))
+	if err != nil {
+		log.Fatal().Err(err).Str("path", cfg.VNC.PasswordsFile).Msg("vnc passwords file error")
+	}
 	go func() {
-		for {
-			err := col.Run(collectorCtx)
-			if err == nil || collectorCtx.Err() != nil {
-				return
-			}
-			log.Error().Err(err).Msgf("event collector failed, retrying in 5s")
-			time.Sleep(5 * time.Second)
+		if err := vncPasswords.Watch(logctx.IntoContext(ctx, log)); err != nil {
+			log.Error().Err(err).Str("path", cfg.VNC.PasswordsFile).Msg("vnc password watcher stopped")
 		}
 	}()
-	log.Info().Msgf("event collector started, connected to %s", apiURL)
+	serviceOpts = append(serviceOpts,
+		service.WithVNCPasswords(vncPasswords.Lookup),
+		service.WithVNCProxy(service.VNCProxyConfig{
+			WriteTimeout:   time.Duration(cfg.VNC.WriteTimeout),
+			PingInterval:   time.Duration(cfg.VNC.PingInterval),
+			PongTimeout:    time.Duration(cfg.VNC.PongTimeout),
+			QueueSize:      cfg.VNC.QueueSize,
+			MaxMessageSize: int64(cfg.VNC.MaxMessageSizeMB) << 20,
+			Compression:    cfg.VNC.Compression,
+		}))
+
+	broadcaster := broadcast.NewBroadcaster[event.BrowserEvent](10)
+
+	backoff := collector.DefaultBackoff
+	backoff.Initial = time.Duration(cfg.Collector.BackoffInitial)
+	backoff.Max = time.Duration(cfg.Collector.BackoffMax)
+	backoff.ResetAfter = time.Duration(cfg.Collector.BackoffReset)
+
+	var backends []*cluster.Backend
+	var collectors []*collector.Collector
+	var readyChecks []health.Check
+	for _, endpoint := range cfg.Clusters {
+		clusterLog := log.With().Str("cluster", endpoint.Name).Logger()
+		clientConfig := client.ClientConfig{
+			BaseURL:    endpoint.URL,
+			HTTPClient: http.DefaultClient,
+			Logger:     clusterLog,
+		}
+
+		browserClient, err := browserclient.NewClient(clientConfig)
+		if err != nil {
+			clusterLog.Fatal().Err(err).Msg("Failed to create Browser client")
+		}
+
+		browserConfigClient, err := browserconfigclient.NewClient(clientConfig)
+		if err != nil {
+			clusterLog.Fatal().Err(err).Msg("Failed to create BrowserConfig client")
+		}
+
+		opts := append(slices.Clone(collectorOpts), collector.WithCluster(endpoint.Name))
+		col := collector.NewCollector(browserClient, browserConfigClient, namespace, sessionStore, browserStore, broadcaster, opts...)
+		supervisor := collector.NewSupervisor(col.Run, backoff)
+
+		go supervisor.Run(logctx.IntoContext(ctx, clusterLog)) //nolint:errcheck
+		clusterLog.Info().Msgf("event collector started, connected to %s", endpoint.URL)
+
+		checks := []health.Check{
+			{Name: "collector", Checker: col},
+			{Name: "collector-supervisor", Checker: supervisor},
+			{Name: "browser-service", Checker: health.HTTPChecker(http.DefaultClient, endpoint.URL, 2*time.Second)},
+		}
+		backend := &cluster.Backend{
+			Name:     endpoint.Name,
+			URL:      endpoint.URL,
+			Browsers: browserClient,
+			Configs:  browserConfigClient,
+			Health:   health.All(checks...),
+		}
+		// Only the default cluster decides readiness: taking the replica out
+		// of the load balancer would not bring another cluster back, and
+		// would cut users off from those still up. Their health is on
+		// /api/v1/clusters and in the cluster_ready metric.
+		if len(backends) == 0 {
+			readyChecks = append(readyChecks, checks...)
+		}
+
+		backends = append(backends, backend)
+		collectors = append(collectors, col)
+	}
+
+	clusters, err := cluster.NewRegistry(backends...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("cluster registry error")
+	}
+	serviceOpts = append(serviceOpts, service.WithClusters(clusters))
+	go clusters.Monitor(ctx, 30*time.Second)
+
+	origins := csrf.NewOrigins(cfg.Server.AllowedOrigins...)
+	serviceOpts = append(serviceOpts, service.WithOriginCheck(origins.Allowed))
+
+	vncConns := vncconn.NewRegistry()
+	serviceOpts = append(serviceOpts, service.WithVNCConnections(vncConns))
+	serviceOpts = append(serviceOpts, service.WithCatalog(catalogStore))
+	if authEnabled {
+		serviceOpts = append(serviceOpts, service.WithAdminCheck(adminCheck(cfg.Auth.Admins, cfg.Auth.AdminGroups)))
+	}
+
+	svc := service.NewService(clusters.Default().Browsers, namespace, sessionStore, browserStore, time.Duration(cfg.BrowserStartupTimeout), serviceOpts...)
+
+	if configPath != "" {
+		go func() {
+			// Restart warnings compare against the previous reload, so each
+			// change is reported once.
+			applied := cfg
+			err := config.Watch(logctx.IntoContext(ctx, log), configPath, os.LookupEnv, func(next *config.Config) {
+				if level, err := zerolog.ParseLevel(next.LogLevel); err == nil {
+					zerolog.SetGlobalLevel(level)
+				}
+				svc.SetBrowserStartTimeout(time.Duration(next.BrowserStartupTimeout))
+				historyRetention.Store(int64(next.History.Retention))
+
+				if fields := applied.RestartRequired(next); len(fields) > 0 {
+					log.Warn().Strs("fields", fields).Msg("config changes need a restart to take effect")
+				}
+				applied = next
+			})
+			if err != nil {
+				log.Error().Err(err).Str("path", configPath).Msg("config watcher stopped")
+			}
+		}()
+	}
+
+	clientIP, err := ratelimit.NewClientIP(cfg.ClientIPProxies())
+	if err != nil {
+		log.Fatal().Err(err).Msg("RATE_LIMIT_TRUSTED_PROXIES error")
+	}
+	login := cfg.RateLimit.Login
+	loginGuard := ratelimit.NewLoginGuard(
+		ratelimit.NewLockout(login.MaxFailuresPerIP, time.Duration(login.Lockout), time.Duration(login.MaxLockout)),
+		ratelimit.NewLockout(login.MaxFailuresPerUser, time.Duration(login.Lockout), time.Duration(login.MaxLockout)),
+	)
+
+	// Creating and deleting browsers starts and stops pods, so each user (or
+	// client IP without auth) gets a budget of such requests.
+	browsersLimit := func(next http.Handler) http.Handler { return next }
+	if limit := cfg.RateLimit.Browsers; limit.Requests > 0 {
+		browsersLimit = ratelimit.NewLimiter(limit.Requests, time.Duration(limit.Window)).Middleware("browsers", func(req *http.Request) string {
+			if owner, ok := auth.OwnerFrom(req.Context()); ok && owner.Name != "" {
+				return "user:" + owner.Name
+			}
+			return "ip:" + clientIP.IP(req)
+		})
+	}
+
+	shareOpts := []share.HandlerOption{
+		share.WithTTL(time.Duration(cfg.Share.DefaultTTL), time.Duration(cfg.Share.MaxTTL)),
+		share.WithSessionLookup(svc.LookupSession),
+		share.WithAuditor(auditor),
+	}
+	if authEnabled {
+		shareOpts = append(shareOpts, share.WithAdminCheck(adminCheck(cfg.Auth.Admins, cfg.Auth.AdminGroups)))
+	}
+	shareHandler := share.NewHandler(share.NewRegistry(), shareOpts...)
 
-	svc := service.NewService(browserClient, namespace, sessionStore, browserStore, browserStartTimeout)
+	vncConnOpts := []vncconn.HandlerOption{vncconn.WithAuditor(auditor)}
+	if authEnabled {
+		vncConnOpts = append(vncConnOpts, vncconn.WithAdminCheck(adminCheck(cfg.Auth.Admins, cfg.Auth.AdminGroups)))
+	}
+	vncConnHandler := vncconn.NewHandler(vncConns, vncConnOpts...)
 
 	router := chi.NewRouter()
 	router.Use(middleware.Recoverer)
@@ -140,15 +614,36 @@ func main() {
 		}
 		return http.HandlerFunc(fn)
 	})
+	// Audit events and logs attribute requests to the client behind trusted
+	// proxies, the same address lockouts and rate limits use.
+	router.Use(clientIP.Middleware)
 
 	if _, err := os.Stat(staticPath); err != nil {
 		log.Fatal().Err(err).Msg("static directory missing")
 	}
 
 	router.Route("/api/v1", func(r chi.Router) {
+		r.Use(csrf.New(csrf.WithOrigins(origins), csrf.WithSecureCookie(cfg.Server.TLS.Enabled())).Middleware)
+
 		r.Get("/auth/config", func(w http.ResponseWriter, _ *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(map[string]bool{"authEnabled": authStore != nil}); err != nil {
+			response := struct {
+				AuthEnabled bool `json:"authEnabled"`
+				// LoginMode is "proxy" when a reverse proxy logs users in and
+				// "form" for the built-in login page.
+				LoginMode string `json:"loginMode,omitempty"`
+				LogoutURL string `json:"logoutUrl,omitempty"`
+			}{
+				AuthEnabled: authEnabled,
+			}
+			switch {
+			case proxyAuth != nil:
+				response.LoginMode = "proxy"
+				response.LogoutURL = cfg.Auth.Proxy.LogoutURL
+			case authStore != nil:
+				response.LoginMode = "form"
+			}
+			if err := json.NewEncoder(w).Encode(&response); err != nil {
 				http.Error(w, "failed to encode response", http.StatusInternalServerError)
 			}
 		})
@@ -167,49 +662,122 @@ func main() {
 				http.Error(w, "invalid request", http.StatusBadRequest)
 				return
 			}
-			if authStore != nil && !authStore.Authenticate(creds.Username, creds.Password) {
-				log.Error().Str("username", creds.Username).Msg("login failed")
-				http.Error(w, "invalid credentials", http.StatusUnauthorized)
-				return
-			}
+			ip := clientIP.IP(req)
 			if authStore != nil {
+				// Unknown users are counted and locked out like existing ones,
+				// so neither answer reveals whether a user exists.
+				if wait := loginGuard.Check(ip, creds.Username); wait > 0 {
+					log.Warn().Str("username", creds.Username).Str("sourceIP", ip).Dur("retryAfter", wait).Msg("login rejected, locked out")
+					metrics.LoginFailures.WithLabelValues("locked_out").Inc()
+					auditor.Audit(req.Context(), audit.Event{Type: audit.AuthLogin, Owner: creds.Username, SourceIP: ip, Outcome: audit.OutcomeDenied, Details: map[string]any{"reason": "locked_out"}})
+					w.Header().Set("Retry-After", ratelimit.RetryAfter(wait))
+					http.Error(w, "too many failed login attempts, try again later", http.StatusTooManyRequests)
+					return
+				}
+				if !authStore.Authenticate(creds.Username, creds.Password) {
+					loginGuard.Failed(ip, creds.Username)
+					log.Error().Str("username", creds.Username).Str("sourceIP", ip).Msg("login failed")
+					metrics.LoginFailures.WithLabelValues("invalid_credentials").Inc()
+					auditor.Audit(req.Context(), audit.Event{Type: audit.AuthLogin, Owner: creds.Username, SourceIP: ip, Outcome: audit.OutcomeDenied})
+					http.Error(w, "invalid credentials", http.StatusUnauthorized)
+					return
+				}
+				loginGuard.Succeeded(ip, creds.Username)
+				auditor.Audit(req.Context(), audit.Event{Type: audit.AuthLogin, Owner: creds.Username, SourceIP: ip, Outcome: metrics.OutcomeSuccess})
 				token := base64.StdEncoding.EncodeToString([]byte(creds.Username + ":" + creds.Password))
 				http.SetCookie(w, &http.Cookie{
 					Name:     "browser_ui_auth",
 					Value:    token,
 					Path:     "/",
 					HttpOnly: true,
+					Secure:   cfg.Server.TLS.Enabled(),
 					SameSite: http.SameSiteStrictMode,
 				})
 			}
 			w.WriteHeader(http.StatusOK)
 		})
 
-		r.Post("/auth/logout", func(w http.ResponseWriter, _ *http.Request) {
+		r.Post("/auth/logout", func(w http.ResponseWriter, req *http.Request) {
+			if authStore != nil {
+				auditor.Audit(req.Context(), audit.Event{Type: audit.AuthLogout, Owner: cookieUsername(req), SourceIP: ratelimit.RequestIP(req), Outcome: metrics.OutcomeSuccess})
+			}
 			http.SetCookie(w, &http.Cookie{
 				Name:     "browser_ui_auth",
 				Value:    "",
 				Path:     "/",
 				HttpOnly: true,
 				MaxAge:   -1,
+				Secure:   cfg.Server.TLS.Enabled(),
 				SameSite: http.SameSiteStrictMode,
 			})
 			w.WriteHeader(http.StatusOK)
 		})
 
+		// Share links stand in for authentication, for the one session they
+		// were created for.
+		r.With(shareHandler.Authorize).HandleFunc("/share/{browserId}/vnc", svc.RouteVNC)
+
 		r.Group(func(r chi.Router) {
-			if authStore != nil {
-				r.Use(cookieAuthMiddleware(authStore, log))
+			if authEnabled {
+				r.Use(authMiddleware(authStore, proxyAuth, tokenManager, loginGuard, clientIP, log))
+				r.Use(tokens.Require(tokenScope))
+
+				tokenHandler := tokens.NewHandler(tokenManager,
+					tokens.WithTTL(time.Duration(cfg.Tokens.DefaultTTL), time.Duration(cfg.Tokens.MaxTTL)),
+					tokens.WithAuditor(auditor))
+				r.Get("/tokens", tokenHandler.List)
+				r.Post("/tokens", tokenHandler.Create)
+				r.Delete("/tokens/{tokenId}", tokenHandler.Revoke)
 			}
 			r.Route("/status", func(r chi.Router) {
 				r.Get("/", svc.GetStatus)
 			})
+			r.Get("/clusters", cluster.NewHandler(clusters).List)
+			r.Get("/vnc/connections", vncConnHandler.List)
+			r.Delete("/vnc/connections/{connectionId}", vncConnHandler.Close)
+			// Diagnostics cover Browsers of every user, namespace and cluster.
+			r.Get("/diagnostics", func(w http.ResponseWriter, req *http.Request) {
+				if authEnabled && !adminCheck(cfg.Auth.Admins, cfg.Auth.AdminGroups)(req) {
+					http.Error(w, "only admins may view diagnostics", http.StatusForbidden)
+					return
+				}
+				response := struct {
+					InvalidBrowsers []collector.InvalidBrowser `json:"invalidBrowsers"`
+				}{
+					InvalidBrowsers: []collector.InvalidBrowser{},
+				}
+				for _, col := range collectors {
+					response.InvalidBrowsers = append(response.InvalidBrowsers, col.InvalidBrowsers()...)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				if err := json.NewEncoder(w).Encode(&response); err != nil {
+					http.Error(w, "failed to encode response", http.StatusInternalServerError)
+				}
+			})
+			if historyStore != nil {
+				historyHandler := history.NewHandler(historyStore)
+				r.Get("/history", historyHandler.Sessions)
+				r.Get("/history/actions", historyHandler.Actions)
+			}
+			if dispatcher != nil {
+				var webhookOpts []webhook.HandlerOption
+				if authEnabled {
+					webhookOpts = append(webhookOpts, webhook.WithAdminCheck(adminCheck(cfg.Auth.Admins, cfg.Auth.AdminGroups)))
+				}
+				webhookHandler := webhook.NewHandler(dispatcher, webhookOpts...)
+				r.Get("/webhooks", webhookHandler.List)
+				r.Post("/webhooks/{name}/test", webhookHandler.Test)
+			}
 			r.Route("/browsers", func(r chi.Router) {
-				r.Post("/", svc.CreateBrowser)
+				r.With(browsersLimit).Post("/", svc.CreateBrowser)
+				r.Get("/catalog", catalog.NewHandler(catalogStore).List)
 				r.Route("/{browserId}", func(r chi.Router) {
 					r.Get("/", svc.GetBrowser)
-					r.Delete("/", svc.DeleteBrowser)
+					r.With(browsersLimit).Delete("/", svc.DeleteBrowser)
 					r.HandleFunc("/vnc", svc.RouteVNC)
+					r.Get("/share", shareHandler.List)
+					r.Post("/share", shareHandler.Create)
+					r.Delete("/share/{shareId}", shareHandler.Revoke)
 				})
 			})
 		})
@@ -222,6 +790,11 @@ func main() {
 		http.ServeFile(w, r, filepath.Join(staticPath, "index.html"))
 	})
 
+	// Session pages are opened directly from share links.
+	router.Get("/session/{browserId}", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join(staticPath, "index.html"))
+	})
+
 	fileServer := http.FileServer(http.Dir(staticPath))
 	router.Handle("/ui/*", http.StripPrefix("/ui/", fileServer))
 
@@ -233,25 +806,68 @@ func main() {
 		w.Write([]byte(`{"status":"ok"}`))
 	})
 
+	router.Get("/livez", health.Handler())
+	shutdown := &health.Shutdown{}
+	readyChecks = append(readyChecks, health.Check{Name: "shutdown", Checker: shutdown})
+	router.Get("/readyz", health.Handler(readyChecks...))
+
+	router.Handle("/metrics", promhttp.Handler())
+
 	srv := &http.Server{
-		Addr:    addr,
-		Handler: router,
+		Addr:              addr,
+		Handler:           router,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+		Protocols:         new(http.Protocols),
+	}
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetHTTP2(cfg.Server.HTTP2)
+
+	if tlsCfg := cfg.Server.TLS; tlsCfg.Enabled() {
+		srv.TLSConfig, err = newTLSConfig(logctx.IntoContext(ctx, log), tlsCfg)
+		if err != nil {
+			log.Fatal().Err(err).Msg("TLS setup error")
+		}
 	}
 
 	go func() {
-		log.Info().Msgf("HTTP server listening %s", addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if srv.TLSConfig != nil {
+			log.Info().Bool("http2", cfg.Server.HTTP2).Bool("mTLS", srv.TLSConfig.ClientCAs != nil).Msgf("HTTPS server listening %s", addr)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			log.Info().Msgf("HTTP server listening %s", addr)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatal().Err(err).Msg("Server failed")
 		}
 	}()
 
 	<-ctx.Done()
 	stop()
-	log.Info().Msg("Shutting down...")
+	shutdown.Begin()
+	log.Info().Dur("delay", time.Duration(cfg.Server.ShutdownDelay)).Msg("Shutting down...")
+	time.Sleep(time.Duration(cfg.Server.ShutdownDelay))
 
-	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Duration(cfg.Server.ShutdownTimeout))
 	defer cancel()
+
+	// Shutdown does not wait for hijacked connections, so VNC connections
+	// are drained alongside it.
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		if err := vncConns.Drain(shutdownCtx); err != nil {
+			log.Warn().Err(err).Msg("vnc connections not drained")
+			return
+		}
+		log.Info().Msg("vnc connections drained")
+	}()
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Error().Err(err).Msg("Server shutdown error")
 	}
+	<-drained
 }
@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/alcounit/browser-ui/pkg/metrics"
+)
+
+type EventType string
+
+const (
+	BrowserCreate EventType = "browser.create"
+	BrowserDelete EventType = "browser.delete"
+	VNCConnect    EventType = "vnc.connect"
+	VNCDisconnect EventType = "vnc.disconnect"
+	VNCForceClose EventType = "vnc.force_close"
+	AuthLogin     EventType = "auth.login"
+	AuthLogout    EventType = "auth.logout"
+	TokenCreate   EventType = "token.create"
+	TokenRevoke   EventType = "token.revoke"
+	ShareCreate   EventType = "share.create"
+	ShareRevoke   EventType = "share.revoke"
+)
+
+// OutcomeDenied marks rejected credentials; other outcomes reuse the metrics outcome values.
+const OutcomeDenied = "denied"
+
+// Event is a single audit record. Audit events are written to their own sinks
+// and never go through the operational zerolog logger.
+type Event struct {
+	Time           time.Time      `json:"time"`
+	Type           EventType      `json:"type"`
+	Owner          string         `json:"owner,omitempty"`
+	SourceIP       string         `json:"sourceIP,omitempty"`
+	BrowserId      string         `json:"browserId,omitempty"`
+	BrowserName    string         `json:"browserName,omitempty"`
+	BrowserVersion string         `json:"browserVersion,omitempty"`
+	Outcome        string         `json:"outcome"`
+	Details        map[string]any `json:"details,omitempty"`
+}
+
+type Auditor interface {
+	Audit(ctx context.Context, ev Event)
+}
+
+type Sink interface {
+	Name() string
+	Write(ev Event) error
+	Close() error
+}
+
+type discard struct{}
+
+func (discard) Audit(context.Context, Event) {}
+
+// Discard is an Auditor that drops every event; it is used when auditing is disabled.
+var Discard Auditor = discard{}
+
+// Trail fans audit events out to every configured sink. A failing sink is
+// reported but does not stop the others.
+type Trail struct {
+	sinks []Sink
+}
+
+func NewTrail(sinks ...Sink) *Trail {
+	return &Trail{sinks: sinks}
+}
+
+func (t *Trail) Audit(ctx context.Context, ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+
+	for _, sink := range t.sinks {
+		if err := sink.Write(ev); err != nil {
+			metrics.AuditSinkErrors.WithLabelValues(sink.Name()).Inc()
+			log := logctx.FromContext(ctx)
+			log.Error().Err(err).Str("sink", sink.Name()).Str("type", string(ev.Type)).Msg("failed to write audit event")
+		}
+	}
+}
+
+func (t *Trail) Close() error {
+	var firstErr error
+	for _, sink := range t.sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
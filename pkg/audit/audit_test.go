@@ -0,0 +1,210 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+)
+
+type recordingSink struct {
+	name   string
+	err    error
+	events []Event
+	closed bool
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Write(ev Event) error {
+	s.events = append(s.events, ev)
+	return s.err
+}
+
+func (s *recordingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestTrailFansOutAndSetsTime(t *testing.T) {
+	failing := &recordingSink{name: "failing-test-sink", err: errors.New("boom")}
+	ok := &recordingSink{name: "ok"}
+	trail := NewTrail(failing, ok)
+
+	before := testutil.ToFloat64(metrics.AuditSinkErrors.WithLabelValues("failing-test-sink"))
+	trail.Audit(context.Background(), Event{Type: BrowserCreate, Outcome: "success"})
+
+	if len(ok.events) != 1 || len(failing.events) != 1 {
+		t.Fatalf("expected both sinks to receive the event, got %d and %d", len(ok.events), len(failing.events))
+	}
+	if ok.events[0].Time.IsZero() {
+		t.Fatal("expected event time to be set")
+	}
+	if got := testutil.ToFloat64(metrics.AuditSinkErrors.WithLabelValues("failing-test-sink")) - before; got != 1 {
+		t.Fatalf("expected 1 sink error, got %v", got)
+	}
+
+	if err := trail.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if !ok.closed || !failing.closed {
+		t.Fatal("expected all sinks to be closed")
+	}
+}
+
+func TestWriterSinkWritesTaggedJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink("stdout", &buf)
+
+	sink.Write(Event{Type: VNCConnect, Owner: "alice", SourceIP: "10.0.0.1", BrowserId: "b1", Outcome: "success"}) //nolint:errcheck
+	sink.Write(Event{Type: VNCDisconnect, Owner: "alice", BrowserId: "b1", Outcome: "success"})                    //nolint:errcheck
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got["stream"] != "audit" || got["type"] != "vnc.connect" || got["owner"] != "alice" || got["sourceIP"] != "10.0.0.1" || got["browserId"] != "b1" {
+		t.Fatalf("unexpected line: %v", got)
+	}
+}
+
+func TestFileSinkRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	ev := Event{Type: BrowserDelete, BrowserId: "b1", Outcome: "success"}
+	b, _ := encodeLine(ev)
+
+	// Room for two lines per file, keep one backup.
+	sink, err := NewFileSink(path, int64(2*len(b)), 1)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer sink.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := sink.Write(ev); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+
+	current, _ := os.ReadFile(path)
+	if n := strings.Count(string(current), "\n"); n != 1 {
+		t.Fatalf("expected 1 line in the current file, got %d", n)
+	}
+	backup, _ := os.ReadFile(path + ".1")
+	if n := strings.Count(string(backup), "\n"); n != 2 {
+		t.Fatalf("expected 2 lines in the backup, got %d", n)
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Fatalf("expected no second backup, got %v", err)
+	}
+}
+
+func TestFileSinkAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := os.WriteFile(path, []byte("previous\n"), 0o600); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	sink, err := NewFileSink(path, 0, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	sink.Write(Event{Type: AuthLogin, Owner: "alice", Outcome: "success"}) //nolint:errcheck
+	sink.Close()
+
+	if err := sink.Write(Event{Type: AuthLogout}); err == nil {
+		t.Fatal("expected write after close to fail")
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "previous\n") || strings.Count(string(data), "\n") != 2 {
+		t.Fatalf("expected event appended to existing content, got %q", data)
+	}
+}
+
+func TestWebhookSinkDeliversEvents(t *testing.T) {
+	var mu sync.Mutex
+	var received []Event
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected application/json, got %q", ct)
+		}
+		var ev Event
+		json.NewDecoder(req.Body).Decode(&ev) //nolint:errcheck
+		mu.Lock()
+		received = append(received, ev)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	sink := NewWebhookSink(srv.URL, time.Second, 10, zerolog.New(io.Discard))
+	sink.Write(Event{Type: BrowserCreate, BrowserId: "b1", Outcome: "success"}) //nolint:errcheck
+	sink.Write(Event{Type: BrowserDelete, BrowserId: "b1", Outcome: "success"}) //nolint:errcheck
+	sink.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || received[0].Type != BrowserCreate || received[1].Type != BrowserDelete {
+		t.Fatalf("expected both events delivered in order, got %+v", received)
+	}
+
+	if err := sink.Write(Event{Type: BrowserCreate}); err == nil {
+		t.Fatal("expected write after close to fail")
+	}
+}
+
+func TestWebhookSinkCountsFailedDeliveries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	before := testutil.ToFloat64(metrics.AuditSinkErrors.WithLabelValues("webhook"))
+
+	sink := NewWebhookSink(srv.URL, time.Second, 10, zerolog.New(io.Discard))
+	sink.Write(Event{Type: BrowserCreate}) //nolint:errcheck
+	sink.Close()
+
+	if got := testutil.ToFloat64(metrics.AuditSinkErrors.WithLabelValues("webhook")) - before; got != 1 {
+		t.Fatalf("expected 1 delivery error, got %v", got)
+	}
+}
+
+func TestWebhookSinkQueueFull(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+
+	sink := NewWebhookSink(srv.URL, 5*time.Second, 1, zerolog.New(io.Discard))
+	defer sink.Close()
+	defer close(block)
+
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		err = sink.Write(Event{Type: BrowserCreate})
+	}
+	if err == nil {
+		t.Fatal("expected a full queue to reject events")
+	}
+}
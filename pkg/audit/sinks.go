@@ -0,0 +1,254 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	"github.com/rs/zerolog"
+)
+
+// stream tags every line written by the JSON sinks, so log shippers reading a
+// shared stdout can route audit events away from operational logs.
+const stream = "audit"
+
+type line struct {
+	Stream string `json:"stream"`
+	Event
+}
+
+func encodeLine(ev Event) ([]byte, error) {
+	b, err := json.Marshal(line{Stream: stream, Event: ev})
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// WriterSink writes one JSON object per line to w, e.g. os.Stdout.
+type WriterSink struct {
+	name string
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+func NewWriterSink(name string, w io.Writer) *WriterSink {
+	return &WriterSink{name: name, w: w}
+}
+
+func (s *WriterSink) Name() string { return s.name }
+
+func (s *WriterSink) Write(ev Event) error {
+	b, err := encodeLine(ev)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *WriterSink) Close() error { return nil }
+
+// FileSink appends JSON lines to a file and rotates it once it would grow past
+// maxBytes, keeping up to maxBackups old files as path.1 (newest) .. path.N.
+type FileSink struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func NewFileSink(path string, maxBytes int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) Name() string { return "file" }
+
+func (s *FileSink) Write(ev Event) error {
+	b, err := encodeLine(ev)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return errors.New("audit file is closed")
+	}
+	if s.maxBytes > 0 && s.size > 0 && s.size+int64(len(b)) > s.maxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.f.Write(b)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.f = f
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	s.f = nil
+
+	if s.maxBackups > 0 {
+		os.Remove(s.backup(s.maxBackups)) //nolint:errcheck
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			if err := os.Rename(s.backup(i), s.backup(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		if err := os.Rename(s.path, s.backup(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+func (s *FileSink) backup(n int) string {
+	return fmt.Sprintf("%s.%d", s.path, n)
+}
+
+// WebhookSink POSTs every event as JSON to a URL. Delivery happens in the
+// background so a slow endpoint never holds up a request; events are dropped
+// when the queue is full.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+	log    zerolog.Logger
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan Event
+	done   chan struct{}
+}
+
+func NewWebhookSink(url string, timeout time.Duration, queueSize int, log zerolog.Logger) *WebhookSink {
+	s := &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+		log:    log,
+		queue:  make(chan Event, queueSize),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *WebhookSink) Name() string { return "webhook" }
+
+func (s *WebhookSink) Write(ev Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return errors.New("audit webhook is closed")
+	}
+	select {
+	case s.queue <- ev:
+		return nil
+	default:
+		return errors.New("audit webhook queue is full")
+	}
+}
+
+// Close stops accepting events and waits for the queued ones to be delivered.
+func (s *WebhookSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+	return nil
+}
+
+func (s *WebhookSink) run() {
+	defer close(s.done)
+
+	for ev := range s.queue {
+		if err := s.post(ev); err != nil {
+			metrics.AuditSinkErrors.WithLabelValues(s.Name()).Inc()
+			s.log.Error().Err(err).Str("sink", s.Name()).Str("type", string(ev.Type)).Msg("failed to deliver audit event")
+		}
+	}
+}
+
+func (s *WebhookSink) post(ev Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, s.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body) //nolint:errcheck
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("audit webhook responded %s", resp.Status)
+	}
+	return nil
+}
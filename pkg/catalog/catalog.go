@@ -0,0 +1,209 @@
+// Package catalog merges the browsers of every BrowserConfig into one list,
+// with versions sorted newest first and the default and latest marked.
+package catalog
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/alcounit/browser-ui/pkg/types"
+)
+
+// Source is a BrowserConfig offering a version.
+type Source struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Config    string `json:"config"`
+}
+
+// Version is one version of a browser. Its image is the one of the first
+// source; configs are ordered by cluster, namespace and name.
+type Version struct {
+	Version string `json:"version"`
+	types.BrowserImage
+	Default bool     `json:"default,omitempty"`
+	Latest  bool     `json:"latest,omitempty"`
+	Sources []Source `json:"sources"`
+}
+
+type Browser struct {
+	Name           string    `json:"name"`
+	DefaultVersion string    `json:"defaultVersion"`
+	LatestVersion  string    `json:"latestVersion"`
+	Versions       []Version `json:"versions"`
+}
+
+// Build merges configs into browsers sorted by name. Only configs of cluster
+// and namespace are used; an empty cluster or namespace matches any. The
+// default version of a browser is the first one a config annotates that is
+// offered, or else the latest.
+func Build(configs []types.BrowserConfigCatalog, cluster, namespace string) []Browser {
+	configs = slices.Clone(configs)
+	sort.Slice(configs, func(i, j int) bool {
+		a, b := configs[i], configs[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	versions := map[string]map[string]*Version{}
+	defaults := map[string][]string{}
+	for _, cfg := range configs {
+		if (cluster != "" && cfg.Cluster != cluster) || (namespace != "" && cfg.Namespace != namespace) {
+			continue
+		}
+		source := Source{Cluster: cfg.Cluster, Namespace: cfg.Namespace, Config: cfg.Name}
+		for name, images := range cfg.Browsers {
+			if versions[name] == nil {
+				versions[name] = map[string]*Version{}
+			}
+			for version, image := range images {
+				v, ok := versions[name][version]
+				if !ok {
+					v = &Version{Version: version, BrowserImage: image}
+					versions[name][version] = v
+				}
+				v.Sources = append(v.Sources, source)
+			}
+		}
+		for name, version := range cfg.Defaults {
+			defaults[name] = append(defaults[name], version)
+		}
+	}
+
+	browsers := make([]Browser, 0, len(versions))
+	for name, byVersion := range versions {
+		if len(byVersion) == 0 {
+			continue
+		}
+		b := Browser{Name: name, Versions: make([]Version, 0, len(byVersion))}
+		for _, v := range byVersion {
+			b.Versions = append(b.Versions, *v)
+		}
+		sort.Slice(b.Versions, func(i, j int) bool {
+			return Compare(b.Versions[i].Version, b.Versions[j].Version) > 0
+		})
+
+		b.LatestVersion = b.Versions[0].Version
+		b.DefaultVersion = b.LatestVersion
+		for _, version := range defaults[name] {
+			if _, ok := byVersion[version]; ok {
+				b.DefaultVersion = version
+				break
+			}
+		}
+		for i := range b.Versions {
+			b.Versions[i].Latest = b.Versions[i].Version == b.LatestVersion
+			b.Versions[i].Default = b.Versions[i].Version == b.DefaultVersion
+		}
+		browsers = append(browsers, b)
+	}
+	sort.Slice(browsers, func(i, j int) bool { return browsers[i].Name < browsers[j].Name })
+	return browsers
+}
+
+// Compare orders versions such as "120.0", "120.0.6099" and "121.0-beta" the
+// way semantic versions are: numeric parts by value, a pre-release below its
+// release. It returns -1, 0 or 1.
+func Compare(a, b string) int {
+	pa, pb := parts(a), parts(b)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if c := comparePart(pa[i], pb[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(pa) == len(pb):
+		return 0
+	case len(pa) > len(pb):
+		// "1.0.1" is newer than "1.0", "1.0-beta" older.
+		if isNumeric(pa[len(pb)]) {
+			return 1
+		}
+		return -1
+	default:
+		if isNumeric(pb[len(pa)]) {
+			return -1
+		}
+		return 1
+	}
+}
+
+// parts splits a version into runs of digits and of other characters,
+// dropping separators.
+func parts(version string) []string {
+	var result []string
+	start := -1
+	for i, r := range version + "." {
+		sep := r == '.' || r == '-' || r == '+' || r == '_'
+		if start >= 0 && (sep || isDigit(r) != isDigit(rune(version[start]))) {
+			result = append(result, version[start:i])
+			start = -1
+		}
+		if !sep && start < 0 {
+			start = i
+		}
+	}
+	return result
+}
+
+// comparePart orders numbers by value and above anything else, and other
+// parts alphabetically.
+func comparePart(a, b string) int {
+	na, nb := isNumeric(a), isNumeric(b)
+	switch {
+	case na && nb:
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		if len(a) != len(b) {
+			if len(a) < len(b) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	case na:
+		return 1
+	case nb:
+		return -1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func isNumeric(part string) bool {
+	return part != "" && isDigit(rune(part[0]))
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// Resolve picks the version of versions a request for requested means: the
+// version itself, the newest one for "latest", or the newest one starting
+// with requested as a version prefix, so "146" matches "146.0.7680" but not
+// "1460.0".
+func Resolve(versions []string, requested string) (string, bool) {
+	if slices.Contains(versions, requested) {
+		return requested, true
+	}
+
+	resolved := ""
+	for _, v := range versions {
+		match := requested == "latest" ||
+			strings.HasPrefix(v, requested+".") || strings.HasPrefix(v, requested+"-")
+		if match && (resolved == "" || Compare(v, resolved) > 0) {
+			resolved = v
+		}
+	}
+	return resolved, resolved != ""
+}
+
+// SortVersions sorts versions newest first.
+func SortVersions(versions []string) {
+	sort.Slice(versions, func(i, j int) bool { return Compare(versions[i], versions[j]) > 0 })
+}
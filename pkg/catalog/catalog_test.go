@@ -0,0 +1,112 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/alcounit/browser-ui/pkg/types"
+)
+
+func TestCompare(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"120.0", "120.0", 0},
+		{"99.0", "120.0", -1},
+		{"120.0.6099", "120.0", 1},
+		{"121.0-beta", "121.0", -1},
+		{"121.0-beta", "120.0", 1},
+		{"121.0-alpha", "121.0-beta", -1},
+		{"010.0", "9.0", 1},
+		{"latest", "1.0", -1},
+	} {
+		if got := Compare(tt.a, tt.b); got != tt.want {
+			t.Fatalf("expected Compare(%q, %q) = %d, got %d", tt.a, tt.b, tt.want, got)
+		}
+		if got := Compare(tt.b, tt.a); got != -tt.want {
+			t.Fatalf("expected Compare(%q, %q) = %d, got %d", tt.b, tt.a, -tt.want, got)
+		}
+	}
+}
+
+func TestBuildMergesConfigs(t *testing.T) {
+	configs := []types.BrowserConfigCatalog{
+		{
+			Namespace: "qa",
+			Name:      "b",
+			Browsers: map[string]map[string]types.BrowserImage{
+				"chrome": {"120.0": {Image: "qa/chrome:120"}, "99.0": {Image: "qa/chrome:99"}},
+			},
+			Defaults: map[string]string{"chrome": "99.0"},
+		},
+		{
+			Namespace: "qa",
+			Name:      "a",
+			Browsers: map[string]map[string]types.BrowserImage{
+				"chrome":  {"120.0": {Image: "a/chrome:120"}, "121.0": {Image: "a/chrome:121"}},
+				"firefox": {"130.0": {Image: "a/firefox:130"}},
+			},
+			Defaults: map[string]string{"chrome": "1.0"},
+		},
+		{
+			Namespace: "dev",
+			Name:      "c",
+			Browsers: map[string]map[string]types.BrowserImage{
+				"edge": {"120.0": {}},
+			},
+		},
+	}
+
+	browsers := Build(configs, "", "qa")
+	if len(browsers) != 2 || browsers[0].Name != "chrome" || browsers[1].Name != "firefox" {
+		t.Fatalf("expected chrome and firefox of namespace qa, got %+v", browsers)
+	}
+
+	chrome := browsers[0]
+	var order []string
+	for _, v := range chrome.Versions {
+		order = append(order, v.Version)
+	}
+	if len(order) != 3 || order[0] != "121.0" || order[1] != "120.0" || order[2] != "99.0" {
+		t.Fatalf("expected versions newest first, got %v", order)
+	}
+	if chrome.LatestVersion != "121.0" || !chrome.Versions[0].Latest {
+		t.Fatalf("expected 121.0 to be latest, got %+v", chrome)
+	}
+	// Config "a" sorts first but its default is not offered, so "b" decides.
+	if chrome.DefaultVersion != "99.0" || !chrome.Versions[2].Default || chrome.Versions[0].Default {
+		t.Fatalf("expected 99.0 to be the default, got %+v", chrome)
+	}
+	merged := chrome.Versions[1]
+	if merged.Image != "a/chrome:120" || len(merged.Sources) != 2 || merged.Sources[0].Config != "a" || merged.Sources[1].Config != "b" {
+		t.Fatalf("expected 120.0 from both configs with the image of the first, got %+v", merged)
+	}
+
+	if firefox := browsers[1]; firefox.DefaultVersion != "130.0" {
+		t.Fatalf("expected the latest version to be the default without an annotation, got %+v", firefox)
+	}
+	if all := Build(configs, "", ""); len(all) != 3 {
+		t.Fatalf("expected every namespace without a filter, got %+v", all)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	versions := []string{"145.0", "146.0", "146.0.7680", "1460.0", "147.0-beta"}
+	for _, tt := range []struct {
+		requested, want string
+		ok              bool
+	}{
+		{"146.0", "146.0", true},
+		{"146", "146.0.7680", true},
+		{"146.0.7680", "146.0.7680", true},
+		{"147", "147.0-beta", true},
+		{"latest", "1460.0", true},
+		{"14", "", false},
+		{"148", "", false},
+	} {
+		got, ok := Resolve(versions, tt.requested)
+		if got != tt.want || ok != tt.ok {
+			t.Fatalf("expected %q to resolve to %q, %v, got %q, %v", tt.requested, tt.want, tt.ok, got, ok)
+		}
+	}
+}
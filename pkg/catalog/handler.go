@@ -0,0 +1,37 @@
+package catalog
+
+import (
+	"encoding/json"
+	"net/http"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/alcounit/browser-ui/pkg/types"
+	"github.com/alcounit/seleniferous/v2/pkg/store"
+)
+
+type Handler struct {
+	configs store.Store[types.BrowserConfigCatalog]
+}
+
+func NewHandler(configs store.Store[types.BrowserConfigCatalog]) *Handler {
+	return &Handler{configs: configs}
+}
+
+// List returns the merged catalog, limited to the BrowserConfigs of the
+// cluster and namespace query parameters when they are given.
+func (h *Handler) List(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	query := req.URL.Query()
+	response := struct {
+		Browsers []Browser `json:"browsers"`
+	}{
+		Browsers: Build(h.configs.List(), query.Get("cluster"), query.Get("namespace")),
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(&response); err != nil {
+		log.Error().Err(err).Msg("failed to encode catalog response")
+		http.Error(rw, "failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,126 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Reloader serves a certificate and key pair loaded from disk and picks up
+// new files without a restart. Use GetCertificate in a tls.Config.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// NewReloader loads the pair once; a pair that cannot be loaded is an error.
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload reads the pair again and reports whether the certificate changed.
+// On error the current certificate stays in use.
+func (r *Reloader) Reload() (bool, error) {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("load certificate %s: %w", r.certFile, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := r.cert == nil || !bytes.Equal(r.cert.Certificate[0], cert.Certificate[0])
+	r.cert = &cert
+	return changed, nil
+}
+
+// Watch reloads the pair whenever a file in the certificate or key directory
+// changes, until ctx is done. The directories are watched so that Kubernetes
+// Secret symlink swaps are noticed. Writes of the certificate before the key
+// fail to load and are retried on the next event.
+func (r *Reloader) Watch(ctx context.Context) error {
+	log := logctx.FromContext(ctx)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	for _, dir := range uniqueDirs(r.certFile, r.keyFile) {
+		if err := watcher.Add(dir); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			changed, err := r.Reload()
+			if err != nil {
+				log.Warn().Err(err).Msg("certificate reload failed, keeping previous certificate")
+				continue
+			}
+			if changed {
+				log.Info().Str("certFile", r.certFile).Msg("certificate reloaded")
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error().Err(err).Str("certFile", r.certFile).Msg("certificate watcher error")
+		}
+	}
+}
+
+func uniqueDirs(paths ...string) []string {
+	var result []string
+	for _, p := range paths {
+		dir := filepath.Dir(p)
+		if !slices.Contains(result, dir) {
+			result = append(result, dir)
+		}
+	}
+	return result
+}
+
+// LoadCertPool reads PEM encoded CA certificates, e.g. to verify client
+// certificates.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(raw) {
+		return nil, errors.New("no PEM certificates found in " + path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,164 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePair writes a self-signed certificate for commonName and its key to dir.
+func writePair(t *testing.T, dir, commonName string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func commonName(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestNewReloaderMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")); err == nil {
+		t.Fatal("expected error for missing files, got nil")
+	}
+}
+
+func TestReloaderReloadReportsChange(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writePair(t, dir, "first")
+
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := commonName(t, r); got != "first" {
+		t.Fatalf("expected first, got %q", got)
+	}
+
+	if changed, err := r.Reload(); err != nil || changed {
+		t.Fatalf("expected unchanged reload, got changed=%v err=%v", changed, err)
+	}
+
+	writePair(t, dir, "second")
+	if changed, err := r.Reload(); err != nil || !changed {
+		t.Fatalf("expected changed reload, got changed=%v err=%v", changed, err)
+	}
+	if got := commonName(t, r); got != "second" {
+		t.Fatalf("expected second, got %q", got)
+	}
+}
+
+func TestReloaderKeepsCertificateOnBadFile(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writePair(t, dir, "first")
+
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	if _, err := r.Reload(); err == nil {
+		t.Fatal("expected reload error, got nil")
+	}
+	if got := commonName(t, r); got != "first" {
+		t.Fatalf("expected previous certificate to stay, got %q", got)
+	}
+}
+
+func TestReloaderWatchPicksUpNewPair(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writePair(t, dir, "first")
+
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Watch(ctx) }()
+
+	// Give the watcher time to register before the files change.
+	time.Sleep(100 * time.Millisecond)
+	writePair(t, dir, "second")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for commonName(t, r) != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected certificate to be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestLoadCertPool(t *testing.T) {
+	dir := t.TempDir()
+	certFile, _ := writePair(t, dir, "ca")
+
+	if _, err := LoadCertPool(certFile); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	empty := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(empty, []byte("nothing here"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := LoadCertPool(empty); err == nil {
+		t.Fatal("expected error for file without certificates, got nil")
+	}
+}
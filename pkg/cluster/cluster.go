@@ -0,0 +1,172 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	browserclient "github.com/alcounit/browser-service/pkg/client/browser"
+	browserconfigclient "github.com/alcounit/browser-service/pkg/client/browserconfig"
+	"github.com/alcounit/browser-ui/pkg/health"
+	"github.com/alcounit/browser-ui/pkg/metrics"
+)
+
+// Endpoint is a browser-service URL with the display name of its cluster.
+type Endpoint struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url"`
+}
+
+// ParseEndpoints reads a comma-separated list of name=url pairs. A single URL
+// without a name is accepted for the one-cluster setup and gets an empty name.
+func ParseEndpoints(raw string) ([]Endpoint, error) {
+	var result []Endpoint
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		var ep Endpoint
+		if name, rawURL, ok := strings.Cut(item, "="); ok && !strings.Contains(name, "://") {
+			ep = Endpoint{Name: strings.TrimSpace(name), URL: strings.TrimSpace(rawURL)}
+			if ep.Name == "" {
+				return nil, fmt.Errorf("cluster name is empty in %q", item)
+			}
+		} else {
+			ep = Endpoint{URL: item}
+		}
+		result = append(result, ep)
+	}
+
+	if err := Validate(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Validate checks that every endpoint has an http(s) URL and a unique name,
+// which may only be empty when it is the single endpoint.
+func Validate(endpoints []Endpoint) error {
+	names := map[string]struct{}{}
+	for _, ep := range endpoints {
+		if u, err := url.Parse(ep.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid browser-service URL %q", ep.URL)
+		}
+		if _, ok := names[ep.Name]; ok {
+			return fmt.Errorf("duplicate cluster name %q", ep.Name)
+		}
+		names[ep.Name] = struct{}{}
+	}
+
+	switch {
+	case len(endpoints) == 0:
+		return errors.New("no browser-service URL configured")
+	case len(endpoints) > 1:
+		for _, ep := range endpoints {
+			if ep.Name == "" {
+				return fmt.Errorf("browser-service URL %q needs a cluster name when several are configured", ep.URL)
+			}
+		}
+	}
+	return nil
+}
+
+// Backend is one browser-service instance, usually one per Kubernetes cluster.
+type Backend struct {
+	Name     string
+	URL      string
+	Browsers browserclient.Client
+	Configs  browserconfigclient.Client
+	// Health reports whether the backend's collector and browser-service are up.
+	Health health.Checker
+}
+
+// Registry holds the configured backends; the first one is the default for
+// requests that do not name a cluster.
+type Registry struct {
+	backends []*Backend
+	byName   map[string]*Backend
+}
+
+func NewRegistry(backends ...*Backend) (*Registry, error) {
+	if len(backends) == 0 {
+		return nil, errors.New("at least one backend is required")
+	}
+
+	r := &Registry{byName: make(map[string]*Backend, len(backends))}
+	for _, b := range backends {
+		if _, ok := r.byName[b.Name]; ok {
+			return nil, fmt.Errorf("duplicate cluster name %q", b.Name)
+		}
+		r.byName[b.Name] = b
+		r.backends = append(r.backends, b)
+	}
+	return r, nil
+}
+
+// Get returns the named backend; an empty name selects the default one.
+func (r *Registry) Get(name string) (*Backend, bool) {
+	if name == "" {
+		return r.Default(), true
+	}
+	b, ok := r.byName[name]
+	return b, ok
+}
+
+func (r *Registry) Default() *Backend {
+	return r.backends[0]
+}
+
+func (r *Registry) Backends() []*Backend {
+	return r.backends
+}
+
+// Status is the health of one cluster as reported by /clusters.
+type Status struct {
+	Name    string         `json:"name"`
+	Default bool           `json:"default"`
+	Ready   bool           `json:"ready"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// Status checks every backend in registry order and updates the
+// cluster_ready metric with the result.
+func (r *Registry) Status(ctx context.Context) []Status {
+	result := make([]Status, 0, len(r.backends))
+	for i, b := range r.backends {
+		st := Status{Name: b.Name, Default: i == 0, Ready: true}
+		if b.Health != nil {
+			res := b.Health.Check(ctx)
+			st.Ready = res.Ready
+			st.Details = res.Details
+		}
+		ready := 0.0
+		if st.Ready {
+			ready = 1
+		}
+		metrics.ClusterReady.WithLabelValues(b.Name).Set(ready)
+		result = append(result, st)
+	}
+	return result
+}
+
+// Monitor checks every backend each interval until ctx is done, so the
+// cluster_ready metric stays current without requests to /clusters.
+func (r *Registry) Monitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.Status(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alcounit/browser-ui/pkg/health"
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseEndpointsSingleURL(t *testing.T) {
+	got, err := ParseEndpoints("http://browser-service:8080")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "" || got[0].URL != "http://browser-service:8080" {
+		t.Fatalf("unexpected endpoints: %+v", got)
+	}
+}
+
+func TestParseEndpointsNamed(t *testing.T) {
+	got, err := ParseEndpoints("eu=http://bs-eu:8080, us=https://bs-us:8443/")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 endpoints, got %d", len(got))
+	}
+	if got[0] != (Endpoint{Name: "eu", URL: "http://bs-eu:8080"}) || got[1] != (Endpoint{Name: "us", URL: "https://bs-us:8443/"}) {
+		t.Fatalf("unexpected endpoints: %+v", got)
+	}
+}
+
+func TestParseEndpointsInvalid(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"eu=ftp://bs-eu",
+		"eu=http://a:8080,eu=http://b:8080",
+		"http://a:8080,us=http://b:8080",
+		"=http://a:8080",
+	} {
+		if _, err := ParseEndpoints(raw); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestRegistryGet(t *testing.T) {
+	eu, us := &Backend{Name: "eu"}, &Backend{Name: "us"}
+	r, err := NewRegistry(eu, us)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if b, ok := r.Get(""); !ok || b != eu {
+		t.Fatalf("expected the first backend to be the default")
+	}
+	if b, ok := r.Get("us"); !ok || b != us {
+		t.Fatalf("expected backend us")
+	}
+	if _, ok := r.Get("asia"); ok {
+		t.Fatalf("expected unknown cluster not to be found")
+	}
+}
+
+func TestNewRegistryRejectsDuplicates(t *testing.T) {
+	if _, err := NewRegistry(&Backend{Name: "eu"}, &Backend{Name: "eu"}); err == nil {
+		t.Fatalf("expected duplicate cluster error")
+	}
+	if _, err := NewRegistry(); err == nil {
+		t.Fatalf("expected error for an empty registry")
+	}
+}
+
+func TestHandlerListReportsPerClusterHealth(t *testing.T) {
+	up := health.CheckerFunc(func(context.Context) health.Result { return health.Result{Ready: true} })
+	down := health.CheckerFunc(func(context.Context) health.Result {
+		return health.Result{Ready: false, Details: map[string]any{"error": "unreachable"}}
+	})
+	r, err := NewRegistry(&Backend{Name: "eu", Health: up}, &Backend{Name: "us", Health: down})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	rw := httptest.NewRecorder()
+	NewHandler(r).List(rw, httptest.NewRequest(http.MethodGet, "/clusters", nil))
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rw.Code)
+	}
+	var got struct {
+		Clusters []Status `json:"clusters"`
+	}
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(got.Clusters) != 2 {
+		t.Fatalf("expected 2 clusters, got %d", len(got.Clusters))
+	}
+	if eu := got.Clusters[0]; eu.Name != "eu" || !eu.Default || !eu.Ready {
+		t.Fatalf("unexpected eu status: %+v", eu)
+	}
+	if us := got.Clusters[1]; us.Name != "us" || us.Default || us.Ready || us.Details["error"] != "unreachable" {
+		t.Fatalf("unexpected us status: %+v", us)
+	}
+}
+
+func TestStatusSetsClusterReadyMetric(t *testing.T) {
+	up := health.CheckerFunc(func(context.Context) health.Result { return health.Result{Ready: true} })
+	down := health.CheckerFunc(func(context.Context) health.Result { return health.Result{Ready: false} })
+	r, err := NewRegistry(&Backend{Name: "metric-eu", Health: up}, &Backend{Name: "metric-us", Health: down})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	r.Status(context.Background())
+
+	if got := testutil.ToFloat64(metrics.ClusterReady.WithLabelValues("metric-eu")); got != 1 {
+		t.Fatalf("expected metric-eu to be ready, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.ClusterReady.WithLabelValues("metric-us")); got != 0 {
+		t.Fatalf("expected metric-us not to be ready, got %v", got)
+	}
+}
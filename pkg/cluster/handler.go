@@ -0,0 +1,34 @@
+package cluster
+
+import (
+	"encoding/json"
+	"net/http"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+)
+
+type Handler struct {
+	registry *Registry
+}
+
+func NewHandler(registry *Registry) *Handler {
+	return &Handler{registry: registry}
+}
+
+// List returns every cluster with its current health. It always answers 200 so
+// the UI can show which clusters are down.
+func (h *Handler) List(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	response := struct {
+		Clusters []Status `json:"clusters"`
+	}{
+		Clusters: h.registry.Status(req.Context()),
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(&response); err != nil {
+		log.Error().Err(err).Msg("failed to encode clusters response")
+		http.Error(rw, "failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -2,14 +2,23 @@ package collector
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/alcounit/browser-service/pkg/broadcast"
 	browserclient "github.com/alcounit/browser-service/pkg/client/browser"
 	browserconfigclient "github.com/alcounit/browser-service/pkg/client/browserconfig"
 	"github.com/alcounit/browser-service/pkg/event"
+	"github.com/alcounit/browser-ui/pkg/health"
+	"github.com/alcounit/browser-ui/pkg/history"
+	"github.com/alcounit/browser-ui/pkg/metrics"
 	"github.com/alcounit/browser-ui/pkg/types"
 	"github.com/alcounit/seleniferous/v2/pkg/store"
 	"github.com/alcounit/selenosis/v2/pkg/ipuuid"
@@ -23,119 +32,344 @@ import (
 type Collector struct {
 	browserClient browserclient.Client
 	configClient  browserconfigclient.Client
-	namespace     string
+	cluster       string
+	namespaces    []string
 	sessionStore  store.Store[*types.Session]
 	configStore   store.Store[types.BrowserVersions]
+	passwordStore store.Store[types.VNCPasswords]
+	catalogStore  store.Store[types.BrowserConfigCatalog]
 	broadcaster   broadcast.Broadcaster[event.BrowserEvent]
+	recorder      history.Recorder
+	runs          atomic.Int64
+
+	configNames map[string]struct{}
+
+	mu        sync.RWMutex
+	synced    bool
+	lastSync  time.Time
+	lastEvent time.Time
+	lastErr   error
+	invalid   map[string]InvalidBrowser
+}
+
+// InvalidBrowser is a Browser the collector skipped because its pod IP could
+// not be turned into a session ID.
+type InvalidBrowser struct {
+	Cluster   string    `json:"cluster,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	BrowserId string    `json:"browserId"`
+	PodIP     string    `json:"podIP"`
+	Error     string    `json:"error"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+type CollectorOption func(*Collector)
+
+// WithRecorder makes the collector report session lifecycle transitions to recorder.
+func WithRecorder(recorder history.Recorder) CollectorOption {
+	return func(c *Collector) { c.recorder = recorder }
+}
+
+// WithNamespaces makes the collector watch every listed namespace, each with
+// its own event streams, instead of the one passed to NewCollector.
+// metav1.NamespaceAll watches all namespaces through a single stream.
+func WithNamespaces(namespaces ...string) CollectorOption {
+	return func(c *Collector) {
+		if len(namespaces) > 0 {
+			c.namespaces = namespaces
+		}
+	}
+}
+
+// WithCluster tags every session and store key with the name of the cluster
+// the collector's browser-service runs in.
+func WithCluster(name string) CollectorOption {
+	return func(c *Collector) { c.cluster = name }
+}
+
+// WithPasswordStore makes the collector keep the VNC passwords annotated on
+// BrowserConfigs in passwordStore, under the same keys as the config store.
+func WithPasswordStore(passwordStore store.Store[types.VNCPasswords]) CollectorOption {
+	return func(c *Collector) { c.passwordStore = passwordStore }
 }
 
-func NewCollector(browserClient browserclient.Client, configClient browserconfigclient.Client, namespace string, sessionStore store.Store[*types.Session], configStore store.Store[types.BrowserVersions], broadcaster broadcast.Broadcaster[event.BrowserEvent]) *Collector {
-	return &Collector{browserClient, configClient, namespace, sessionStore, configStore, broadcaster}
+// WithCatalogStore makes the collector keep the images, resources and
+// default versions of BrowserConfigs in catalogStore, under the same keys as
+// the config store.
+func WithCatalogStore(catalogStore store.Store[types.BrowserConfigCatalog]) CollectorOption {
+	return func(c *Collector) { c.catalogStore = catalogStore }
+}
+
+func NewCollector(browserClient browserclient.Client, configClient browserconfigclient.Client, namespace string, sessionStore store.Store[*types.Session], configStore store.Store[types.BrowserVersions], broadcaster broadcast.Broadcaster[event.BrowserEvent], opts ...CollectorOption) *Collector {
+	c := &Collector{
+		browserClient: browserClient,
+		configClient:  configClient,
+		namespaces:    []string{namespace},
+		sessionStore:  sessionStore,
+		configStore:   configStore,
+		broadcaster:   broadcaster,
+		recorder:      history.Discard,
+		configNames:   map[string]struct{}{},
+		invalid:       map[string]InvalidBrowser{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 func (c *Collector) Run(ctx context.Context) error {
+	if c.runs.Add(1) > 1 {
+		metrics.CollectorReconnects.Inc()
+	}
+
+	err := c.run(ctx)
+
+	c.mu.Lock()
+	c.synced = false
+	c.lastErr = err
+	c.mu.Unlock()
+
+	return err
+}
+
+// Check reports the collector as ready once the initial lists are stored and
+// both event streams are open, until Run returns.
+func (c *Collector) Check(context.Context) health.Result {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	details := map[string]any{"synced": c.synced}
+	if !c.lastSync.IsZero() {
+		details["lastSyncTime"] = c.lastSync.Format(time.RFC3339)
+	}
+	if !c.lastEvent.IsZero() {
+		details["lastEventTime"] = c.lastEvent.Format(time.RFC3339)
+		details["sinceLastEvent"] = time.Since(c.lastEvent).Round(time.Second).String()
+	}
+	if c.lastErr != nil {
+		details["lastError"] = c.lastErr.Error()
+	}
+	if len(c.invalid) > 0 {
+		details["invalidBrowsers"] = len(c.invalid)
+	}
+
+	return health.Result{Ready: c.synced, Details: details}
+}
+
+// InvalidBrowsers returns the Browsers currently skipped because of a bad pod IP.
+func (c *Collector) InvalidBrowsers() []InvalidBrowser {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]InvalidBrowser, 0, len(c.invalid))
+	for _, ib := range c.invalid {
+		result = append(result, ib)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Cluster != result[j].Cluster {
+			return result[i].Cluster < result[j].Cluster
+		}
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].BrowserId < result[j].BrowserId
+	})
+	return result
+}
+
+func (c *Collector) run(ctx context.Context) error {
 	log := logctx.FromContext(ctx)
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	browsers, err := c.browserClient.List(ctx, c.namespace)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to list browsers")
-		return err
+	var browsers []*browserv1.Browser
+	for _, ns := range c.namespaces {
+		listed, err := c.browserClient.List(ctx, ns)
+		if err != nil {
+			log.Error().Err(err).Str("namespace", ns).Msg("failed to list browsers")
+			return err
+		}
+		for _, browser := range listed {
+			browser.Namespace = namespaceOr(browser.Namespace, ns)
+		}
+		browsers = append(browsers, listed...)
 	}
 
+	listedBrowsers := make(map[string]struct{}, len(browsers))
 	for _, browser := range browsers {
+		listedBrowsers[c.key(browser.Namespace, browser.Name)] = struct{}{}
+	}
+	c.pruneSessions(ctx, listedBrowsers)
+
+	c.resetInvalid()
+	for _, browser := range browsers {
+		if browser.Status.PodIP == "" {
+			storeSession(ctx, "", browser, c)
+			log.Info().Str("namespace", browser.Namespace).Str("browserId", browser.Name).Str("phase", string(browser.Status.Phase)).Msg("add pending session to store")
+			continue
+		}
 		sessionId, err := parseIp(browser.Status.PodIP)
 		if err != nil {
-			log.Error().Err(err).Str("sessionId", sessionId).Msgf("sessionId not found")
-			return errors.New("failed to convert IP to UUID")
+			c.markInvalid(browser, err)
+			log.Error().Err(err).Str("namespace", browser.Namespace).Str("browserId", browser.Name).Str("podIP", browser.Status.PodIP).Msg("skip browser with invalid pod IP")
+			continue
 		}
 
-		storeSession(sessionId, browser, c)
-		log.Info().Str("sessionId", sessionId).Msg("add session to store")
+		storeSession(ctx, sessionId, browser, c)
+		log.Info().Str("namespace", browser.Namespace).Str("sessionId", sessionId).Msg("add session to store")
 
 	}
 
-	configs, err := c.configClient.List(ctx, c.namespace)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to list browser configs")
-		return err
+	listedConfigs := map[string]*browserconfigv1.BrowserConfig{}
+	for _, ns := range c.namespaces {
+		configs, err := c.configClient.List(ctx, ns)
+		if err != nil {
+			log.Error().Err(err).Str("namespace", ns).Msg("failed to list browser configs")
+			return err
+		}
+		for _, cfg := range configs {
+			cfg.Namespace = namespaceOr(cfg.Namespace, ns)
+			listedConfigs[c.key(cfg.Namespace, cfg.Name)] = cfg
+		}
 	}
+	c.pruneConfigs(ctx, listedConfigs)
 
-	for _, cfg := range configs {
-		storeBrowserConfig(cfg.Name, cfg, c)
-		log.Info().Str("configName", cfg.Name).Msg("add browser config to store")
+	for key, cfg := range listedConfigs {
+		storeBrowserConfig(ctx, key, cfg.Namespace, cfg, c)
+		log.Info().Str("configName", key).Msg("add browser config to store")
 	}
 
-	browserStream, err := c.browserClient.Events(ctx, c.namespace)
-	if err != nil {
-		return err
+	watches := make([]watch, 0, len(c.namespaces))
+	for _, ns := range c.namespaces {
+		browserStream, err := c.browserClient.Events(ctx, ns)
+		if err != nil {
+			return err
+		}
+		defer browserStream.Close()
+
+		configStream, err := c.configClient.Events(ctx, ns)
+		if err != nil {
+			return err
+		}
+		defer configStream.Close()
+
+		watches = append(watches, watch{namespace: ns, browsers: browserStream, configs: configStream})
 	}
-	defer browserStream.Close()
 
-	configStream, err := c.configClient.Events(ctx, c.namespace)
-	if err != nil {
-		return err
+	c.mu.Lock()
+	c.synced = true
+	c.lastSync = time.Now()
+	c.lastErr = nil
+	c.mu.Unlock()
+
+	log.Info().Strs("namespaces", c.namespaces).Msg("starting collector")
+
+	// The first stream to fail ends the run; the others are cancelled and
+	// waited for, so a restart never overlaps with a previous run.
+	errCh := make(chan error, len(watches))
+	for _, w := range watches {
+		go func() { errCh <- c.watch(ctx, w) }()
+	}
+
+	err := <-errCh
+	cancel()
+	for range len(watches) - 1 {
+		<-errCh
 	}
-	defer configStream.Close()
+	return err
+}
+
+// watch is the pair of event streams opened for one namespace.
+type watch struct {
+	namespace string
+	browsers  browserclient.EventStream
+	configs   browserconfigclient.EventStream
+}
 
-	log.Info().Msg("starting collector")
+func (c *Collector) watch(ctx context.Context, w watch) error {
+	log := logctx.FromContext(ctx).With().Str("namespace", w.namespace).Logger()
 
 	for {
 		select {
-		case browserEvent, ok := <-browserStream.Events():
+		case browserEvent, ok := <-w.browsers.Events():
 			if !ok {
+				if ctx.Err() != nil {
+					return context.Canceled
+				}
 				log.Error().Msg("browser event stream closed unexpectedly")
 				return errors.New("browser event stream closed unexpectedly")
 			}
 
+			c.observeEvent()
+			metrics.CollectorEvents.WithLabelValues("browser", strings.ToLower(string(browserEvent.EventType))).Inc()
+
+			browser := browserEvent.Browser
+			browser.Namespace = namespaceOr(browser.Namespace, w.namespace)
+			c.publish(browserEvent)
+
 			switch browserEvent.EventType {
 			case event.EventTypeDeleted:
-				c.sessionStore.Delete(browserEvent.Browser.Name)
-				log.Info().Str("eventType", "deleted").Str("sessionId", browserEvent.Browser.Name).Msg("delete session from store")
+				c.deleteSession(ctx, browser)
+				c.clearInvalid(browser)
+				log.Info().Str("eventType", "deleted").Str("sessionId", browser.Name).Msg("delete session from store")
 				continue
 			case event.EventTypeAdded, event.EventTypeModified:
-				if browserEvent.Browser.Status.PodIP == "" {
+				if browser.Status.PodIP == "" {
+					c.clearInvalid(browser)
+					storeSession(ctx, "", browser, c)
+					eventType := strings.ToLower(string(browserEvent.EventType))
+					log.Info().Str("eventType", eventType).Str("browserId", browser.Name).Str("phase", string(browser.Status.Phase)).Msg("add/update pending session in store")
 					continue
 				}
-				sessionId, err := parseIp(browserEvent.Browser.Status.PodIP)
+				sessionId, err := parseIp(browser.Status.PodIP)
 				if err != nil {
-					log.Error().Err(err).Str("sessionId", sessionId).Msgf("sessionId not found")
-					return errors.New("failed to convert IP to UUID")
+					c.markInvalid(browser, err)
+					log.Error().Err(err).Str("browserId", browser.Name).Str("podIP", browser.Status.PodIP).Msg("skip browser with invalid pod IP")
+					continue
 				}
 
-				storeSession(sessionId, browserEvent.Browser, c)
+				c.clearInvalid(browser)
+				storeSession(ctx, sessionId, browser, c)
 
 				eventType := strings.ToLower(string(browserEvent.EventType))
 				log.Info().Str("eventType", eventType).Str("sessionId", sessionId).Msg("add/update session in store")
 				continue
 			}
 
-		case configEvent, ok := <-configStream.Events():
+		case configEvent, ok := <-w.configs.Events():
 			if !ok {
+				if ctx.Err() != nil {
+					return context.Canceled
+				}
 				log.Error().Msg("browser config event stream closed unexpectedly")
 				return errors.New("browser config event stream closed unexpectedly")
 			}
 
+			c.observeEvent()
+			metrics.CollectorEvents.WithLabelValues("browserconfig", strings.ToLower(string(configEvent.EventType))).Inc()
+
 			cfg := configEvent.BrowserConfig
+			namespace := namespaceOr(cfg.Namespace, w.namespace)
+			key := c.key(namespace, cfg.Name)
 			switch configEvent.EventType {
 			case event.EventTypeDeleted:
-				c.configStore.Delete(cfg.Name)
-				log.Info().Str("eventType", "deleted").Str("configName", cfg.Name).Msg("delete browser config from store")
+				deleteBrowserConfig(key, c)
+				log.Info().Str("eventType", "deleted").Str("configName", key).Msg("delete browser config from store")
 			case event.EventTypeAdded, event.EventTypeModified:
 
-				storeBrowserConfig(cfg.Name, cfg, c)
+				storeBrowserConfig(ctx, key, namespace, cfg, c)
 				eventType := strings.ToLower(string(configEvent.EventType))
-				log.Info().Str("eventType", eventType).Str("configName", cfg.Name).Msg("add/update browser config in store")
+				log.Info().Str("eventType", eventType).Str("configName", key).Msg("add/update browser config in store")
 			}
 
-		case err, ok := <-browserStream.Errors():
+		case err, ok := <-w.browsers.Errors():
 			if ok && err != nil {
 				return err
 			}
 
-		case err, ok := <-configStream.Errors():
+		case err, ok := <-w.configs.Errors():
 			if ok && err != nil {
 				return err
 			}
@@ -146,6 +380,110 @@ func (c *Collector) Run(ctx context.Context) error {
 	}
 }
 
+// key is the store key of a Browser or BrowserConfig seen by this collector.
+func (c *Collector) key(namespace, name string) string {
+	return types.StoreKey(c.cluster, namespace, name)
+}
+
+// namespaceOr returns namespace, or fallback for objects that came without one.
+func namespaceOr(namespace, fallback string) string {
+	if namespace != "" {
+		return namespace
+	}
+	return fallback
+}
+
+// pruneSessions drops sessions whose Browser is no longer listed, e.g. deleted
+// while the event stream was down, and reports each to the recorder as
+// deleted, so history and webhooks see the same end as for a delete event.
+// Sessions of other clusters sharing the store are left alone.
+func (c *Collector) pruneSessions(ctx context.Context, listed map[string]struct{}) {
+	log := logctx.FromContext(ctx)
+
+	for _, sess := range c.sessionStore.List() {
+		if sess.Cluster != c.cluster {
+			continue
+		}
+		if _, ok := listed[sess.Key()]; ok {
+			continue
+		}
+
+		c.sessionStore.Delete(sess.Key())
+		c.recorder.RecordSession(ctx, history.SessionEvent{Type: history.SessionDeleted, Time: time.Now(), Session: sess})
+		metrics.CollectorPruned.WithLabelValues("browser").Inc()
+		log.Info().Str("eventType", "deleted").Str("namespace", sess.Namespace).Str("sessionId", sess.BrowserId).Msg("prune stale session from store")
+	}
+}
+
+// pruneConfigs drops browser configs that are no longer listed.
+func (c *Collector) pruneConfigs(ctx context.Context, listed map[string]*browserconfigv1.BrowserConfig) {
+	log := logctx.FromContext(ctx)
+
+	c.mu.RLock()
+	names := make([]string, 0, len(c.configNames))
+	for name := range c.configNames {
+		names = append(names, name)
+	}
+	c.mu.RUnlock()
+
+	for _, name := range names {
+		if _, ok := listed[name]; ok {
+			continue
+		}
+
+		deleteBrowserConfig(name, c)
+		metrics.CollectorPruned.WithLabelValues("browserconfig").Inc()
+		log.Info().Str("eventType", "deleted").Str("configName", name).Msg("prune stale browser config from store")
+	}
+}
+
+func (c *Collector) markInvalid(browser *browserv1.Browser, err error) {
+	c.mu.Lock()
+	c.invalid[c.key(browser.Namespace, browser.Name)] = InvalidBrowser{
+		Cluster:   c.cluster,
+		Namespace: browser.Namespace,
+		BrowserId: browser.Name,
+		PodIP:     browser.Status.PodIP,
+		Error:     err.Error(),
+		LastSeen:  time.Now(),
+	}
+	n := len(c.invalid)
+	c.mu.Unlock()
+
+	metrics.CollectorItemErrors.WithLabelValues("browser", "invalid_pod_ip").Inc()
+	metrics.CollectorInvalidBrowsers.WithLabelValues(c.cluster).Set(float64(n))
+}
+
+func (c *Collector) clearInvalid(browser *browserv1.Browser) {
+	c.mu.Lock()
+	delete(c.invalid, c.key(browser.Namespace, browser.Name))
+	n := len(c.invalid)
+	c.mu.Unlock()
+
+	metrics.CollectorInvalidBrowsers.WithLabelValues(c.cluster).Set(float64(n))
+}
+
+func (c *Collector) resetInvalid() {
+	c.mu.Lock()
+	c.invalid = map[string]InvalidBrowser{}
+	c.mu.Unlock()
+
+	metrics.CollectorInvalidBrowsers.WithLabelValues(c.cluster).Set(0)
+}
+
+func (c *Collector) publish(ev *event.BrowserEvent) {
+	if c.broadcaster == nil {
+		return
+	}
+	c.broadcaster.Broadcast(*ev)
+}
+
+func (c *Collector) observeEvent() {
+	c.mu.Lock()
+	c.lastEvent = time.Now()
+	c.mu.Unlock()
+}
+
 func parseIp(ip string) (string, error) {
 	netIp := net.ParseIP(ip)
 	rawId, err := ipuuid.IPToUUID(netIp)
@@ -153,7 +491,7 @@ func parseIp(ip string) (string, error) {
 
 }
 
-func storeBrowserConfig(configName string, cfg *browserconfigv1.BrowserConfig, c *Collector) {
+func storeBrowserConfig(ctx context.Context, configName, namespace string, cfg *browserconfigv1.BrowserConfig, c *Collector) {
 	result := make(types.BrowserVersions, len(cfg.Spec.Browsers))
 	for browserName, versions := range cfg.Spec.Browsers {
 		vs := make([]string, 0, len(versions))
@@ -164,20 +502,269 @@ func storeBrowserConfig(configName string, cfg *browserconfigv1.BrowserConfig, c
 	}
 
 	c.configStore.Set(configName, result)
+	storeVNCPasswords(ctx, configName, namespace, cfg, c)
+	storeCatalog(ctx, configName, namespace, cfg, c)
+
+	c.mu.Lock()
+	c.configNames[configName] = struct{}{}
+	c.mu.Unlock()
+}
+
+// storeVNCPasswords keeps the passwords annotated on cfg. A config whose
+// annotation cannot be parsed contributes no passwords.
+func storeVNCPasswords(ctx context.Context, configName, namespace string, cfg *browserconfigv1.BrowserConfig, c *Collector) {
+	if c.passwordStore == nil {
+		return
+	}
+	raw, ok := cfg.Annotations[types.VNCPasswordsAnnotation]
+	if !ok || raw == "" {
+		c.passwordStore.Delete(configName)
+		return
+	}
+
+	var passwords map[string]string
+	if err := json.Unmarshal([]byte(raw), &passwords); err != nil {
+		c.passwordStore.Delete(configName)
+		metrics.CollectorItemErrors.WithLabelValues("browserconfig", "invalid_vnc_passwords").Inc()
+		log := logctx.FromContext(ctx)
+		log.Warn().Err(err).Str("configName", configName).Msg("ignoring invalid vnc passwords annotation")
+		return
+	}
+	c.passwordStore.Set(configName, types.VNCPasswords{
+		Cluster:   c.cluster,
+		Namespace: namespace,
+		Passwords: passwords,
+	})
 }
 
-func storeSession(sessionId string, browser *browserv1.Browser, c *Collector) {
+// storeCatalog keeps what cfg says about each of its browser versions. An
+// invalid default versions annotation is ignored; the browsers are still kept.
+func storeCatalog(ctx context.Context, configName, namespace string, cfg *browserconfigv1.BrowserConfig, c *Collector) {
+	if c.catalogStore == nil {
+		return
+	}
+
+	catalog := types.BrowserConfigCatalog{
+		Cluster:   c.cluster,
+		Namespace: namespace,
+		Name:      cfg.Name,
+		Browsers:  make(map[string]map[string]types.BrowserImage, len(cfg.Spec.Browsers)),
+	}
+	for browserName, versions := range cfg.Spec.Browsers {
+		images := make(map[string]types.BrowserImage, len(versions))
+		for version, spec := range versions {
+			if spec == nil {
+				images[version] = types.BrowserImage{}
+				continue
+			}
+			image := types.BrowserImage{Image: spec.Image, Resources: spec.Resources}
+			for _, env := range spec.Env {
+				enabled, err := strconv.ParseBool(env.Value)
+				switch {
+				case err != nil:
+				case env.Name == "ENABLE_VNC":
+					image.VNC = &enabled
+				case env.Name == "ENABLE_VIDEO":
+					image.Video = &enabled
+				}
+			}
+			images[version] = image
+		}
+		catalog.Browsers[browserName] = images
+	}
+
+	if raw := cfg.Annotations[types.DefaultVersionsAnnotation]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &catalog.Defaults); err != nil {
+			catalog.Defaults = nil
+			metrics.CollectorItemErrors.WithLabelValues("browserconfig", "invalid_default_versions").Inc()
+			log := logctx.FromContext(ctx)
+			log.Warn().Err(err).Str("configName", configName).Msg("ignoring invalid default versions annotation")
+		}
+	}
+
+	c.catalogStore.Set(configName, catalog)
+}
+
+func deleteBrowserConfig(configName string, c *Collector) {
+	c.configStore.Delete(configName)
+	if c.passwordStore != nil {
+		c.passwordStore.Delete(configName)
+	}
+	if c.catalogStore != nil {
+		c.catalogStore.Delete(configName)
+	}
+
+	c.mu.Lock()
+	delete(c.configNames, configName)
+	c.mu.Unlock()
+}
+
+func storeSession(ctx context.Context, sessionId string, browser *browserv1.Browser, c *Collector) {
 	sess := &types.Session{
-		SessionId:       sessionId,
-		BrowserId:       browser.Name,
-		BrowserIP:       browser.Status.PodIP,
-		BrowserName:     browser.Spec.BrowserName,
-		BrowserVersion:  browser.Spec.BrowserVersion,
-		Owner:           browser.Labels[browserv1.SelenosisOwnerLabelKey],
-		StartedManually: browser.Annotations["startedManually"] == "true",
-		StartTime:       browser.CreationTimestamp.DeepCopy(),
-		Phase:           corev1.PodPhase(browser.Status.Phase),
-	}
-
-	c.sessionStore.Set(browser.Name, sess)
+		SessionId:        sessionId,
+		Cluster:          c.cluster,
+		Namespace:        browser.Namespace,
+		BrowserId:        browser.Name,
+		BrowserIP:        browser.Status.PodIP,
+		BrowserName:      browser.Spec.BrowserName,
+		BrowserVersion:   browser.Spec.BrowserVersion,
+		Owner:            browser.Labels[browserv1.SelenosisOwnerLabelKey],
+		StartedManually:  browser.Annotations["startedManually"] == "true",
+		StartTime:        browser.CreationTimestamp.DeepCopy(),
+		Phase:            corev1.PodPhase(browser.Status.Phase),
+		Reason:           browser.Status.Reason,
+		Message:          browser.Status.Message,
+		NodeName:         browser.Status.NodeName,
+		Conditions:       sessionConditions(browser),
+		Containers:       sessionContainers(browser),
+		SelenosisOptions: sessionSelenosisOptions(browser),
+	}
+
+	prev, existed := c.sessionStore.Get(sess.Key())
+	c.sessionStore.Set(sess.Key(), sess)
+
+	now := time.Now()
+	if !existed {
+		c.recorder.RecordSession(ctx, history.SessionEvent{Type: history.SessionCreated, Time: now, Session: sess})
+	}
+	if sess.Phase == corev1.PodRunning && (!existed || prev.Phase != corev1.PodRunning) {
+		c.recorder.RecordSession(ctx, history.SessionEvent{Type: history.SessionRunning, Time: now, Session: sess})
+	}
+	if sess.Phase == corev1.PodFailed && (!existed || prev.Phase != corev1.PodFailed) {
+		c.recorder.RecordSession(ctx, history.SessionEvent{Type: history.SessionFailed, Time: now, Session: sess})
+	}
+}
+
+// deleteSession drops the Browser's session and records the deletion, falling
+// back to the event's Browser when the session was never stored.
+func (c *Collector) deleteSession(ctx context.Context, browser *browserv1.Browser) {
+	key := c.key(browser.Namespace, browser.Name)
+	sess, ok := c.sessionStore.Get(key)
+	if !ok {
+		sess = &types.Session{
+			Cluster:        c.cluster,
+			Namespace:      browser.Namespace,
+			BrowserId:      browser.Name,
+			BrowserName:    browser.Spec.BrowserName,
+			BrowserVersion: browser.Spec.BrowserVersion,
+			Owner:          browser.Labels[browserv1.SelenosisOwnerLabelKey],
+		}
+	}
+
+	c.sessionStore.Delete(key)
+	c.recorder.RecordSession(ctx, history.SessionEvent{Type: history.SessionDeleted, Time: time.Now(), Session: sess})
+}
+
+func sessionConditions(browser *browserv1.Browser) []types.Condition {
+	if len(browser.Status.Conditions) == 0 {
+		return nil
+	}
+
+	result := make([]types.Condition, 0, len(browser.Status.Conditions))
+	for _, cond := range browser.Status.Conditions {
+		c := types.Condition{
+			Type:    cond.Type,
+			Status:  string(cond.Status),
+			Reason:  cond.Reason,
+			Message: cond.Message,
+		}
+		if !cond.LastTransitionTime.IsZero() {
+			c.LastTransitionTime = cond.LastTransitionTime.DeepCopy()
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+func sessionContainers(browser *browserv1.Browser) []types.ContainerStatus {
+	if len(browser.Status.ContainerStatuses) == 0 {
+		return nil
+	}
+
+	result := make([]types.ContainerStatus, 0, len(browser.Status.ContainerStatuses))
+	for _, cs := range browser.Status.ContainerStatuses {
+		status := types.ContainerStatus{
+			Name:         cs.Name,
+			Image:        cs.Image,
+			Ready:        cs.Ready,
+			RestartCount: cs.RestartCount,
+		}
+
+		switch {
+		case cs.State.Running != nil:
+			status.State = "running"
+		case cs.State.Waiting != nil:
+			status.State = "waiting"
+			status.Reason = cs.State.Waiting.Reason
+			status.Message = cs.State.Waiting.Message
+		case cs.State.Terminated != nil:
+			status.State = "terminated"
+			status.Reason = cs.State.Terminated.Reason
+			status.Message = cs.State.Terminated.Message
+		}
+
+		if cs.Resources != nil && len(cs.Resources.Requests) > 0 {
+			status.Requests = make(map[string]string, len(cs.Resources.Requests))
+			for name, qty := range cs.Resources.Requests {
+				status.Requests[string(name)] = qty.String()
+			}
+		}
+
+		result = append(result, status)
+	}
+	return result
+}
+
+// sensitiveOptionKeys are selenosis option keys whose values never leave the server.
+var sensitiveOptionKeys = []string{"password", "secret", "token", "credential"}
+
+// sessionSelenosisOptions decodes the selenosis options annotation back into
+// JSON, redacting values under keys that look like credentials.
+func sessionSelenosisOptions(browser *browserv1.Browser) json.RawMessage {
+	raw, ok := browser.Annotations[browserv1.SelenosisOptionsAnnotationKey]
+	if !ok || raw == "" {
+		return nil
+	}
+
+	var opts any
+	if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+		return nil
+	}
+
+	b, err := json.Marshal(redactOptions(opts))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func redactOptions(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			if isSensitiveOptionKey(k) {
+				val[k] = "***"
+				continue
+			}
+			val[k] = redactOptions(item)
+		}
+		return val
+	case []any:
+		for i, item := range val {
+			val[i] = redactOptions(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+func isSensitiveOptionKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveOptionKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
 }
@@ -2,20 +2,27 @@ package collector
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
 	browserv1 "github.com/alcounit/browser-controller/apis/browser/v1"
 	browserconfigv1 "github.com/alcounit/browser-controller/apis/browserconfig/v1"
+	"github.com/alcounit/browser-service/pkg/broadcast"
 	browserclient "github.com/alcounit/browser-service/pkg/client/browser"
 	browserconfigclient "github.com/alcounit/browser-service/pkg/client/browserconfig"
 	"github.com/alcounit/browser-service/pkg/event"
+	"github.com/alcounit/browser-ui/pkg/history"
+	"github.com/alcounit/browser-ui/pkg/metrics"
 	"github.com/alcounit/browser-ui/pkg/types"
 	"github.com/alcounit/seleniferous/v2/pkg/store"
 	"github.com/alcounit/selenosis/v2/pkg/ipuuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -196,7 +203,7 @@ func TestCollectorRunDeletesSession(t *testing.T) {
 	}
 	client := &fakeClient{stream: stream}
 	st := store.NewDefaultStore[*types.Session]()
-	st.Set("browser-1", &types.Session{BrowserId: "browser-1"})
+	st.Set("default/browser-1", &types.Session{Namespace: "default", BrowserId: "browser-1"})
 	col := NewCollector(client, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil)
 
 	stream.eventsCh <- newBrowserEvent(event.EventTypeDeleted, "browser-1", "")
@@ -206,12 +213,12 @@ func TestCollectorRunDeletesSession(t *testing.T) {
 	if err == nil || err.Error() != "browser event stream closed unexpectedly" {
 		t.Fatalf("expected stream closed error, got %v", err)
 	}
-	if _, ok := st.Get("browser-1"); ok {
+	if _, ok := st.Get("default/browser-1"); ok {
 		t.Fatalf("expected browser-1 to be deleted")
 	}
 }
 
-func TestCollectorRunSkipsEmptyPodIP(t *testing.T) {
+func TestCollectorRunStoresPendingSession(t *testing.T) {
 	stream := &fakeStream{
 		eventsCh: make(chan *event.BrowserEvent, 1),
 		errorsCh: make(chan error, 1),
@@ -220,31 +227,186 @@ func TestCollectorRunSkipsEmptyPodIP(t *testing.T) {
 	st := store.NewDefaultStore[*types.Session]()
 	col := NewCollector(client, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil)
 
-	stream.eventsCh <- newBrowserEvent(event.EventTypeAdded, "browser-1", "")
+	ev := newBrowserEvent(event.EventTypeAdded, "browser-1", "")
+	ev.Browser.Status.Phase = corev1.PodPending
+	ev.Browser.Status.Reason = "ImagePullBackOff"
+	ev.Browser.Status.Message = "Back-off pulling image"
+	stream.eventsCh <- ev
 	close(stream.eventsCh)
 
 	err := col.Run(context.Background())
 	if err == nil || err.Error() != "browser event stream closed unexpectedly" {
 		t.Fatalf("expected stream closed error, got %v", err)
 	}
-	if st.Len() != 0 {
-		t.Fatalf("expected store to be empty")
+
+	sess, ok := st.Get("default/browser-1")
+	if !ok {
+		t.Fatalf("expected pending browser-1 to be stored")
+	}
+	if sess.SessionId != "" || sess.BrowserIP != "" {
+		t.Fatalf("expected no sessionId or IP for pending session, got %q %q", sess.SessionId, sess.BrowserIP)
+	}
+	if sess.Phase != corev1.PodPending {
+		t.Fatalf("expected phase Pending, got %s", sess.Phase)
+	}
+	if sess.Reason != "ImagePullBackOff" || sess.Message != "Back-off pulling image" {
+		t.Fatalf("expected reason and message from Browser status, got %q %q", sess.Reason, sess.Message)
 	}
 }
 
-func TestCollectorRunInvalidIP(t *testing.T) {
+func TestCollectorRunPendingSessionGetsIP(t *testing.T) {
 	stream := &fakeStream{
-		eventsCh: make(chan *event.BrowserEvent, 1),
+		eventsCh: make(chan *event.BrowserEvent, 2),
+		errorsCh: make(chan error, 1),
+	}
+	st := store.NewDefaultStore[*types.Session]()
+	col := NewCollector(&fakeClient{stream: stream}, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil)
+
+	pending := newBrowserEvent(event.EventTypeAdded, "browser-1", "")
+	pending.Browser.Status.Phase = corev1.PodPending
+	stream.eventsCh <- pending
+	stream.eventsCh <- newBrowserEvent(event.EventTypeModified, "browser-1", "127.0.0.1")
+	close(stream.eventsCh)
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	sess, ok := st.Get("default/browser-1")
+	if !ok {
+		t.Fatalf("expected browser-1 to be stored")
+	}
+	expectedID, _ := ipuuid.IPToUUID(net.ParseIP("127.0.0.1"))
+	if sess.SessionId != expectedID.String() || sess.Phase != corev1.PodRunning {
+		t.Fatalf("expected running session %s, got %q %s", expectedID.String(), sess.SessionId, sess.Phase)
+	}
+}
+
+func TestCollectorRunInvalidIPIsSkipped(t *testing.T) {
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent, 2),
 		errorsCh: make(chan error, 1),
 	}
 	client := &fakeClient{stream: stream}
-	col := NewCollector(client, &fakeConfigClient{}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), nil)
+	st := store.NewDefaultStore[*types.Session]()
+	col := NewCollector(client, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil)
 
-	stream.eventsCh <- newBrowserEvent(event.EventTypeAdded, "browser-1", "not-an-ip")
+	stream.eventsCh <- newBrowserEvent(event.EventTypeAdded, "browser-bad", "not-an-ip")
+	stream.eventsCh <- newBrowserEvent(event.EventTypeAdded, "browser-good", "127.0.0.1")
+	close(stream.eventsCh)
 
 	err := col.Run(context.Background())
-	if err == nil || err.Error() != "failed to convert IP to UUID" {
-		t.Fatalf("expected convert error, got %v", err)
+	if err == nil || err.Error() != "browser event stream closed unexpectedly" {
+		t.Fatalf("expected collector to keep streaming past the bad IP, got %v", err)
+	}
+
+	if _, ok := st.Get("default/browser-bad"); ok {
+		t.Fatalf("expected browser-bad to be skipped")
+	}
+	if _, ok := st.Get("default/browser-good"); !ok {
+		t.Fatalf("expected browser-good to be stored")
+	}
+
+	invalid := col.InvalidBrowsers()
+	if len(invalid) != 1 || invalid[0].BrowserId != "browser-bad" || invalid[0].PodIP != "not-an-ip" {
+		t.Fatalf("expected browser-bad to be recorded as invalid, got %+v", invalid)
+	}
+}
+
+func TestCollectorRunInvalidIPClearedOnDelete(t *testing.T) {
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent, 2),
+		errorsCh: make(chan error, 1),
+	}
+	col := NewCollector(&fakeClient{stream: stream}, &fakeConfigClient{}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), nil)
+
+	stream.eventsCh <- newBrowserEvent(event.EventTypeAdded, "browser-bad", "300.1.1.1")
+	stream.eventsCh <- newBrowserEvent(event.EventTypeDeleted, "browser-bad", "")
+	close(stream.eventsCh)
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	if invalid := col.InvalidBrowsers(); len(invalid) != 0 {
+		t.Fatalf("expected no invalid browsers after delete, got %+v", invalid)
+	}
+}
+
+func TestCollectorRunInvalidIPClearedWhenPendingAgain(t *testing.T) {
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent, 2),
+		errorsCh: make(chan error, 1),
+	}
+	col := NewCollector(&fakeClient{stream: stream}, &fakeConfigClient{}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), nil, WithCluster("pending-again"))
+
+	stream.eventsCh <- newBrowserEvent(event.EventTypeAdded, "browser-bad", "300.1.1.1")
+	stream.eventsCh <- newBrowserEvent(event.EventTypeModified, "browser-bad", "")
+	close(stream.eventsCh)
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	if invalid := col.InvalidBrowsers(); len(invalid) != 0 {
+		t.Fatalf("expected no invalid browsers once pending again, got %+v", invalid)
+	}
+	if got := testutil.ToFloat64(metrics.CollectorInvalidBrowsers.WithLabelValues("pending-again")); got != 0 {
+		t.Fatalf("expected the gauge to drop back to 0, got %v", got)
+	}
+}
+
+func TestCollectorInvalidBrowsersGaugePerCluster(t *testing.T) {
+	newStream := func(name string) *fakeStream {
+		stream := &fakeStream{
+			eventsCh: make(chan *event.BrowserEvent, 1),
+			errorsCh: make(chan error, 1),
+		}
+		stream.eventsCh <- newBrowserEvent(event.EventTypeAdded, name, "not-an-ip")
+		close(stream.eventsCh)
+		return stream
+	}
+	st := store.NewDefaultStore[*types.Session]()
+	eu := NewCollector(&fakeClient{stream: newStream("browser-eu")}, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil, WithCluster("gauge-eu"))
+	us := NewCollector(&fakeClient{stream: newStream("browser-us")}, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil, WithCluster("gauge-us"))
+
+	eu.Run(context.Background()) //nolint:errcheck
+	us.Run(context.Background()) //nolint:errcheck
+	us.resetInvalid()
+
+	if got := testutil.ToFloat64(metrics.CollectorInvalidBrowsers.WithLabelValues("gauge-eu")); got != 1 {
+		t.Fatalf("expected a reset of another cluster to keep eu's count, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.CollectorInvalidBrowsers.WithLabelValues("gauge-us")); got != 0 {
+		t.Fatalf("expected us to be reset, got %v", got)
+	}
+}
+
+func TestCollectorRunIPv6PodIP(t *testing.T) {
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent, 1),
+		errorsCh: make(chan error, 1),
+	}
+	st := store.NewDefaultStore[*types.Session]()
+	col := NewCollector(&fakeClient{stream: stream}, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil)
+
+	stream.eventsCh <- newBrowserEvent(event.EventTypeAdded, "browser-v6", "fd00::10:2")
+	close(stream.eventsCh)
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	sess, ok := st.Get("default/browser-v6")
+	if !ok {
+		t.Fatalf("expected IPv6 browser to be stored, invalid: %+v", col.InvalidBrowsers())
+	}
+	expectedID, _ := ipuuid.IPToUUID(net.ParseIP("fd00::10:2"))
+	if sess.SessionId != expectedID.String() {
+		t.Fatalf("expected sessionId %s, got %s", expectedID.String(), sess.SessionId)
+	}
+	if sess.BrowserIP != "fd00::10:2" {
+		t.Fatalf("expected browserIP fd00::10:2, got %s", sess.BrowserIP)
+	}
+}
+
+func TestParseIpMalformed(t *testing.T) {
+	for _, ip := range []string{"not-an-ip", "10.0.0", "10.0.0.256", "fd00:::1", "[fd00::1]", "10.0.0.1:4445"} {
+		if _, err := parseIp(ip); err == nil {
+			t.Fatalf("expected error for %q", ip)
+		}
 	}
 }
 
@@ -287,7 +449,7 @@ func TestCollectorRunListBrowsersPopulatesStore(t *testing.T) {
 
 	col.Run(context.Background()) //nolint:errcheck
 
-	sess, ok := st.Get("browser-existing")
+	sess, ok := st.Get("default/browser-existing")
 	if !ok {
 		t.Fatalf("expected browser-existing to be in store after ListBrowsers")
 	}
@@ -314,7 +476,7 @@ func TestCollectorRunAddsSession(t *testing.T) {
 		t.Fatalf("expected stream closed error, got %v", err)
 	}
 
-	sess, ok := st.Get("browser-1")
+	sess, ok := st.Get("default/browser-1")
 	if !ok {
 		t.Fatalf("expected browser-1 to be stored")
 	}
@@ -372,7 +534,7 @@ func TestCollectorRunListConfigsPopulatesStore(t *testing.T) {
 	col := NewCollector(cl, cfgClient, "default", store.NewDefaultStore[*types.Session](), cfgStore, nil)
 	col.Run(context.Background()) //nolint:errcheck
 
-	bv, ok := cfgStore.Get("cfg-1")
+	bv, ok := cfgStore.Get("default/cfg-1")
 	if !ok {
 		t.Fatalf("expected cfg-1 to be in config store")
 	}
@@ -455,7 +617,7 @@ func TestCollectorRunConfigEventAdded(t *testing.T) {
 
 	col.Run(context.Background()) //nolint:errcheck
 
-	bv, ok := cfgStore.Get("cfg-added")
+	bv, ok := cfgStore.Get("default/cfg-added")
 	if !ok {
 		t.Fatalf("expected cfg-added to be in config store")
 	}
@@ -475,7 +637,7 @@ func TestCollectorRunConfigEventModified(t *testing.T) {
 	}
 
 	cfgStore := store.NewDefaultStore[types.BrowserVersions]()
-	cfgStore.Set("cfg-modified", types.BrowserVersions{"old-browser": {"1.0"}})
+	cfgStore.Set("default/cfg-modified", types.BrowserVersions{"old-browser": {"1.0"}})
 
 	cl := &fakeClient{stream: browserStream}
 	cfgClient := &fakeConfigClient{stream: configStream}
@@ -490,7 +652,7 @@ func TestCollectorRunConfigEventModified(t *testing.T) {
 
 	col.Run(context.Background()) //nolint:errcheck
 
-	bv, ok := cfgStore.Get("cfg-modified")
+	bv, ok := cfgStore.Get("default/cfg-modified")
 	if !ok {
 		t.Fatalf("expected cfg-modified to be in config store")
 	}
@@ -513,7 +675,7 @@ func TestCollectorRunConfigEventDeleted(t *testing.T) {
 	}
 
 	cfgStore := store.NewDefaultStore[types.BrowserVersions]()
-	cfgStore.Set("cfg-del", types.BrowserVersions{"chrome": {"123"}})
+	cfgStore.Set("default/cfg-del", types.BrowserVersions{"chrome": {"123"}})
 
 	cl := &fakeClient{stream: browserStream}
 	cfgClient := &fakeConfigClient{stream: configStream}
@@ -525,11 +687,95 @@ func TestCollectorRunConfigEventDeleted(t *testing.T) {
 
 	col.Run(context.Background()) //nolint:errcheck
 
-	if _, ok := cfgStore.Get("cfg-del"); ok {
+	if _, ok := cfgStore.Get("default/cfg-del"); ok {
 		t.Fatalf("expected cfg-del to be deleted from config store")
 	}
 }
 
+func TestCollectorRunStoresVNCPasswords(t *testing.T) {
+	browserStream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent, 1),
+		errorsCh: make(chan error, 1),
+	}
+	configStream := &fakeConfigStream{
+		eventsCh: make(chan *event.BrowserConfigEvent, 4),
+		errorsCh: make(chan error, 1),
+	}
+
+	passwordStore := store.NewDefaultStore[types.VNCPasswords]()
+	cl := &fakeClient{stream: browserStream}
+	cfgClient := &fakeConfigClient{stream: configStream}
+
+	col := NewCollector(cl, cfgClient, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), nil,
+		WithCluster("eu"), WithPasswordStore(passwordStore))
+
+	annotated := func(eventType event.EventType, name, passwords string) *event.BrowserConfigEvent {
+		ev := newConfigEvent(eventType, name, nil)
+		ev.BrowserConfig.Annotations = map[string]string{types.VNCPasswordsAnnotation: passwords}
+		return ev
+	}
+	configStream.eventsCh <- annotated(event.EventTypeAdded, "cfg-1", `{"chrome":"s3cr3t","*":"selenoid"}`)
+	configStream.eventsCh <- annotated(event.EventTypeAdded, "cfg-2", `{"chrome":"other"}`)
+	configStream.eventsCh <- annotated(event.EventTypeModified, "cfg-2", "{not json")
+	close(configStream.eventsCh)
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	got, ok := passwordStore.Get("eu/default/cfg-1")
+	if !ok {
+		t.Fatalf("expected cfg-1 passwords to be stored")
+	}
+	if got.Cluster != "eu" || got.Namespace != "default" || got.Passwords["chrome"] != "s3cr3t" || got.Passwords["*"] != "selenoid" {
+		t.Fatalf("unexpected passwords: %+v", got)
+	}
+	if _, ok := passwordStore.Get("eu/default/cfg-2"); ok {
+		t.Fatalf("expected an invalid annotation to drop the passwords of cfg-2")
+	}
+}
+
+func TestCollectorRunStoresCatalog(t *testing.T) {
+	browserStream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent, 1),
+		errorsCh: make(chan error, 1),
+	}
+	configStream := &fakeConfigStream{
+		eventsCh: make(chan *event.BrowserConfigEvent, 4),
+		errorsCh: make(chan error, 1),
+	}
+
+	catalogStore := store.NewDefaultStore[types.BrowserConfigCatalog]()
+	col := NewCollector(&fakeClient{stream: browserStream}, &fakeConfigClient{stream: configStream}, "default",
+		store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), nil,
+		WithCatalogStore(catalogStore))
+
+	ev := newConfigEvent(event.EventTypeAdded, "cfg-1", map[string]map[string]*browserconfigv1.BrowserVersionConfigSpec{
+		"chrome": {
+			"120.0": {Image: "selenoid/chrome:120.0", Env: []corev1.EnvVar{{Name: "ENABLE_VNC", Value: "true"}, {Name: "ENABLE_VIDEO", Value: "false"}}},
+			"121.0": {Image: "selenoid/chrome:121.0"},
+		},
+	})
+	ev.BrowserConfig.Annotations = map[string]string{types.DefaultVersionsAnnotation: `{"chrome":"120.0"}`}
+	configStream.eventsCh <- ev
+	close(configStream.eventsCh)
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	got, ok := catalogStore.Get("default/cfg-1")
+	if !ok {
+		t.Fatalf("expected cfg-1 to be in the catalog")
+	}
+	if got.Name != "cfg-1" || got.Namespace != "default" || got.Defaults["chrome"] != "120.0" {
+		t.Fatalf("unexpected catalog entry: %+v", got)
+	}
+	image := got.Browsers["chrome"]["120.0"]
+	if image.Image != "selenoid/chrome:120.0" || image.VNC == nil || !*image.VNC || image.Video == nil || *image.Video {
+		t.Fatalf("expected image and features of chrome 120.0, got %+v", image)
+	}
+	if other := got.Browsers["chrome"]["121.0"]; other.VNC != nil || other.Video != nil {
+		t.Fatalf("expected unknown features without env vars, got %+v", other)
+	}
+}
+
 func TestCollectorRunContextCancelled(t *testing.T) {
 	browserStream := &fakeStream{
 		eventsCh: make(chan *event.BrowserEvent),
@@ -548,30 +794,71 @@ func TestCollectorRunContextCancelled(t *testing.T) {
 	}
 }
 
+// cancelledContext reports cancellation but never closes Done, so the watch
+// loop sees only the closed stream.
+type cancelledContext struct{ context.Context }
+
+func (cancelledContext) Done() <-chan struct{} { return nil }
+func (cancelledContext) Err() error            { return context.Canceled }
+
+func TestCollectorWatchStreamClosedByCancellation(t *testing.T) {
+	col := NewCollector(&fakeClient{}, &fakeConfigClient{}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), nil)
+	ctx := cancelledContext{context.Background()}
+
+	browsers := &fakeStream{eventsCh: make(chan *event.BrowserEvent), errorsCh: make(chan error)}
+	close(browsers.eventsCh)
+	configs := &fakeConfigStream{eventsCh: make(chan *event.BrowserConfigEvent), errorsCh: make(chan error)}
+	if err := col.watch(ctx, watch{namespace: "default", browsers: browsers, configs: configs}); err != context.Canceled {
+		t.Fatalf("expected a browser stream closed by cancellation to be a clean stop, got %v", err)
+	}
+
+	browsers = &fakeStream{eventsCh: make(chan *event.BrowserEvent), errorsCh: make(chan error)}
+	configs = &fakeConfigStream{eventsCh: make(chan *event.BrowserConfigEvent), errorsCh: make(chan error)}
+	close(configs.eventsCh)
+	if err := col.watch(ctx, watch{namespace: "default", browsers: browsers, configs: configs}); err != context.Canceled {
+		t.Fatalf("expected a config stream closed by cancellation to be a clean stop, got %v", err)
+	}
+}
+
 func TestCollectorRunInitialListBrowsersInvalidIP(t *testing.T) {
 	// The initial list of browsers contains one with an invalid IP.
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent),
+		errorsCh: make(chan error),
+	}
+	close(stream.eventsCh)
+
 	now := metav1.NewTime(time.Unix(0, 0).UTC())
-	existing := &browserv1.Browser{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:              "browser-bad",
-			CreationTimestamp: now,
-		},
-		Spec: browserv1.BrowserSpec{
-			BrowserName:    "chrome",
-			BrowserVersion: "123",
-		},
-		Status: browserv1.BrowserStatus{
-			PodIP: "not-an-ip",
-			Phase: corev1.PodRunning,
-		},
+	browser := func(name, ip string) *browserv1.Browser {
+		return &browserv1.Browser{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: now,
+			},
+			Spec: browserv1.BrowserSpec{
+				BrowserName:    "chrome",
+				BrowserVersion: "123",
+			},
+			Status: browserv1.BrowserStatus{
+				PodIP: ip,
+				Phase: corev1.PodRunning,
+			},
+		}
 	}
 
-	cl := &fakeClient{browsers: []*browserv1.Browser{existing}}
-	col := NewCollector(cl, &fakeConfigClient{}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), nil)
+	st := store.NewDefaultStore[*types.Session]()
+	cl := &fakeClient{stream: stream, browsers: []*browserv1.Browser{browser("browser-bad", "not-an-ip"), browser("browser-ok", "127.0.0.1")}}
+	col := NewCollector(cl, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil)
 
 	err := col.Run(context.Background())
-	if err == nil || err.Error() != "failed to convert IP to UUID" {
-		t.Fatalf("expected convert error, got %v", err)
+	if err == nil || err.Error() != "browser event stream closed unexpectedly" {
+		t.Fatalf("expected collector to reach the event loop, got %v", err)
+	}
+	if _, ok := st.Get("default/browser-ok"); !ok {
+		t.Fatalf("expected browser-ok to be stored")
+	}
+	if invalid := col.InvalidBrowsers(); len(invalid) != 1 || invalid[0].BrowserId != "browser-bad" {
+		t.Fatalf("expected browser-bad to be recorded as invalid, got %+v", invalid)
 	}
 }
 
@@ -602,7 +889,7 @@ func TestStoreSessionSetsOwnerFromLabel(t *testing.T) {
 
 	col.Run(context.Background()) //nolint:errcheck
 
-	sess, ok := st.Get("browser-owned")
+	sess, ok := st.Get("default/browser-owned")
 	if !ok {
 		t.Fatal("expected browser-owned to be stored")
 	}
@@ -625,7 +912,7 @@ func TestStoreSessionNoOwnerLabel(t *testing.T) {
 
 	col.Run(context.Background()) //nolint:errcheck
 
-	sess, ok := st.Get("browser-noowner")
+	sess, ok := st.Get("default/browser-noowner")
 	if !ok {
 		t.Fatal("expected browser-noowner to be stored")
 	}
@@ -634,6 +921,128 @@ func TestStoreSessionNoOwnerLabel(t *testing.T) {
 	}
 }
 
+func TestStoreSessionCopiesStatusDetails(t *testing.T) {
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent, 1),
+		errorsCh: make(chan error, 1),
+	}
+	client := &fakeClient{stream: stream}
+	st := store.NewDefaultStore[*types.Session]()
+	col := NewCollector(client, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil)
+
+	transition := metav1.NewTime(time.Unix(100, 0).UTC())
+	ev := newBrowserEvent(event.EventTypeAdded, "browser-1", "127.0.0.1")
+	ev.Browser.Annotations = map[string]string{
+		browserv1.SelenosisOptionsAnnotationKey: `{"labels":{"team":"qa"},"vncPassword":"s3cr3t","env":[{"name":"API_TOKEN","value":"x"}]}`,
+	}
+	ev.Browser.Status.NodeName = "node-a"
+	ev.Browser.Status.Conditions = []metav1.Condition{
+		{Type: "Ready", Status: metav1.ConditionTrue, Reason: "PodReady", LastTransitionTime: transition},
+	}
+	ev.Browser.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name:         "browser",
+			Image:        "selenosis/chrome:123",
+			ImageID:      "sha256:abc",
+			ContainerID:  "containerd://abc",
+			Ready:        true,
+			RestartCount: 2,
+			State:        corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			Resources: &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("500m"),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+			},
+		},
+		{
+			Name:  "seleniferous",
+			Image: "alcounit/seleniferous:v2",
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff", Message: "back-off"}},
+		},
+	}
+	stream.eventsCh <- ev
+	close(stream.eventsCh)
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	sess, ok := st.Get("default/browser-1")
+	if !ok {
+		t.Fatal("expected browser-1 to be stored")
+	}
+	if sess.NodeName != "node-a" {
+		t.Fatalf("expected node-a, got %q", sess.NodeName)
+	}
+	if len(sess.Conditions) != 1 || sess.Conditions[0].Type != "Ready" || sess.Conditions[0].Status != "True" {
+		t.Fatalf("unexpected conditions: %+v", sess.Conditions)
+	}
+	if sess.Conditions[0].LastTransitionTime == nil || !sess.Conditions[0].LastTransitionTime.Equal(&transition) {
+		t.Fatalf("expected lastTransitionTime %v, got %v", transition, sess.Conditions[0].LastTransitionTime)
+	}
+	if len(sess.Containers) != 2 {
+		t.Fatalf("expected 2 containers, got %d", len(sess.Containers))
+	}
+
+	browser := sess.Containers[0]
+	if browser.State != "running" || !browser.Ready || browser.RestartCount != 2 || browser.Image != "selenosis/chrome:123" {
+		t.Fatalf("unexpected browser container: %+v", browser)
+	}
+	if browser.Requests["cpu"] != "500m" || browser.Requests["memory"] != "1Gi" {
+		t.Fatalf("unexpected requests: %+v", browser.Requests)
+	}
+
+	sidecar := sess.Containers[1]
+	if sidecar.State != "waiting" || sidecar.Reason != "CrashLoopBackOff" || sidecar.Message != "back-off" {
+		t.Fatalf("unexpected sidecar container: %+v", sidecar)
+	}
+
+	var opts map[string]any
+	if err := json.Unmarshal(sess.SelenosisOptions, &opts); err != nil {
+		t.Fatalf("expected selenosisOptions JSON, got %s: %v", sess.SelenosisOptions, err)
+	}
+	if opts["vncPassword"] != "***" {
+		t.Fatalf("expected vncPassword to be redacted, got %v", opts["vncPassword"])
+	}
+	if labels, _ := opts["labels"].(map[string]any); labels["team"] != "qa" {
+		t.Fatalf("expected labels to be kept, got %v", opts["labels"])
+	}
+
+	b, err := json.Marshal(sess)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, leaked := range []string{"127.0.0.1", "sha256:abc", "containerd://abc", "s3cr3t"} {
+		if strings.Contains(string(b), leaked) {
+			t.Fatalf("expected %q not to be exposed, got %s", leaked, b)
+		}
+	}
+}
+
+func TestStoreSessionInvalidSelenosisOptions(t *testing.T) {
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent, 1),
+		errorsCh: make(chan error, 1),
+	}
+	client := &fakeClient{stream: stream}
+	st := store.NewDefaultStore[*types.Session]()
+	col := NewCollector(client, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil)
+
+	ev := newBrowserEvent(event.EventTypeAdded, "browser-1", "127.0.0.1")
+	ev.Browser.Annotations = map[string]string{browserv1.SelenosisOptionsAnnotationKey: "{not json"}
+	stream.eventsCh <- ev
+	close(stream.eventsCh)
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	sess, ok := st.Get("default/browser-1")
+	if !ok {
+		t.Fatal("expected browser-1 to be stored")
+	}
+	if sess.SelenosisOptions != nil {
+		t.Fatalf("expected no selenosisOptions, got %s", sess.SelenosisOptions)
+	}
+}
+
 func TestCollectorRunConfigEventsStreamError(t *testing.T) {
 	// configClient.Events returns an error.
 	stream := &fakeStream{
@@ -650,3 +1059,384 @@ func TestCollectorRunConfigEventsStreamError(t *testing.T) {
 		t.Fatalf("expected config events error, got %v", err)
 	}
 }
+
+func TestCollectorRunCountsEvents(t *testing.T) {
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent, 2),
+		errorsCh: make(chan error, 1),
+	}
+	client := &fakeClient{stream: stream}
+	col := NewCollector(client, &fakeConfigClient{}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), nil)
+
+	added := metrics.CollectorEvents.WithLabelValues("browser", "added")
+	deleted := metrics.CollectorEvents.WithLabelValues("browser", "deleted")
+	beforeAdded := testutil.ToFloat64(added)
+	beforeDeleted := testutil.ToFloat64(deleted)
+
+	stream.eventsCh <- newBrowserEvent(event.EventTypeAdded, "browser-1", "127.0.0.1")
+	stream.eventsCh <- newBrowserEvent(event.EventTypeDeleted, "browser-1", "")
+	close(stream.eventsCh)
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	if got := testutil.ToFloat64(added) - beforeAdded; got != 1 {
+		t.Fatalf("expected 1 added event, got %v", got)
+	}
+	if got := testutil.ToFloat64(deleted) - beforeDeleted; got != 1 {
+		t.Fatalf("expected 1 deleted event, got %v", got)
+	}
+}
+
+func TestCollectorRunCountsReconnects(t *testing.T) {
+	cl := &fakeClient{listErr: errors.New("list error")}
+	col := NewCollector(cl, &fakeConfigClient{}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), nil)
+
+	before := testutil.ToFloat64(metrics.CollectorReconnects)
+	col.Run(context.Background()) //nolint:errcheck
+	col.Run(context.Background()) //nolint:errcheck
+	col.Run(context.Background()) //nolint:errcheck
+
+	if got := testutil.ToFloat64(metrics.CollectorReconnects) - before; got != 2 {
+		t.Fatalf("expected 2 reconnects, got %v", got)
+	}
+}
+
+func TestCollectorCheckNotReadyBeforeRun(t *testing.T) {
+	col := NewCollector(&fakeClient{}, &fakeConfigClient{}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), nil)
+
+	if res := col.Check(context.Background()); res.Ready {
+		t.Fatalf("expected collector to be not ready before Run")
+	}
+}
+
+func TestCollectorCheckReadyWhileStreaming(t *testing.T) {
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent, 1),
+		errorsCh: make(chan error, 1),
+	}
+	client := &fakeClient{stream: stream}
+	col := NewCollector(client, &fakeConfigClient{}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- col.Run(ctx) }()
+
+	stream.eventsCh <- newBrowserEvent(event.EventTypeAdded, "browser-1", "127.0.0.1")
+
+	deadline := time.After(time.Second)
+	for {
+		res := col.Check(context.Background())
+		if res.Ready && res.Details["lastEventTime"] != nil {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("expected collector to become ready, got %+v", res)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	<-done
+
+	res := col.Check(context.Background())
+	if res.Ready {
+		t.Fatalf("expected collector to be not ready after Run returned")
+	}
+	if res.Details["lastError"] != context.Canceled.Error() {
+		t.Fatalf("expected lastError in details, got %+v", res.Details)
+	}
+}
+
+// recordingBroadcaster records published events; other Broadcaster methods are unused.
+type recordingBroadcaster struct {
+	broadcast.Broadcaster[event.BrowserEvent]
+	events []event.BrowserEvent
+}
+
+func (b *recordingBroadcaster) Broadcast(ev event.BrowserEvent) {
+	b.events = append(b.events, ev)
+}
+
+func TestCollectorRunPrunesSessionsDeletedWhileDisconnected(t *testing.T) {
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent),
+		errorsCh: make(chan error),
+	}
+	close(stream.eventsCh)
+
+	now := metav1.NewTime(time.Unix(0, 0).UTC())
+	live := &browserv1.Browser{
+		ObjectMeta: metav1.ObjectMeta{Name: "browser-live", CreationTimestamp: now},
+		Spec:       browserv1.BrowserSpec{BrowserName: "chrome", BrowserVersion: "123"},
+		Status:     browserv1.BrowserStatus{PodIP: "127.0.0.1", Phase: corev1.PodRunning},
+	}
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("default/browser-live", &types.Session{Namespace: "default", BrowserId: "browser-live"})
+	st.Set("default/browser-gone", &types.Session{Namespace: "default", BrowserId: "browser-gone", BrowserName: "firefox", BrowserVersion: "100"})
+
+	rec := &recordingRecorder{}
+	cl := &fakeClient{stream: stream, browsers: []*browserv1.Browser{live}}
+	col := NewCollector(cl, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil, WithRecorder(rec))
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	if _, ok := st.Get("default/browser-gone"); ok {
+		t.Fatalf("expected browser-gone to be pruned")
+	}
+	if _, ok := st.Get("default/browser-live"); !ok {
+		t.Fatalf("expected browser-live to stay in store")
+	}
+
+	var deleted []history.SessionEvent
+	for _, ev := range rec.events {
+		if ev.Type == history.SessionDeleted {
+			deleted = append(deleted, ev)
+		}
+	}
+	if len(deleted) != 1 || deleted[0].Session.BrowserId != "browser-gone" {
+		t.Fatalf("expected browser-gone to be recorded as deleted, got %+v", deleted)
+	}
+	if deleted[0].Session.BrowserName != "firefox" {
+		t.Fatalf("expected the deleted record to carry the browser name, got %q", deleted[0].Session.BrowserName)
+	}
+}
+
+func TestCollectorRunPrunesConfigsDeletedWhileDisconnected(t *testing.T) {
+	newStream := func() *fakeStream {
+		s := &fakeStream{
+			eventsCh: make(chan *event.BrowserEvent),
+			errorsCh: make(chan error),
+		}
+		close(s.eventsCh)
+		return s
+	}
+
+	cfg := func(name string) *browserconfigv1.BrowserConfig {
+		return &browserconfigv1.BrowserConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: browserconfigv1.BrowserConfigSpec{
+				Browsers: map[string]map[string]*browserconfigv1.BrowserVersionConfigSpec{
+					"chrome": {"123": {Image: "chrome:123"}},
+				},
+			},
+		}
+	}
+
+	cl := &fakeClient{stream: newStream()}
+	cfgClient := &fakeConfigClient{listData: []*browserconfigv1.BrowserConfig{cfg("cfg-keep"), cfg("cfg-gone")}}
+	cfgStore := store.NewDefaultStore[types.BrowserVersions]()
+	col := NewCollector(cl, cfgClient, "default", store.NewDefaultStore[*types.Session](), cfgStore, nil)
+
+	col.Run(context.Background()) //nolint:errcheck
+	if cfgStore.Len() != 2 {
+		t.Fatalf("expected 2 configs after first run, got %d", cfgStore.Len())
+	}
+
+	// cfg-gone was deleted while the stream was down: the next list no longer has it.
+	cl.stream = newStream()
+	cfgClient.listData = []*browserconfigv1.BrowserConfig{cfg("cfg-keep")}
+	col.Run(context.Background()) //nolint:errcheck
+
+	if _, ok := cfgStore.Get("default/cfg-gone"); ok {
+		t.Fatalf("expected cfg-gone to be pruned")
+	}
+	if _, ok := cfgStore.Get("default/cfg-keep"); !ok {
+		t.Fatalf("expected cfg-keep to stay in store")
+	}
+}
+
+func TestCollectorRunPublishesStreamEvents(t *testing.T) {
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent, 1),
+		errorsCh: make(chan error, 1),
+	}
+	bc := &recordingBroadcaster{}
+	col := NewCollector(&fakeClient{stream: stream}, &fakeConfigClient{}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), bc)
+
+	stream.eventsCh <- newBrowserEvent(event.EventTypeAdded, "browser-1", "127.0.0.1")
+	close(stream.eventsCh)
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	if len(bc.events) != 1 || bc.events[0].Browser.Name != "browser-1" {
+		t.Fatalf("expected added event for browser-1 to be published, got %+v", bc.events)
+	}
+}
+
+type recordingRecorder struct {
+	events []history.SessionEvent
+}
+
+func (r *recordingRecorder) RecordSession(_ context.Context, ev history.SessionEvent) {
+	r.events = append(r.events, ev)
+}
+
+func (r *recordingRecorder) RecordAction(context.Context, history.Action) {}
+
+func (r *recordingRecorder) types() []history.SessionEventType {
+	result := make([]history.SessionEventType, 0, len(r.events))
+	for _, ev := range r.events {
+		result = append(result, ev.Type)
+	}
+	return result
+}
+
+func TestCollectorRecordsSessionLifecycle(t *testing.T) {
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent, 4),
+		errorsCh: make(chan error, 1),
+	}
+	rec := &recordingRecorder{}
+	col := NewCollector(&fakeClient{stream: stream}, &fakeConfigClient{}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), nil, WithRecorder(rec))
+
+	pending := newBrowserEvent(event.EventTypeAdded, "browser-1", "")
+	pending.Browser.Status.Phase = corev1.PodPending
+	stream.eventsCh <- pending
+	stream.eventsCh <- newBrowserEvent(event.EventTypeModified, "browser-1", "127.0.0.1")
+	stream.eventsCh <- newBrowserEvent(event.EventTypeModified, "browser-1", "127.0.0.1")
+	stream.eventsCh <- newBrowserEvent(event.EventTypeDeleted, "browser-1", "127.0.0.1")
+	close(stream.eventsCh)
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	got := rec.types()
+	want := []history.SessionEventType{history.SessionCreated, history.SessionRunning, history.SessionDeleted}
+	if len(got) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected events %v, got %v", want, got)
+		}
+	}
+	if rec.events[2].Session.BrowserName != "chrome" {
+		t.Fatalf("expected deleted event to carry the stored session, got %+v", rec.events[2].Session)
+	}
+}
+
+func TestCollectorRecordsPrunedSessionAsDeleted(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("default/browser-gone", &types.Session{Namespace: "default", BrowserId: "browser-gone", BrowserName: "chrome"})
+
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent),
+		errorsCh: make(chan error, 1),
+	}
+	close(stream.eventsCh)
+	rec := &recordingRecorder{}
+	col := NewCollector(&fakeClient{stream: stream}, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil, WithRecorder(rec))
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	if len(rec.events) != 1 || rec.events[0].Type != history.SessionDeleted || rec.events[0].Session.BrowserId != "browser-gone" {
+		t.Fatalf("expected a deleted event for browser-gone, got %+v", rec.events)
+	}
+}
+
+func TestCollectorRecordsFailedSession(t *testing.T) {
+	stream := &fakeStream{
+		eventsCh: make(chan *event.BrowserEvent, 2),
+		errorsCh: make(chan error, 1),
+	}
+	rec := &recordingRecorder{}
+	col := NewCollector(&fakeClient{stream: stream}, &fakeConfigClient{}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), nil, WithRecorder(rec))
+
+	failed := newBrowserEvent(event.EventTypeModified, "browser-1", "")
+	failed.Browser.Status.Phase = corev1.PodFailed
+	stream.eventsCh <- failed
+	stream.eventsCh <- failed
+	close(stream.eventsCh)
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	got := rec.types()
+	if len(got) != 2 || got[0] != history.SessionCreated || got[1] != history.SessionFailed {
+		t.Fatalf("expected created and a single failed event, got %v", got)
+	}
+}
+
+// namespacedClient serves a separate browser list and stream per namespace.
+type namespacedClient struct {
+	fakeClient
+	streams  map[string]*fakeStream
+	browsers map[string][]*browserv1.Browser
+}
+
+func (c *namespacedClient) Events(ctx context.Context, namespace string, opts ...event.EventsOption) (browserclient.EventStream, error) {
+	return c.streams[namespace], nil
+}
+
+func (c *namespacedClient) List(_ context.Context, namespace string) ([]*browserv1.Browser, error) {
+	return c.browsers[namespace], nil
+}
+
+func TestCollectorRunWatchesEveryNamespace(t *testing.T) {
+	teamA := &fakeStream{eventsCh: make(chan *event.BrowserEvent, 1), errorsCh: make(chan error)}
+	teamB := &fakeStream{eventsCh: make(chan *event.BrowserEvent), errorsCh: make(chan error)}
+	teamA.eventsCh <- newBrowserEvent(event.EventTypeAdded, "browser-2", "127.0.0.3")
+	close(teamA.eventsCh)
+
+	cl := &namespacedClient{
+		streams: map[string]*fakeStream{"team-a": teamA, "team-b": teamB},
+		browsers: map[string][]*browserv1.Browser{
+			"team-a": {newBrowserEvent(event.EventTypeAdded, "browser-1", "127.0.0.1").Browser},
+			"team-b": {newBrowserEvent(event.EventTypeAdded, "browser-1", "127.0.0.2").Browser},
+		},
+	}
+	st := store.NewDefaultStore[*types.Session]()
+	col := NewCollector(cl, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil, WithNamespaces("team-a", "team-b"))
+
+	err := col.Run(context.Background())
+	if err == nil || err.Error() != "browser event stream closed unexpectedly" {
+		t.Fatalf("expected stream closed error, got %v", err)
+	}
+
+	a, ok := st.Get("team-a/browser-1")
+	if !ok {
+		t.Fatalf("expected team-a/browser-1 to be stored")
+	}
+	b, ok := st.Get("team-b/browser-1")
+	if !ok {
+		t.Fatalf("expected team-b/browser-1 to be stored")
+	}
+	if a.SessionId == b.SessionId {
+		t.Fatalf("expected distinct sessions for the same name in two namespaces, got %s", a.SessionId)
+	}
+	if a.Namespace != "team-a" || b.Namespace != "team-b" {
+		t.Fatalf("expected namespaces team-a and team-b, got %q and %q", a.Namespace, b.Namespace)
+	}
+	if _, ok := st.Get("team-a/browser-2"); !ok {
+		t.Fatalf("expected event from team-a stream to be stored")
+	}
+	if _, ok := st.Get("default/browser-1"); ok {
+		t.Fatalf("expected default namespace not to be watched")
+	}
+	if !teamA.closed || !teamB.closed {
+		t.Fatalf("expected both streams to be closed")
+	}
+}
+
+func TestCollectorWithClusterTagsSessionsAndKeepsOtherClusters(t *testing.T) {
+	stream := &fakeStream{eventsCh: make(chan *event.BrowserEvent, 1), errorsCh: make(chan error)}
+	stream.eventsCh <- newBrowserEvent(event.EventTypeAdded, "browser-1", "127.0.0.1")
+	close(stream.eventsCh)
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("us/default/browser-1", &types.Session{Cluster: "us", Namespace: "default", BrowserId: "browser-1"})
+	col := NewCollector(&fakeClient{stream: stream}, &fakeConfigClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), nil, WithCluster("eu"))
+
+	col.Run(context.Background()) //nolint:errcheck
+
+	sess, ok := st.Get("eu/default/browser-1")
+	if !ok {
+		t.Fatalf("expected eu/default/browser-1 to be stored")
+	}
+	if sess.Cluster != "eu" {
+		t.Fatalf("expected cluster eu, got %q", sess.Cluster)
+	}
+	if _, ok := st.Get("us/default/browser-1"); !ok {
+		t.Fatalf("expected session of another cluster not to be pruned")
+	}
+}
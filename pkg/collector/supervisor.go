@@ -0,0 +1,197 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/alcounit/browser-ui/pkg/health"
+)
+
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type Backoff struct {
+	// Initial is the delay before the first restart.
+	Initial time.Duration
+	// Max caps the delay, jitter included.
+	Max time.Duration
+	// Multiplier grows the delay after every consecutive failure.
+	Multiplier float64
+	// Jitter randomizes each delay by up to ±Jitter of its value (0..1).
+	Jitter float64
+	// ResetAfter restores the initial delay once a run stayed up at least this long.
+	ResetAfter time.Duration
+}
+
+var DefaultBackoff = Backoff{
+	Initial:    time.Second,
+	Max:        time.Minute,
+	Multiplier: 2,
+	Jitter:     0.2,
+	ResetAfter: time.Minute,
+}
+
+// base returns the un-jittered delay for attempt, clamped to Max before any
+// jitter is applied so huge attempts can never overflow into Inf or NaN.
+func (b Backoff) base(attempt int) float64 {
+	d := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt))
+	if math.IsNaN(d) || math.IsInf(d, 0) || (b.Max > 0 && d > float64(b.Max)) {
+		d = float64(b.Max)
+	}
+	return d
+}
+
+// capped reports whether attempt already reaches Max, so growing it further
+// cannot change the delay.
+func (b Backoff) capped(attempt int) bool {
+	return b.Max > 0 && b.base(attempt) >= float64(b.Max)
+}
+
+func (b Backoff) delay(attempt int, r float64) time.Duration {
+	d := b.base(attempt)
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (2*r - 1)
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	if d < 0 {
+		d = 0
+	}
+	return time.Duration(d)
+}
+
+type SupervisorStats struct {
+	Running             bool
+	Restarts            int
+	ConsecutiveFailures int
+	LastStart           time.Time
+	LastFailure         time.Time
+	LastError           string
+	NextDelay           time.Duration
+}
+
+// Supervisor keeps restarting fn with exponential backoff until it returns nil
+// or the context is cancelled.
+type Supervisor struct {
+	fn      func(context.Context) error
+	backoff Backoff
+	clock   Clock
+	rand    func() float64
+
+	mu    sync.RWMutex
+	stats SupervisorStats
+}
+
+type SupervisorOption func(*Supervisor)
+
+func WithClock(clock Clock) SupervisorOption {
+	return func(s *Supervisor) { s.clock = clock }
+}
+
+func WithRand(rand func() float64) SupervisorOption {
+	return func(s *Supervisor) { s.rand = rand }
+}
+
+func NewSupervisor(fn func(context.Context) error, backoff Backoff, opts ...SupervisorOption) *Supervisor {
+	s := &Supervisor{
+		fn:      fn,
+		backoff: backoff,
+		clock:   realClock{},
+		rand:    rand.Float64,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Supervisor) Run(ctx context.Context) error {
+	log := logctx.FromContext(ctx)
+
+	s.mu.Lock()
+	s.stats.Running = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.stats.Running = false
+		s.stats.NextDelay = 0
+		s.mu.Unlock()
+	}()
+
+	attempt, failures := 0, 0
+	for {
+		start := s.clock.Now()
+		s.mu.Lock()
+		s.stats.LastStart = start
+		s.mu.Unlock()
+
+		err := s.fn(ctx)
+		if err == nil || ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		now := s.clock.Now()
+		if s.backoff.ResetAfter > 0 && now.Sub(start) >= s.backoff.ResetAfter {
+			attempt, failures = 0, 0
+		}
+		delay := s.backoff.delay(attempt, s.rand())
+		if !s.backoff.capped(attempt) {
+			attempt++
+		}
+		failures++
+
+		s.mu.Lock()
+		s.stats.Restarts++
+		s.stats.ConsecutiveFailures = failures
+		s.stats.LastFailure = now
+		s.stats.LastError = err.Error()
+		s.stats.NextDelay = delay
+		s.mu.Unlock()
+
+		log.Error().Err(err).Dur("retryIn", delay).Int("attempt", failures).Msg("event collector failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock.After(delay):
+		}
+	}
+}
+
+func (s *Supervisor) Stats() SupervisorStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stats
+}
+
+// Check reports the supervisor as ready while its restart loop is active.
+func (s *Supervisor) Check(context.Context) health.Result {
+	stats := s.Stats()
+
+	details := map[string]any{
+		"restarts":            stats.Restarts,
+		"consecutiveFailures": stats.ConsecutiveFailures,
+	}
+	if stats.LastError != "" {
+		details["lastError"] = stats.LastError
+		details["lastFailure"] = stats.LastFailure.Format(time.RFC3339)
+	}
+	if stats.NextDelay > 0 {
+		details["nextDelay"] = stats.NextDelay.String()
+	}
+
+	return health.Result{Ready: stats.Running, Details: details}
+}
@@ -0,0 +1,232 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	delays  []time.Duration
+	waiters []chan time.Time
+	waiting chan struct{}
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:     time.Unix(0, 0),
+		waiting: make(chan struct{}, 16),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.delays = append(c.delays, d)
+	c.waiters = append(c.waiters, ch)
+	c.waiting <- struct{}{}
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, ch := range c.waiters {
+		ch <- c.now
+	}
+	c.waiters = nil
+}
+
+func (c *fakeClock) Delays() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.delays...)
+}
+
+func (c *fakeClock) awaitSleep(t *testing.T) {
+	t.Helper()
+	select {
+	case <-c.waiting:
+	case <-time.After(time.Second):
+		t.Fatalf("timeout waiting for supervisor to sleep")
+	}
+}
+
+func TestBackoffDelayGrowsAndCaps(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 10 * time.Second, Multiplier: 2}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for attempt, want := range expected {
+		if got := b.delay(attempt, 0.5); got != want {
+			t.Fatalf("attempt %d: expected %v, got %v", attempt, want, got)
+		}
+	}
+}
+
+func TestBackoffDelayJitter(t *testing.T) {
+	b := Backoff{Initial: 10 * time.Second, Max: time.Minute, Multiplier: 2, Jitter: 0.2}
+
+	if got := b.delay(0, 0); got != 8*time.Second {
+		t.Fatalf("expected lower bound 8s, got %v", got)
+	}
+	if got := b.delay(0, 1); got != 12*time.Second {
+		t.Fatalf("expected upper bound 12s, got %v", got)
+	}
+	if got := b.delay(10, 1); got != time.Minute {
+		t.Fatalf("expected jittered delay to be capped at 1m, got %v", got)
+	}
+}
+
+func TestBackoffDelayLargeAttemptStaysBounded(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2, Jitter: 0.2}
+
+	for _, attempt := range []int{1024, 5000, math.MaxInt32} {
+		for _, r := range []float64{0, 0.5, 1} {
+			got := b.delay(attempt, r)
+			if got < 0 || got > b.Max {
+				t.Fatalf("attempt %d r %v: expected delay in [0, %v], got %v", attempt, r, b.Max, got)
+			}
+		}
+	}
+	if !b.capped(6) || b.capped(5) {
+		t.Fatalf("expected backoff to be capped from attempt 6 onwards")
+	}
+}
+
+func TestSupervisorBacksOffExponentially(t *testing.T) {
+	clock := newFakeClock()
+	calls := 0
+	fn := func(context.Context) error {
+		calls++
+		if calls == 4 {
+			return nil
+		}
+		return errors.New("boom")
+	}
+
+	sup := NewSupervisor(fn, Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2}, WithClock(clock), WithRand(func() float64 { return 0.5 }))
+
+	done := make(chan error, 1)
+	go func() { done <- sup.Run(context.Background()) }()
+
+	for i := 0; i < 3; i++ {
+		clock.awaitSleep(t)
+		clock.Advance(time.Hour)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	delays := clock.Delays()
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	if len(delays) != len(expected) {
+		t.Fatalf("expected %d sleeps, got %v", len(expected), delays)
+	}
+	for i := range expected {
+		if delays[i] != expected[i] {
+			t.Fatalf("sleep %d: expected %v, got %v", i, expected[i], delays[i])
+		}
+	}
+
+	stats := sup.Stats()
+	if stats.Restarts != 3 {
+		t.Fatalf("expected 3 restarts, got %d", stats.Restarts)
+	}
+	if stats.Running {
+		t.Fatalf("expected supervisor to be stopped")
+	}
+}
+
+func TestSupervisorResetsAfterHealthyRun(t *testing.T) {
+	clock := newFakeClock()
+	calls := 0
+	fn := func(context.Context) error {
+		calls++
+		switch calls {
+		case 3:
+			// Stay up long enough to reset the backoff.
+			clock.mu.Lock()
+			clock.now = clock.now.Add(2 * time.Minute)
+			clock.mu.Unlock()
+		case 4:
+			return nil
+		}
+		return errors.New("boom")
+	}
+
+	b := Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2, ResetAfter: time.Minute}
+	sup := NewSupervisor(fn, b, WithClock(clock), WithRand(func() float64 { return 0.5 }))
+
+	done := make(chan error, 1)
+	go func() { done <- sup.Run(context.Background()) }()
+
+	for i := 0; i < 3; i++ {
+		clock.awaitSleep(t)
+		if i == 1 {
+			if got := sup.Stats().ConsecutiveFailures; got != 2 {
+				t.Fatalf("expected 2 consecutive failures, got %d", got)
+			}
+		}
+		clock.Advance(time.Second)
+	}
+	<-done
+
+	delays := clock.Delays()
+	expected := []time.Duration{time.Second, 2 * time.Second, time.Second}
+	for i := range expected {
+		if delays[i] != expected[i] {
+			t.Fatalf("sleep %d: expected %v, got %v", i, expected[i], delays[i])
+		}
+	}
+	if got := sup.Stats().ConsecutiveFailures; got != 1 {
+		t.Fatalf("expected consecutive failures to reset to 1, got %d", got)
+	}
+}
+
+func TestSupervisorStopsOnContextCancel(t *testing.T) {
+	clock := newFakeClock()
+	fn := func(context.Context) error { return errors.New("boom") }
+
+	sup := NewSupervisor(fn, DefaultBackoff, WithClock(clock))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- sup.Run(ctx) }()
+
+	clock.awaitSleep(t)
+	if res := sup.Check(context.Background()); !res.Ready {
+		t.Fatalf("expected supervisor to be ready while retrying")
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("supervisor did not stop on context cancel")
+	}
+
+	res := sup.Check(context.Background())
+	if res.Ready {
+		t.Fatalf("expected supervisor to be not ready after stop")
+	}
+	if res.Details["lastError"] != "boom" {
+		t.Fatalf("expected lastError boom, got %+v", res.Details)
+	}
+}
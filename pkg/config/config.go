@@ -0,0 +1,581 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/cluster"
+	"github.com/alcounit/browser-ui/pkg/csrf"
+	"github.com/alcounit/browser-ui/pkg/proxyauth"
+	"github.com/alcounit/browser-ui/pkg/webhook"
+	"github.com/rs/zerolog"
+	"sigs.k8s.io/yaml"
+)
+
+// Duration is a time.Duration written as a Go duration string, e.g. "90s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Config is the complete browser-ui configuration. Fields tagged
+// reload:"true" take effect when the file is reloaded; the others need a
+// restart.
+type Config struct {
+	ListenAddr string `json:"listenAddr"`
+	StaticPath string `json:"staticPath"`
+	LogLevel   string `json:"logLevel" reload:"true"`
+
+	// Clusters are the browser-service backends; the first one is the default.
+	Clusters              []cluster.Endpoint `json:"clusters"`
+	Namespace             string             `json:"namespace"`
+	WatchNamespaces       []string           `json:"watchNamespaces,omitempty"`
+	CreateNamespaces      []string           `json:"createNamespaces,omitempty"`
+	BrowserStartupTimeout Duration           `json:"browserStartupTimeout" reload:"true"`
+
+	Server    ServerConfig    `json:"server"`
+	Collector CollectorConfig `json:"collector"`
+	Auth      AuthConfig      `json:"auth"`
+	Tokens    TokensConfig    `json:"tokens"`
+	Share     ShareConfig     `json:"share"`
+	VNC       VNCConfig       `json:"vnc"`
+	RateLimit RateLimitConfig `json:"rateLimit"`
+	History   HistoryConfig   `json:"history"`
+	Webhooks  WebhooksConfig  `json:"webhooks"`
+	Audit     AuditConfig     `json:"audit"`
+}
+
+type ServerConfig struct {
+	ReadHeaderTimeout Duration `json:"readHeaderTimeout"`
+	ReadTimeout       Duration `json:"readTimeout"`
+	// WriteTimeout is off by default: creating a browser waits up to
+	// browserStartupTimeout before the response is written.
+	WriteTimeout Duration `json:"writeTimeout"`
+	IdleTimeout  Duration `json:"idleTimeout"`
+	// ShutdownDelay is how long the server keeps serving after it reports
+	// not ready on shutdown, so that load balancers move traffic elsewhere.
+	ShutdownDelay Duration `json:"shutdownDelay"`
+	// ShutdownTimeout bounds draining requests and VNC connections after
+	// ShutdownDelay.
+	ShutdownTimeout Duration `json:"shutdownTimeout"`
+	// HTTP2 is negotiated over TLS only; WebSockets keep using HTTP/1.1.
+	HTTP2 bool      `json:"http2"`
+	TLS   TLSConfig `json:"tls"`
+	// AllowedOrigins may open VNC websockets and send mutating API requests
+	// in addition to the UI's own origin; "*" allows any origin.
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+}
+
+// TLSConfig enables HTTPS when CertFile and KeyFile are set. The pair is
+// reloaded when the files change.
+type TLSConfig struct {
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// ClientCAFile enables mTLS: client certificates must be signed by one of
+	// these CAs, always with "require" or when presented with "optional".
+	ClientCAFile string `json:"clientCAFile,omitempty"`
+	ClientAuth   string `json:"clientAuth,omitempty"`
+}
+
+// Enabled reports whether the server should listen with TLS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+type CollectorConfig struct {
+	BackoffInitial Duration `json:"backoffInitial"`
+	BackoffMax     Duration `json:"backoffMax"`
+	BackoffReset   Duration `json:"backoffReset"`
+}
+
+type AuthConfig struct {
+	BasicAuthFile string          `json:"basicAuthFile,omitempty"`
+	Proxy         ProxyAuthConfig `json:"proxy"`
+	// Admins and AdminGroups name the users, and the proxy groups, allowed to
+	// see and force-close everyone's VNC connections.
+	Admins      []string `json:"admins,omitempty"`
+	AdminGroups []string `json:"adminGroups,omitempty"`
+}
+
+// ProxyAuthConfig trusts the user name an authenticating reverse proxy sends,
+// but only on connections from TrustedProxies. Setting TrustedProxies turns
+// it on.
+type ProxyAuthConfig struct {
+	TrustedProxies []string `json:"trustedProxies,omitempty"`
+	UserHeader     string   `json:"userHeader"`
+	GroupsHeader   string   `json:"groupsHeader"`
+	// AllowedGroups, when set, admits only users in one of these groups.
+	AllowedGroups []string `json:"allowedGroups,omitempty"`
+	// LogoutURL is where the UI sends users to sign out of the proxy.
+	LogoutURL string `json:"logoutURL,omitempty"`
+}
+
+// Enabled reports whether proxy authentication is on.
+func (c ProxyAuthConfig) Enabled() bool {
+	return len(c.TrustedProxies) > 0
+}
+
+// TokensConfig controls API tokens, which are available when auth is on.
+// Without DBPath tokens are kept in memory and lost on restart.
+type TokensConfig struct {
+	DBPath     string   `json:"dbPath,omitempty"`
+	DefaultTTL Duration `json:"defaultTTL"`
+	MaxTTL     Duration `json:"maxTTL"`
+}
+
+// ShareConfig bounds the lifetime of shared VNC links. Links are kept in
+// memory and stop working on restart.
+type ShareConfig struct {
+	DefaultTTL Duration `json:"defaultTTL"`
+	MaxTTL     Duration `json:"maxTTL"`
+}
+
+type VNCConfig struct {
+	// PasswordsFile and Passwords hold VNC passwords the proxy answers
+	// authentication with, so users connect without knowing them. Keys are
+	// "name@version", "name" or "*"; passwords from PasswordsFile, a JSON
+	// object reloaded on change, win over Passwords. BrowserConfig
+	// annotations are consulted last.
+	PasswordsFile string            `json:"passwordsFile,omitempty"`
+	Passwords     map[string]string `json:"passwords,omitempty"`
+
+	// WriteTimeout is how long a message may take to reach the viewer or the
+	// browser before the connection is closed as stuck.
+	WriteTimeout Duration `json:"writeTimeout"`
+	// Both sides are pinged every PingInterval and dropped after PongTimeout
+	// without any message.
+	PingInterval Duration `json:"pingInterval"`
+	PongTimeout  Duration `json:"pongTimeout"`
+	// QueueSize messages are buffered per direction before reading pauses.
+	QueueSize        int  `json:"queueSize"`
+	MaxMessageSizeMB int  `json:"maxMessageSizeMB"`
+	Compression      bool `json:"compression"`
+}
+
+type RateLimitConfig struct {
+	Login    LoginLimitConfig   `json:"login"`
+	Browsers RequestLimitConfig `json:"browsers"`
+	// TrustedProxies are the proxies whose X-Forwarded-For names the client
+	// IP that lockouts and limits are keyed by; auth.proxy.trustedProxies
+	// is used when empty.
+	TrustedProxies []string `json:"trustedProxies,omitempty"`
+}
+
+// ClientIPProxies returns the proxies trusted to report the client IP.
+func (c *Config) ClientIPProxies() []string {
+	if len(c.RateLimit.TrustedProxies) > 0 {
+		return c.RateLimit.TrustedProxies
+	}
+	return c.Auth.Proxy.TrustedProxies
+}
+
+// LoginLimitConfig locks out an IP or user name after too many failed
+// logins. Each further failure doubles the lockout, up to MaxLockout.
+type LoginLimitConfig struct {
+	MaxFailuresPerIP   int      `json:"maxFailuresPerIP"`
+	MaxFailuresPerUser int      `json:"maxFailuresPerUser"`
+	Lockout            Duration `json:"lockout"`
+	MaxLockout         Duration `json:"maxLockout"`
+}
+
+// RequestLimitConfig allows Requests per Window for each user, or each client
+// IP without auth; 0 requests disables the limit.
+type RequestLimitConfig struct {
+	Requests int      `json:"requests"`
+	Window   Duration `json:"window"`
+}
+
+type HistoryConfig struct {
+	DBPath    string   `json:"dbPath,omitempty"`
+	Retention Duration `json:"retention" reload:"true"`
+}
+
+type WebhooksConfig struct {
+	// File is a JSON array of hooks, loaded in addition to Hooks.
+	File           string         `json:"file,omitempty"`
+	Hooks          []webhook.Hook `json:"hooks,omitempty"`
+	MaxAttempts    int            `json:"maxAttempts"`
+	RetryInitial   Duration       `json:"retryInitial"`
+	RetryMax       Duration       `json:"retryMax"`
+	DeadLetterFile string         `json:"deadLetterFile,omitempty"`
+}
+
+type AuditConfig struct {
+	Sinks   []string           `json:"sinks,omitempty"`
+	File    AuditFileConfig    `json:"file"`
+	Webhook AuditWebhookConfig `json:"webhook"`
+}
+
+type AuditFileConfig struct {
+	Path       string `json:"path,omitempty"`
+	MaxSizeMB  int    `json:"maxSizeMB"`
+	MaxBackups int    `json:"maxBackups"`
+}
+
+type AuditWebhookConfig struct {
+	URL     string   `json:"url,omitempty"`
+	Timeout Duration `json:"timeout"`
+}
+
+// Default returns the configuration used when neither a file nor environment
+// variables set a value.
+func Default() *Config {
+	return &Config{
+		ListenAddr:            ":8080",
+		StaticPath:            "/app/static",
+		LogLevel:              "info",
+		Clusters:              []cluster.Endpoint{{URL: "http://browser-service:8080"}},
+		Namespace:             "default",
+		BrowserStartupTimeout: Duration(3 * time.Minute),
+		Server: ServerConfig{
+			ReadHeaderTimeout: Duration(10 * time.Second),
+			ReadTimeout:       Duration(time.Minute),
+			IdleTimeout:       Duration(2 * time.Minute),
+			ShutdownDelay:     Duration(5 * time.Second),
+			ShutdownTimeout:   Duration(20 * time.Second),
+			HTTP2:             true,
+			TLS:               TLSConfig{ClientAuth: "require"},
+		},
+		Collector: CollectorConfig{
+			BackoffInitial: Duration(time.Second),
+			BackoffMax:     Duration(time.Minute),
+			BackoffReset:   Duration(time.Minute),
+		},
+		Auth: AuthConfig{
+			Proxy: ProxyAuthConfig{UserHeader: "X-Forwarded-User", GroupsHeader: "X-Forwarded-Groups"},
+		},
+		Tokens: TokensConfig{
+			DefaultTTL: Duration(30 * 24 * time.Hour),
+			MaxTTL:     Duration(365 * 24 * time.Hour),
+		},
+		Share: ShareConfig{
+			DefaultTTL: Duration(time.Hour),
+			MaxTTL:     Duration(24 * time.Hour),
+		},
+		VNC: VNCConfig{
+			WriteTimeout:     Duration(10 * time.Second),
+			PingInterval:     Duration(30 * time.Second),
+			PongTimeout:      Duration(75 * time.Second),
+			QueueSize:        64,
+			MaxMessageSizeMB: 32,
+		},
+		RateLimit: RateLimitConfig{
+			Login: LoginLimitConfig{
+				MaxFailuresPerIP:   20,
+				MaxFailuresPerUser: 5,
+				Lockout:            Duration(time.Minute),
+				MaxLockout:         Duration(time.Hour),
+			},
+			Browsers: RequestLimitConfig{Requests: 30, Window: Duration(time.Minute)},
+		},
+		History: HistoryConfig{
+			Retention: Duration(30 * 24 * time.Hour),
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts:  webhook.DefaultRetry.MaxAttempts,
+			RetryInitial: Duration(webhook.DefaultRetry.Initial),
+			RetryMax:     Duration(webhook.DefaultRetry.Max),
+		},
+		Audit: AuditConfig{
+			File:    AuditFileConfig{MaxSizeMB: 100, MaxBackups: 5},
+			Webhook: AuditWebhookConfig{Timeout: Duration(5 * time.Second)},
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the YAML or JSON file at
+// path (skipped when empty) and the environment, in that order, and validates
+// the result.
+func Load(path string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(raw, cfg); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(cfg, lookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+type envVar struct {
+	key string
+	set func(cfg *Config, value string) error
+}
+
+// envVars are the environment variables that override file values.
+var envVars = []envVar{
+	{"LISTEN_ADDR", func(c *Config, v string) error { c.ListenAddr = v; return nil }},
+	{"UI_STATIC_PATH", func(c *Config, v string) error { c.StaticPath = v; return nil }},
+	{"LOG_LEVEL", func(c *Config, v string) error { c.LogLevel = v; return nil }},
+	{"BROWSER_SERVICE_URL", func(c *Config, v string) (err error) {
+		c.Clusters, err = cluster.ParseEndpoints(v)
+		return err
+	}},
+	{"BROWSER_NAMESPACE", func(c *Config, v string) error { c.Namespace = v; return nil }},
+	{"BROWSER_NAMESPACES", func(c *Config, v string) error { c.WatchNamespaces = splitList(v); return nil }},
+	{"BROWSER_CREATE_NAMESPACES", func(c *Config, v string) error { c.CreateNamespaces = splitList(v); return nil }},
+	{"BROWSER_STARTUP_TIMEOUT", durationVar(func(c *Config) *Duration { return &c.BrowserStartupTimeout })},
+	{"SERVER_READ_HEADER_TIMEOUT", durationVar(func(c *Config) *Duration { return &c.Server.ReadHeaderTimeout })},
+	{"SERVER_READ_TIMEOUT", durationVar(func(c *Config) *Duration { return &c.Server.ReadTimeout })},
+	{"SERVER_WRITE_TIMEOUT", durationVar(func(c *Config) *Duration { return &c.Server.WriteTimeout })},
+	{"SERVER_IDLE_TIMEOUT", durationVar(func(c *Config) *Duration { return &c.Server.IdleTimeout })},
+	{"SERVER_SHUTDOWN_DELAY", durationVar(func(c *Config) *Duration { return &c.Server.ShutdownDelay })},
+	{"SERVER_SHUTDOWN_TIMEOUT", durationVar(func(c *Config) *Duration { return &c.Server.ShutdownTimeout })},
+	{"HTTP2_ENABLED", boolVar(func(c *Config) *bool { return &c.Server.HTTP2 })},
+	{"ALLOWED_ORIGINS", func(c *Config, v string) error { c.Server.AllowedOrigins = splitList(v); return nil }},
+	{"TLS_CERT_FILE", func(c *Config, v string) error { c.Server.TLS.CertFile = v; return nil }},
+	{"TLS_KEY_FILE", func(c *Config, v string) error { c.Server.TLS.KeyFile = v; return nil }},
+	{"TLS_CLIENT_CA_FILE", func(c *Config, v string) error { c.Server.TLS.ClientCAFile = v; return nil }},
+	{"TLS_CLIENT_AUTH", func(c *Config, v string) error { c.Server.TLS.ClientAuth = v; return nil }},
+	{"COLLECTOR_BACKOFF_INITIAL", durationVar(func(c *Config) *Duration { return &c.Collector.BackoffInitial })},
+	{"COLLECTOR_BACKOFF_MAX", durationVar(func(c *Config) *Duration { return &c.Collector.BackoffMax })},
+	{"COLLECTOR_BACKOFF_RESET", durationVar(func(c *Config) *Duration { return &c.Collector.BackoffReset })},
+	{"BASIC_AUTH_FILE", func(c *Config, v string) error { c.Auth.BasicAuthFile = v; return nil }},
+	{"AUTH_PROXY_TRUSTED_CIDRS", func(c *Config, v string) error { c.Auth.Proxy.TrustedProxies = splitList(v); return nil }},
+	{"AUTH_PROXY_USER_HEADER", func(c *Config, v string) error { c.Auth.Proxy.UserHeader = v; return nil }},
+	{"AUTH_PROXY_GROUPS_HEADER", func(c *Config, v string) error { c.Auth.Proxy.GroupsHeader = v; return nil }},
+	{"AUTH_PROXY_ALLOWED_GROUPS", func(c *Config, v string) error { c.Auth.Proxy.AllowedGroups = splitList(v); return nil }},
+	{"AUTH_PROXY_LOGOUT_URL", func(c *Config, v string) error { c.Auth.Proxy.LogoutURL = v; return nil }},
+	{"AUTH_ADMINS", func(c *Config, v string) error { c.Auth.Admins = splitList(v); return nil }},
+	{"AUTH_ADMIN_GROUPS", func(c *Config, v string) error { c.Auth.AdminGroups = splitList(v); return nil }},
+	{"TOKENS_DB_PATH", func(c *Config, v string) error { c.Tokens.DBPath = v; return nil }},
+	{"TOKENS_DEFAULT_TTL", durationVar(func(c *Config) *Duration { return &c.Tokens.DefaultTTL })},
+	{"TOKENS_MAX_TTL", durationVar(func(c *Config) *Duration { return &c.Tokens.MaxTTL })},
+	{"SHARE_DEFAULT_TTL", durationVar(func(c *Config) *Duration { return &c.Share.DefaultTTL })},
+	{"SHARE_MAX_TTL", durationVar(func(c *Config) *Duration { return &c.Share.MaxTTL })},
+	{"VNC_PASSWORDS_FILE", func(c *Config, v string) error { c.VNC.PasswordsFile = v; return nil }},
+	{"VNC_WRITE_TIMEOUT", durationVar(func(c *Config) *Duration { return &c.VNC.WriteTimeout })},
+	{"VNC_PING_INTERVAL", durationVar(func(c *Config) *Duration { return &c.VNC.PingInterval })},
+	{"VNC_PONG_TIMEOUT", durationVar(func(c *Config) *Duration { return &c.VNC.PongTimeout })},
+	{"VNC_QUEUE_SIZE", intVar(func(c *Config) *int { return &c.VNC.QueueSize })},
+	{"VNC_MAX_MESSAGE_SIZE_MB", intVar(func(c *Config) *int { return &c.VNC.MaxMessageSizeMB })},
+	{"VNC_COMPRESSION", boolVar(func(c *Config) *bool { return &c.VNC.Compression })},
+	{"LOGIN_MAX_FAILURES_PER_IP", intVar(func(c *Config) *int { return &c.RateLimit.Login.MaxFailuresPerIP })},
+	{"LOGIN_MAX_FAILURES_PER_USER", intVar(func(c *Config) *int { return &c.RateLimit.Login.MaxFailuresPerUser })},
+	{"LOGIN_LOCKOUT", durationVar(func(c *Config) *Duration { return &c.RateLimit.Login.Lockout })},
+	{"LOGIN_MAX_LOCKOUT", durationVar(func(c *Config) *Duration { return &c.RateLimit.Login.MaxLockout })},
+	{"BROWSERS_RATE_LIMIT", intVar(func(c *Config) *int { return &c.RateLimit.Browsers.Requests })},
+	{"BROWSERS_RATE_WINDOW", durationVar(func(c *Config) *Duration { return &c.RateLimit.Browsers.Window })},
+	{"RATE_LIMIT_TRUSTED_PROXIES", func(c *Config, v string) error { c.RateLimit.TrustedProxies = splitList(v); return nil }},
+	{"HISTORY_DB_PATH", func(c *Config, v string) error { c.History.DBPath = v; return nil }},
+	{"HISTORY_RETENTION", durationVar(func(c *Config) *Duration { return &c.History.Retention })},
+	{"WEBHOOKS_FILE", func(c *Config, v string) error { c.Webhooks.File = v; return nil }},
+	{"WEBHOOKS_MAX_ATTEMPTS", intVar(func(c *Config) *int { return &c.Webhooks.MaxAttempts })},
+	{"WEBHOOKS_RETRY_INITIAL", durationVar(func(c *Config) *Duration { return &c.Webhooks.RetryInitial })},
+	{"WEBHOOKS_RETRY_MAX", durationVar(func(c *Config) *Duration { return &c.Webhooks.RetryMax })},
+	{"WEBHOOKS_DEAD_LETTER_FILE", func(c *Config, v string) error { c.Webhooks.DeadLetterFile = v; return nil }},
+	{"AUDIT_SINKS", func(c *Config, v string) error { c.Audit.Sinks = splitList(v); return nil }},
+	{"AUDIT_FILE_PATH", func(c *Config, v string) error { c.Audit.File.Path = v; return nil }},
+	{"AUDIT_FILE_MAX_SIZE_MB", intVar(func(c *Config) *int { return &c.Audit.File.MaxSizeMB })},
+	{"AUDIT_FILE_MAX_BACKUPS", intVar(func(c *Config) *int { return &c.Audit.File.MaxBackups })},
+	{"AUDIT_WEBHOOK_URL", func(c *Config, v string) error { c.Audit.Webhook.URL = v; return nil }},
+	{"AUDIT_WEBHOOK_TIMEOUT", durationVar(func(c *Config) *Duration { return &c.Audit.Webhook.Timeout })},
+}
+
+func durationVar(field func(*Config) *Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = Duration(d)
+		return nil
+	}
+}
+
+func intVar(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func boolVar(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+// applyEnv overrides cfg with every non-empty environment variable in envVars.
+func applyEnv(cfg *Config, lookupEnv func(string) (string, bool)) error {
+	var errs []error
+	for _, ev := range envVars {
+		v, ok := lookupEnv(ev.key)
+		if !ok || v == "" {
+			continue
+		}
+		if err := ev.set(cfg, v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ev.key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func splitList(raw string) []string {
+	var result []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+var auditSinks = map[string]struct{}{"stdout": {}, "stderr": {}, "file": {}, "webhook": {}}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.ListenAddr != "", "listenAddr is required")
+	if _, err := zerolog.ParseLevel(c.LogLevel); err != nil || c.LogLevel == "" {
+		errs = append(errs, fmt.Errorf("logLevel %q is not a valid level", c.LogLevel))
+	}
+	if err := cluster.Validate(c.Clusters); err != nil {
+		errs = append(errs, fmt.Errorf("clusters: %w", err))
+	}
+	check(c.Namespace != "", "namespace is required")
+	check(c.BrowserStartupTimeout > 0, "browserStartupTimeout must be positive")
+
+	check(c.Server.ReadHeaderTimeout >= 0, "server.readHeaderTimeout must not be negative")
+	check(c.Server.ReadTimeout >= 0, "server.readTimeout must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.writeTimeout must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idleTimeout must not be negative")
+	check(c.Server.ShutdownDelay >= 0, "server.shutdownDelay must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdownTimeout must be positive")
+	check((c.Server.TLS.CertFile == "") == (c.Server.TLS.KeyFile == ""), "server.tls.certFile and server.tls.keyFile must be set together")
+	check(c.Server.TLS.ClientCAFile == "" || c.Server.TLS.Enabled(), "server.tls.clientCAFile needs server.tls.certFile and server.tls.keyFile")
+	check(c.Server.TLS.ClientAuth == "require" || c.Server.TLS.ClientAuth == "optional", "server.tls.clientAuth must be \"require\" or \"optional\"")
+
+	for _, origin := range c.Server.AllowedOrigins {
+		if err := csrf.ParseOrigin(origin); err != nil {
+			errs = append(errs, fmt.Errorf("server.allowedOrigins: %w", err))
+		}
+	}
+	for _, cidr := range c.Auth.Proxy.TrustedProxies {
+		if _, err := proxyauth.ParsePrefix(cidr); err != nil {
+			errs = append(errs, fmt.Errorf("auth.proxy.trustedProxies: %w", err))
+		}
+	}
+	if c.Auth.Proxy.Enabled() {
+		check(c.Auth.Proxy.UserHeader != "", "auth.proxy.userHeader is required when auth.proxy.trustedProxies is set")
+	}
+
+	check(c.Collector.BackoffInitial > 0, "collector.backoffInitial must be positive")
+	check(c.Collector.BackoffMax >= c.Collector.BackoffInitial, "collector.backoffMax must not be less than collector.backoffInitial")
+	check(c.Collector.BackoffReset >= 0, "collector.backoffReset must not be negative")
+
+	check(c.Tokens.DefaultTTL > 0, "tokens.defaultTTL must be positive")
+	check(c.Tokens.MaxTTL >= c.Tokens.DefaultTTL, "tokens.maxTTL must not be less than tokens.defaultTTL")
+	check(c.Share.DefaultTTL > 0, "share.defaultTTL must be positive")
+	check(c.Share.MaxTTL >= c.Share.DefaultTTL, "share.maxTTL must not be less than share.defaultTTL")
+	check(c.VNC.WriteTimeout > 0, "vnc.writeTimeout must be positive")
+	check(c.VNC.PingInterval > 0, "vnc.pingInterval must be positive")
+	check(c.VNC.PongTimeout > c.VNC.PingInterval, "vnc.pongTimeout must be longer than vnc.pingInterval")
+	check(c.VNC.QueueSize > 0, "vnc.queueSize must be positive")
+	check(c.VNC.MaxMessageSizeMB > 0, "vnc.maxMessageSizeMB must be positive")
+
+	login := c.RateLimit.Login
+	check(login.MaxFailuresPerIP >= 1, "rateLimit.login.maxFailuresPerIP must be at least 1")
+	check(login.MaxFailuresPerUser >= 1, "rateLimit.login.maxFailuresPerUser must be at least 1")
+	check(login.Lockout > 0, "rateLimit.login.lockout must be positive")
+	check(login.MaxLockout >= login.Lockout, "rateLimit.login.maxLockout must not be less than rateLimit.login.lockout")
+	for _, cidr := range c.RateLimit.TrustedProxies {
+		if _, err := proxyauth.ParsePrefix(cidr); err != nil {
+			errs = append(errs, fmt.Errorf("rateLimit.trustedProxies: %w", err))
+		}
+	}
+	check(c.RateLimit.Browsers.Requests >= 0, "rateLimit.browsers.requests must not be negative")
+	check(c.RateLimit.Browsers.Requests == 0 || c.RateLimit.Browsers.Window > 0, "rateLimit.browsers.window must be positive")
+
+	check(c.History.DBPath == "" || c.History.Retention > 0, "history.retention must be positive")
+
+	check(c.Webhooks.MaxAttempts >= 1, "webhooks.maxAttempts must be at least 1")
+	check(c.Webhooks.RetryInitial > 0, "webhooks.retryInitial must be positive")
+	check(c.Webhooks.RetryMax >= c.Webhooks.RetryInitial, "webhooks.retryMax must not be less than webhooks.retryInitial")
+
+	for _, sink := range c.Audit.Sinks {
+		if _, ok := auditSinks[sink]; !ok {
+			errs = append(errs, fmt.Errorf("audit.sinks: unknown sink %q", sink))
+		}
+		check(sink != "file" || c.Audit.File.Path != "", "audit.file.path is required for the file sink")
+		check(sink != "webhook" || c.Audit.Webhook.URL != "", "audit.webhook.url is required for the webhook sink")
+	}
+	check(c.Audit.File.MaxSizeMB > 0, "audit.file.maxSizeMB must be positive")
+	check(c.Audit.File.MaxBackups >= 0, "audit.file.maxBackups must not be negative")
+	check(c.Audit.Webhook.Timeout > 0, "audit.webhook.timeout must be positive")
+
+	return errors.Join(errs...)
+}
+
+// RestartRequired lists the settings, as dotted JSON paths, that differ
+// between c and next but only take effect after a restart.
+func (c *Config) RestartRequired(next *Config) []string {
+	var result []string
+	restartRequired("", reflect.ValueOf(c).Elem(), reflect.ValueOf(next).Elem(), &result)
+	return result
+}
+
+func restartRequired(prefix string, cur, next reflect.Value, result *[]string) {
+	for i := range cur.NumField() {
+		field := cur.Type().Field(i)
+		if field.Tag.Get("reload") == "true" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		name = prefix + name
+		if field.Type.Kind() == reflect.Struct {
+			restartRequired(name+".", cur.Field(i), next.Field(i), result)
+			continue
+		}
+		if !reflect.DeepEqual(cur.Field(i).Interface(), next.Field(i).Interface()) {
+			*result = append(*result, name)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/cluster"
+)
+
+func noEnv(string) (string, bool) { return "", false }
+
+func envMap(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := values[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestLoadWithoutFileUsesDefaults(t *testing.T) {
+	cfg, err := Load("", noEnv)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.ListenAddr != ":8080" || cfg.Namespace != "default" || time.Duration(cfg.BrowserStartupTimeout) != 3*time.Minute {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoadYAML(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+listenAddr: ":9090"
+logLevel: debug
+clusters:
+  - name: eu
+    url: http://bs-eu:8080
+  - name: us
+    url: http://bs-us:8080
+browserStartupTimeout: 90s
+history:
+  dbPath: /data/history.db
+  retention: 168h
+webhooks:
+  hooks:
+    - name: ci
+      url: https://ci.example.com/hook
+`)
+
+	cfg, err := Load(path, noEnv)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.ListenAddr != ":9090" || cfg.LogLevel != "debug" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if len(cfg.Clusters) != 2 || cfg.Clusters[1] != (cluster.Endpoint{Name: "us", URL: "http://bs-us:8080"}) {
+		t.Fatalf("unexpected clusters: %+v", cfg.Clusters)
+	}
+	if time.Duration(cfg.BrowserStartupTimeout) != 90*time.Second || time.Duration(cfg.History.Retention) != 168*time.Hour {
+		t.Fatalf("unexpected durations: %+v", cfg)
+	}
+	if len(cfg.Webhooks.Hooks) != 1 || cfg.Webhooks.Hooks[0].Name != "ci" {
+		t.Fatalf("unexpected hooks: %+v", cfg.Webhooks.Hooks)
+	}
+	// Settings missing from the file keep their defaults.
+	if cfg.Namespace != "default" || cfg.Audit.File.MaxSizeMB != 100 {
+		t.Fatalf("expected defaults for unset fields, got %+v", cfg)
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	path := writeFile(t, "config.json", `{"namespace": "browsers", "collector": {"backoffMax": "5m"}}`)
+
+	cfg, err := Load(path, noEnv)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Namespace != "browsers" || time.Duration(cfg.Collector.BackoffMax) != 5*time.Minute {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestLoadEnvOverridesFile(t *testing.T) {
+	path := writeFile(t, "config.yaml", "namespace: browsers\nbrowserStartupTimeout: 90s\n")
+
+	cfg, err := Load(path, envMap(map[string]string{
+		"BROWSER_NAMESPACE":       "selenium",
+		"BROWSER_SERVICE_URL":     "eu=http://bs-eu:8080,us=http://bs-us:8080",
+		"AUDIT_SINKS":             "stdout, stderr",
+		"BROWSER_STARTUP_TIMEOUT": "",
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Namespace != "selenium" {
+		t.Fatalf("expected namespace from env, got %q", cfg.Namespace)
+	}
+	if len(cfg.Clusters) != 2 || cfg.Clusters[0].Name != "eu" {
+		t.Fatalf("expected clusters from env, got %+v", cfg.Clusters)
+	}
+	if !slices.Equal(cfg.Audit.Sinks, []string{"stdout", "stderr"}) {
+		t.Fatalf("expected audit sinks from env, got %v", cfg.Audit.Sinks)
+	}
+	if time.Duration(cfg.BrowserStartupTimeout) != 90*time.Second {
+		t.Fatalf("expected empty env var to keep the file value, got %v", time.Duration(cfg.BrowserStartupTimeout))
+	}
+}
+
+func TestLoadRejectsUnknownField(t *testing.T) {
+	path := writeFile(t, "config.yaml", "listenAdr: \":9090\"\n")
+
+	if _, err := Load(path, noEnv); err == nil || !strings.Contains(err.Error(), "listenAdr") {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+}
+
+func TestLoadReportsEveryInvalidSetting(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+logLevel: loud
+namespace: ""
+audit:
+  sinks: [file]
+`)
+
+	_, err := Load(path, envMap(map[string]string{"WEBHOOKS_MAX_ATTEMPTS": "0"}))
+	if err == nil {
+		t.Fatal("expected validation error, got nil")
+	}
+	for _, want := range []string{"logLevel", "namespace is required", "audit.file.path", "webhooks.maxAttempts"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to mention %q, got %v", want, err)
+		}
+	}
+}
+
+func TestLoadReportsInvalidEnvValue(t *testing.T) {
+	_, err := Load("", envMap(map[string]string{"HISTORY_RETENTION": "a month"}))
+	if err == nil || !strings.Contains(err.Error(), "HISTORY_RETENTION") {
+		t.Fatalf("expected env parse error, got %v", err)
+	}
+}
+
+func TestRestartRequiredIgnoresReloadableFields(t *testing.T) {
+	cur := Default()
+	next := Default()
+	next.LogLevel = "debug"
+	next.BrowserStartupTimeout = Duration(time.Minute)
+	next.History.Retention = Duration(time.Hour)
+
+	if got := cur.RestartRequired(next); len(got) != 0 {
+		t.Fatalf("expected no restart for reloadable fields, got %v", got)
+	}
+
+	next.ListenAddr = ":9090"
+	next.History.DBPath = "/data/history.db"
+	got := cur.RestartRequired(next)
+	if !slices.Equal(got, []string{"listenAddr", "history.dbPath"}) {
+		t.Fatalf("expected listenAddr and history.dbPath, got %v", got)
+	}
+}
+
+func TestWatchAppliesChangedFile(t *testing.T) {
+	path := writeFile(t, "config.yaml", "logLevel: info\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	applied := make(chan *Config, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, path, noEnv, func(cfg *Config) {
+			select {
+			case applied <- cfg:
+			default:
+			}
+		})
+	}()
+
+	// Give the watcher time to register before the file changes.
+	time.Sleep(100 * time.Millisecond)
+
+	if err := os.WriteFile(path, []byte("logLevel: ["), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	select {
+	case cfg := <-applied:
+		t.Fatalf("expected invalid file to be rejected, got %+v", cfg)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte("logLevel: debug\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	select {
+	case cfg := <-applied:
+		if cfg.LogLevel != "debug" {
+			t.Fatalf("expected logLevel debug, got %q", cfg.LogLevel)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected config to be reloaded")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	cfg := Default()
+	cfg.Server.TLS.CertFile = "/tls/tls.crt"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "server.tls.keyFile") {
+		t.Fatalf("expected cert/key pair error, got %v", err)
+	}
+
+	cfg.Server.TLS.KeyFile = "/tls/tls.key"
+	cfg.Server.TLS.ClientCAFile = "/tls/ca.crt"
+	cfg.Server.TLS.ClientAuth = "optional"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	cfg.Server.TLS.ClientAuth = "sometimes"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "server.tls.clientAuth") {
+		t.Fatalf("expected clientAuth error, got %v", err)
+	}
+}
+
+func TestValidateAllowedOrigins(t *testing.T) {
+	cfg, err := Load("", envMap(map[string]string{"ALLOWED_ORIGINS": "https://ui.example.com, ui.example.org"}))
+	if err == nil || !strings.Contains(err.Error(), "ui.example.org") {
+		t.Fatalf("expected invalid origin error, got %v (%+v)", err, cfg)
+	}
+}
+
+func TestProxyAuthFromEnv(t *testing.T) {
+	cfg, err := Load("", envMap(map[string]string{
+		"AUTH_PROXY_TRUSTED_CIDRS":  "10.0.0.0/8, 192.168.1.5",
+		"AUTH_PROXY_ALLOWED_GROUPS": "qa,admins",
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.Auth.Proxy.Enabled() || cfg.Auth.Proxy.UserHeader != "X-Forwarded-User" || len(cfg.Auth.Proxy.AllowedGroups) != 2 {
+		t.Fatalf("unexpected proxy auth config: %+v", cfg.Auth.Proxy)
+	}
+
+	if _, err := Load("", envMap(map[string]string{"AUTH_PROXY_TRUSTED_CIDRS": "10.0.0.0/33"})); err == nil || !strings.Contains(err.Error(), "auth.proxy.trustedProxies") {
+		t.Fatalf("expected invalid CIDR error, got %v", err)
+	}
+}
+
+func TestClientIPProxiesFallBackToProxyAuth(t *testing.T) {
+	cfg, err := Load("", envMap(map[string]string{"AUTH_PROXY_TRUSTED_CIDRS": "10.0.0.0/8"}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := cfg.ClientIPProxies(); len(got) != 1 || got[0] != "10.0.0.0/8" {
+		t.Fatalf("expected proxy auth CIDRs, got %v", got)
+	}
+
+	cfg.RateLimit.TrustedProxies = []string{"172.16.0.0/12"}
+	if got := cfg.ClientIPProxies(); len(got) != 1 || got[0] != "172.16.0.0/12" {
+		t.Fatalf("expected rate limit CIDRs to win, got %v", got)
+	}
+
+	if _, err := Load("", envMap(map[string]string{"RATE_LIMIT_TRUSTED_PROXIES": "ingress"})); err == nil || !strings.Contains(err.Error(), "rateLimit.trustedProxies") {
+		t.Fatalf("expected invalid CIDR error, got %v", err)
+	}
+}
+
+func TestValidateVNCKeepalive(t *testing.T) {
+	cfg, err := Load("", envMap(map[string]string{"VNC_COMPRESSION": "true", "VNC_QUEUE_SIZE": "8"}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.VNC.Compression || cfg.VNC.QueueSize != 8 {
+		t.Fatalf("unexpected vnc config: %+v", cfg.VNC)
+	}
+
+	if _, err := Load("", envMap(map[string]string{"VNC_PONG_TIMEOUT": "10s"})); err == nil || !strings.Contains(err.Error(), "vnc.pongTimeout") {
+		t.Fatalf("expected pong timeout error, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch reloads the file at path on SIGHUP and whenever it changes on disk,
+// and calls apply with each new valid configuration. A file that fails to
+// load or validate is logged and the previous configuration stays in effect.
+// Watch blocks until ctx is done.
+//
+// The directory is watched rather than the file, so replacing the file and
+// Kubernetes ConfigMap symlink swaps are noticed as well.
+func Watch(ctx context.Context, path string, lookupEnv func(string) (string, bool), apply func(*Config)) error {
+	log := logctx.FromContext(ctx)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	last, _ := os.ReadFile(path)
+	reload := func(reason string, force bool) {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			log.Error().Err(err).Str("path", path).Msg("failed to read config file")
+			return
+		}
+		if !force && bytes.Equal(raw, last) {
+			return
+		}
+		last = raw
+		if len(bytes.TrimSpace(raw)) == 0 {
+			// Editors and ConfigMap updates may truncate the file before writing it.
+			log.Warn().Str("path", path).Msg("config file is empty, keeping previous config")
+			return
+		}
+
+		cfg, err := Load(path, lookupEnv)
+		if err != nil {
+			log.Error().Err(err).Str("path", path).Str("trigger", reason).Msg("config reload rejected, keeping previous config")
+			return
+		}
+		log.Info().Str("path", path).Str("trigger", reason).Msg("config reloaded")
+		apply(cfg)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			reload("sighup", true)
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Has(fsnotify.Write) || ev.Has(fsnotify.Create) || ev.Has(fsnotify.Rename) {
+				reload("file", false)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error().Err(err).Str("path", path).Msg("config watcher error")
+		}
+	}
+}
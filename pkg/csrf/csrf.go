@@ -0,0 +1,171 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+)
+
+const (
+	// CookieName holds the token; it is readable by the frontend, which echoes
+	// it in HeaderName on every mutating request (double-submit).
+	CookieName = "browser_ui_csrf"
+	HeaderName = "X-CSRF-Token"
+)
+
+// Origins decides which Origin headers are accepted. The server's own origin
+// is always allowed; "*" allows any origin.
+type Origins struct {
+	any     bool
+	allowed map[string]struct{}
+}
+
+func NewOrigins(allowed ...string) *Origins {
+	o := &Origins{allowed: make(map[string]struct{}, len(allowed))}
+	for _, origin := range allowed {
+		if origin == "*" {
+			o.any = true
+			continue
+		}
+		o.allowed[normalize(origin)] = struct{}{}
+	}
+	return o
+}
+
+// ParseOrigin checks that origin is "*" or a scheme://host[:port] origin.
+func ParseOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return fmt.Errorf("invalid origin %q, expected scheme://host[:port]", origin)
+	}
+	return nil
+}
+
+// Allowed reports whether req may be served given its Origin header. Requests
+// without one are not from a cross-origin browser context and are allowed.
+func (o *Origins) Allowed(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" || o.any {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, req.Host) {
+		return true
+	}
+	_, ok := o.allowed[normalize(origin)]
+	return ok
+}
+
+func normalize(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(origin, "/"))
+}
+
+// Protector rejects mutating requests that do not echo the CSRF cookie in
+// HeaderName or come from an origin that is not allowed.
+type Protector struct {
+	origins *Origins
+	secure  bool
+}
+
+type Option func(*Protector)
+
+func WithOrigins(origins *Origins) Option {
+	return func(p *Protector) { p.origins = origins }
+}
+
+// WithSecureCookie marks the token cookie Secure; use it when serving HTTPS.
+func WithSecureCookie(secure bool) Option {
+	return func(p *Protector) { p.secure = secure }
+}
+
+func New(opts ...Option) *Protector {
+	p := &Protector{origins: NewOrigins()}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Middleware issues the token cookie when it is missing and checks mutating
+// requests. Requests with a Bearer token carry explicit credentials that a
+// browser never attaches to a cross-site request, so they are not checked.
+// Other Authorization schemes are, since the API falls back to the auth
+// cookie for them.
+func (p *Protector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		log := logctx.FromContext(req.Context())
+
+		token := ""
+		if cookie, err := req.Cookie(CookieName); err == nil && validToken(cookie.Value) {
+			token = cookie.Value
+		} else {
+			token = newToken()
+			http.SetCookie(rw, &http.Cookie{
+				Name:     CookieName,
+				Value:    token,
+				Path:     "/",
+				Secure:   p.secure,
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+
+		if isSafe(req.Method) || hasBearer(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		if !p.origins.Allowed(req) {
+			log.Warn().Str("origin", req.Header.Get("Origin")).Msg("request from disallowed origin rejected")
+			http.Error(rw, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get(HeaderName)), []byte(token)) != 1 {
+			log.Warn().Msg("request with missing or invalid CSRF token rejected")
+			http.Error(rw, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(rw, req)
+	})
+}
+
+func isSafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+const tokenBytes = 32
+
+func newToken() string {
+	b := make([]byte, tokenBytes)
+	rand.Read(b) //nolint:errcheck // crypto/rand.Read never fails
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func validToken(token string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	return err == nil && len(b) == tokenBytes
+}
+
+// hasBearer reports whether req authenticates with a Bearer token, the only
+// Authorization scheme the API accepts instead of the cookie.
+func hasBearer(req *http.Request) bool {
+	scheme, value, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	return ok && strings.EqualFold(scheme, "Bearer") && value != ""
+}
@@ -0,0 +1,205 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func protected(p *Protector) (http.Handler, *bool) {
+	called := false
+	return p.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		called = true
+	})), &called
+}
+
+// issueToken performs a GET, as the frontend does on load, and returns the cookie.
+func issueToken(t *testing.T, h http.Handler) *http.Cookie {
+	t.Helper()
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/api/v1/status", nil))
+	for _, c := range rw.Result().Cookies() {
+		if c.Name == CookieName {
+			return c
+		}
+	}
+	t.Fatalf("expected %s cookie to be issued", CookieName)
+	return nil
+}
+
+func TestMiddlewareIssuesReadableCookie(t *testing.T) {
+	h, called := protected(New(WithSecureCookie(true)))
+	cookie := issueToken(t, h)
+
+	if !*called {
+		t.Fatalf("expected GET to reach the handler")
+	}
+	if cookie.HttpOnly {
+		t.Fatalf("expected cookie to be readable by the frontend")
+	}
+	if !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode {
+		t.Fatalf("expected Secure SameSite=Strict cookie, got %+v", cookie)
+	}
+}
+
+func TestMiddlewareKeepsExistingToken(t *testing.T) {
+	h, _ := protected(New())
+	cookie := issueToken(t, h)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+	req.AddCookie(cookie)
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	if len(rw.Result().Cookies()) != 0 {
+		t.Fatalf("expected no new cookie when a valid one is present")
+	}
+}
+
+func TestMiddlewareAcceptsDoubleSubmit(t *testing.T) {
+	h, called := protected(New())
+	cookie := issueToken(t, h)
+	*called = false
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/browsers", strings.NewReader(`{}`))
+	req.AddCookie(cookie)
+	req.Header.Set(HeaderName, cookie.Value)
+	req.Header.Set("Origin", "http://example.com")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK || !*called {
+		t.Fatalf("expected request to pass, got %d", rw.Code)
+	}
+}
+
+func TestMiddlewareRejectsForgedRequests(t *testing.T) {
+	h, called := protected(New())
+	cookie := issueToken(t, h)
+
+	tests := []struct {
+		name   string
+		method string
+		setup  func(req *http.Request)
+	}{
+		{
+			// A cross-site form POST: the browser attaches the cookies but
+			// the attacker cannot read them to set the header.
+			name:   "missing header",
+			method: http.MethodPost,
+			setup:  func(req *http.Request) { req.AddCookie(cookie) },
+		},
+		{
+			name:   "wrong header",
+			method: http.MethodDelete,
+			setup: func(req *http.Request) {
+				req.AddCookie(cookie)
+				req.Header.Set(HeaderName, "guessed")
+			},
+		},
+		{
+			// A forged cookie without the victim's token.
+			name:   "no cookie",
+			method: http.MethodPost,
+			setup:  func(req *http.Request) { req.Header.Set(HeaderName, cookie.Value) },
+		},
+		{
+			name:   "cross origin",
+			method: http.MethodDelete,
+			setup: func(req *http.Request) {
+				req.AddCookie(cookie)
+				req.Header.Set(HeaderName, cookie.Value)
+				req.Header.Set("Origin", "https://evil.example")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*called = false
+			req := httptest.NewRequest(tt.method, "/api/v1/browsers/browser-1", nil)
+			tt.setup(req)
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusForbidden {
+				t.Fatalf("expected status %d, got %d", http.StatusForbidden, rw.Code)
+			}
+			if *called {
+				t.Fatalf("expected handler not to be called")
+			}
+		})
+	}
+}
+
+func TestMiddlewareSkipsExplicitCredentials(t *testing.T) {
+	h, called := protected(New())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/browsers", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	if !*called {
+		t.Fatalf("expected request with Authorization header to pass, got %d", rw.Code)
+	}
+}
+
+func TestMiddlewareChecksOtherAuthorizationSchemes(t *testing.T) {
+	h, called := protected(New())
+	cookie := issueToken(t, h)
+
+	for _, value := range []string{"Basic x", "Bearer", "Token abc"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/browsers", nil)
+		req.Header.Set("Authorization", value)
+		req.Header.Set("Origin", "https://evil.example")
+		req.AddCookie(cookie)
+		req.AddCookie(&http.Cookie{Name: "browser_ui_auth", Value: "YWxpY2U6c2VjcmV0"})
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+
+		if *called || rw.Code != http.StatusForbidden {
+			t.Fatalf("expected cross-site request with %q and a cookie to be rejected, got %d", value, rw.Code)
+		}
+	}
+}
+
+func TestOriginsAllowed(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{nil, "", true},
+		{nil, "http://example.com", true},
+		{nil, "https://evil.example", false},
+		{nil, "null", false},
+		{[]string{"https://ui.example.com/"}, "https://UI.example.com", true},
+		{[]string{"https://ui.example.com"}, "http://ui.example.com", false},
+		{[]string{"*"}, "https://evil.example", true},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := NewOrigins(tt.allowed...).Allowed(req); got != tt.want {
+			t.Fatalf("allowed %v, origin %q: expected %v, got %v", tt.allowed, tt.origin, tt.want, got)
+		}
+	}
+}
+
+func TestParseOrigin(t *testing.T) {
+	for _, origin := range []string{"*", "https://ui.example.com", "http://localhost:3000"} {
+		if err := ParseOrigin(origin); err != nil {
+			t.Fatalf("expected %q to be valid, got %v", origin, err)
+		}
+	}
+	for _, origin := range []string{"ui.example.com", "https://ui.example.com/path", ""} {
+		if err := ParseOrigin(origin); err == nil {
+			t.Fatalf("expected %q to be invalid", origin)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type Result struct {
+	Ready   bool           `json:"ready"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+type Checker interface {
+	Check(ctx context.Context) Result
+}
+
+type CheckerFunc func(ctx context.Context) Result
+
+func (f CheckerFunc) Check(ctx context.Context) Result {
+	return f(ctx)
+}
+
+type Check struct {
+	Name    string
+	Checker Checker
+}
+
+// All combines checks into one Checker that is ready only when every check is;
+// each check's result is reported under its name in the details.
+func All(checks ...Check) Checker {
+	return CheckerFunc(func(ctx context.Context) Result {
+		ready := true
+		details := make(map[string]any, len(checks))
+		for _, c := range checks {
+			res := c.Checker.Check(ctx)
+			details[c.Name] = res
+			ready = ready && res.Ready
+		}
+		return Result{Ready: ready, Details: details}
+	})
+}
+
+// Handler runs every check and responds 200 only when all of them are ready,
+// 503 otherwise. The per-check results are always included in the body.
+func Handler(checks ...Check) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		ready := true
+		results := make(map[string]Result, len(checks))
+		for _, c := range checks {
+			res := c.Checker.Check(req.Context())
+			results[c.Name] = res
+			ready = ready && res.Ready
+		}
+
+		response := struct {
+			Status string            `json:"status"`
+			Checks map[string]Result `json:"checks,omitempty"`
+		}{
+			Status: "ok",
+			Checks: results,
+		}
+
+		code := http.StatusOK
+		if !ready {
+			response.Status = "not ready"
+			code = http.StatusServiceUnavailable
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(code)
+		json.NewEncoder(rw).Encode(&response) //nolint:errcheck
+	}
+}
+
+// Shutdown turns not ready once Begin is called, so that load balancers stop
+// sending traffic to a server that is about to go away.
+type Shutdown struct {
+	started atomic.Bool
+}
+
+func (s *Shutdown) Begin() {
+	s.started.Store(true)
+}
+
+func (s *Shutdown) Check(context.Context) Result {
+	if s.started.Load() {
+		return Result{Ready: false, Details: map[string]any{"shuttingDown": true}}
+	}
+	return Result{Ready: true}
+}
+
+// HTTPChecker reports whether url answers a GET with a non-5xx status within timeout.
+func HTTPChecker(client *http.Client, url string, timeout time.Duration) Checker {
+	return CheckerFunc(func(ctx context.Context) Result {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		start := time.Now()
+		details := map[string]any{"url": url}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			details["error"] = err.Error()
+			return Result{Ready: false, Details: details}
+		}
+
+		resp, err := client.Do(req)
+		details["latency"] = time.Since(start).String()
+		if err != nil {
+			details["error"] = err.Error()
+			return Result{Ready: false, Details: details}
+		}
+		resp.Body.Close()
+
+		details["statusCode"] = resp.StatusCode
+		return Result{Ready: resp.StatusCode < http.StatusInternalServerError, Details: details}
+	})
+}
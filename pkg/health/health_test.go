@@ -0,0 +1,125 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func static(ready bool) Checker {
+	return CheckerFunc(func(context.Context) Result {
+		return Result{Ready: ready, Details: map[string]any{"static": ready}}
+	})
+}
+
+func TestHandlerNoChecks(t *testing.T) {
+	rw := httptest.NewRecorder()
+	Handler()(rw, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rw.Code)
+	}
+}
+
+func TestHandlerAllReady(t *testing.T) {
+	rw := httptest.NewRecorder()
+	Handler(Check{Name: "a", Checker: static(true)}, Check{Name: "b", Checker: static(true)})(rw, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rw.Code)
+	}
+}
+
+func TestHandlerOneNotReady(t *testing.T) {
+	rw := httptest.NewRecorder()
+	Handler(Check{Name: "a", Checker: static(true)}, Check{Name: "b", Checker: static(false)})(rw, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rw.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", rw.Code)
+	}
+
+	var got struct {
+		Status string            `json:"status"`
+		Checks map[string]Result `json:"checks"`
+	}
+	if err := json.Unmarshal(rw.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got.Status != "not ready" {
+		t.Fatalf("expected status not ready, got %q", got.Status)
+	}
+	if !got.Checks["a"].Ready || got.Checks["b"].Ready {
+		t.Fatalf("unexpected check results: %+v", got.Checks)
+	}
+}
+
+func TestHTTPCheckerReachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	res := HTTPChecker(srv.Client(), srv.URL, time.Second).Check(context.Background())
+	if !res.Ready {
+		t.Fatalf("expected reachable backend to be ready, got %+v", res)
+	}
+	if res.Details["statusCode"] != http.StatusNotFound {
+		t.Fatalf("expected statusCode 404 in details, got %v", res.Details["statusCode"])
+	}
+}
+
+func TestHTTPCheckerServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	res := HTTPChecker(srv.Client(), srv.URL, time.Second).Check(context.Background())
+	if res.Ready {
+		t.Fatalf("expected 5xx backend to be not ready")
+	}
+}
+
+func TestHTTPCheckerUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	res := HTTPChecker(http.DefaultClient, url, time.Second).Check(context.Background())
+	if res.Ready {
+		t.Fatalf("expected unreachable backend to be not ready")
+	}
+	if _, ok := res.Details["error"]; !ok {
+		t.Fatalf("expected error in details, got %+v", res.Details)
+	}
+}
+
+func TestAllReadyOnlyWhenEveryCheckIs(t *testing.T) {
+	ready := All(Check{Name: "a", Checker: static(true)}, Check{Name: "b", Checker: static(true)}).Check(context.Background())
+	if !ready.Ready {
+		t.Fatalf("expected combined check to be ready")
+	}
+
+	res := All(Check{Name: "a", Checker: static(true)}, Check{Name: "b", Checker: static(false)}).Check(context.Background())
+	if res.Ready {
+		t.Fatalf("expected combined check not to be ready")
+	}
+	b, ok := res.Details["b"].(Result)
+	if !ok || b.Ready {
+		t.Fatalf("expected details to carry check b, got %+v", res.Details)
+	}
+}
+
+func TestShutdownTurnsNotReady(t *testing.T) {
+	shutdown := &Shutdown{}
+	if !shutdown.Check(context.Background()).Ready {
+		t.Fatalf("expected ready before shutdown")
+	}
+	shutdown.Begin()
+	if shutdown.Check(context.Background()).Ready {
+		t.Fatalf("expected not ready once shutdown began")
+	}
+}
@@ -0,0 +1,270 @@
+package history
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"time"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	sessionsBucket = []byte("sessions")
+	actionsBucket  = []byte("actions")
+)
+
+// BoltStore persists history in a single BoltDB file. Sessions are keyed by
+// their session store key, so Browsers of the same name in different
+// namespaces or clusters keep separate records; actions by a monotonically
+// increasing sequence.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{sessionsBucket, actionsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *BoltStore) RecordSession(ctx context.Context, ev SessionEvent) {
+	if ev.Session == nil || ev.Session.BrowserId == "" {
+		return
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		key := []byte(ev.Session.Key())
+
+		var record SessionRecord
+		if raw := b.Get(key); raw != nil {
+			if err := json.Unmarshal(raw, &record); err != nil {
+				return err
+			}
+		}
+		mergeSession(&record, ev)
+
+		raw, err := json.Marshal(&record)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, raw)
+	})
+	if err != nil {
+		metrics.HistoryWriteErrors.Inc()
+		log := logctx.FromContext(ctx)
+		log.Error().Err(err).Str("browserId", ev.Session.BrowserId).Str("event", string(ev.Type)).Msg("failed to record session history")
+	}
+}
+
+func (s *BoltStore) RecordAction(ctx context.Context, action Action) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(actionsBucket)
+
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		action.ID = id
+		if action.Time.IsZero() {
+			action.Time = time.Now()
+		}
+
+		raw, err := json.Marshal(&action)
+		if err != nil {
+			return err
+		}
+		return b.Put(itob(id), raw)
+	})
+	if err != nil {
+		metrics.HistoryWriteErrors.Inc()
+		log := logctx.FromContext(ctx)
+		log.Error().Err(err).Str("browserId", action.BrowserId).Str("action", string(action.Type)).Msg("failed to record action history")
+	}
+}
+
+// Sessions returns matching session records, most recently created first.
+func (s *BoltStore) Sessions(_ context.Context, q Query) ([]SessionRecord, error) {
+	result := []SessionRecord{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(_, raw []byte) error {
+			var record SessionRecord
+			if err := json.Unmarshal(raw, &record); err != nil {
+				return err
+			}
+			if q.matchSession(&record) {
+				result = append(result, record)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].key() < result[j].key()
+		}
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[:q.Limit]
+	}
+	return result, nil
+}
+
+// Actions returns matching actions, newest first.
+func (s *BoltStore) Actions(_ context.Context, q Query) ([]Action, error) {
+	result := []Action{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(actionsBucket).Cursor()
+		for k, raw := c.Last(); k != nil; k, raw = c.Prev() {
+			var action Action
+			if err := json.Unmarshal(raw, &action); err != nil {
+				return err
+			}
+			if !q.matchAction(&action) {
+				continue
+			}
+			result = append(result, action)
+			if q.Limit > 0 && len(result) >= q.Limit {
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteBefore removes deleted sessions and actions older than t and returns
+// how many records were removed. Sessions that are still alive are kept.
+func (s *BoltStore) DeleteBefore(_ context.Context, t time.Time) (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(sessionsBucket)
+		var staleSessions, staleActions [][]byte
+		err := sessions.ForEach(func(k, raw []byte) error {
+			var record SessionRecord
+			if err := json.Unmarshal(raw, &record); err != nil {
+				return err
+			}
+			if record.DeletedAt != nil && record.DeletedAt.Before(t) {
+				staleSessions = append(staleSessions, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		actions := tx.Bucket(actionsBucket)
+		c := actions.Cursor()
+		for k, raw := c.First(); k != nil; k, raw = c.Next() {
+			var action Action
+			if err := json.Unmarshal(raw, &action); err != nil {
+				return err
+			}
+			if !action.Time.Before(t) {
+				break
+			}
+			staleActions = append(staleActions, k)
+		}
+
+		// Deleting while iterating makes the cursor skip entries, so remove
+		// the collected keys afterwards.
+		for _, k := range staleSessions {
+			if err := sessions.Delete(k); err != nil {
+				return err
+			}
+		}
+		for _, k := range staleActions {
+			if err := actions.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(staleSessions) + len(staleActions)
+		return nil
+	})
+	return removed, err
+}
+
+// mergeSession folds a lifecycle event into the stored record. Fields only move
+// forward, so replayed events after a collector resync are harmless.
+func mergeSession(record *SessionRecord, ev SessionEvent) {
+	sess := ev.Session
+
+	record.Cluster = sess.Cluster
+	record.Namespace = sess.Namespace
+	record.BrowserId = sess.BrowserId
+	if sess.SessionId != "" {
+		record.SessionId = sess.SessionId
+	}
+	if sess.BrowserName != "" {
+		record.BrowserName = sess.BrowserName
+	}
+	if sess.BrowserVersion != "" {
+		record.BrowserVersion = sess.BrowserVersion
+	}
+	if sess.Owner != "" {
+		record.Owner = sess.Owner
+	}
+	if sess.Phase != "" {
+		record.Phase = string(sess.Phase)
+	}
+	record.StartedManually = record.StartedManually || sess.StartedManually
+
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = ev.Time
+		if sess.StartTime != nil && !sess.StartTime.IsZero() {
+			record.CreatedAt = sess.StartTime.Time
+		}
+	}
+
+	switch ev.Type {
+	case SessionRunning:
+		if record.RunningAt == nil {
+			t := ev.Time
+			record.RunningAt = &t
+		}
+	case SessionDeleted:
+		if record.DeletedAt == nil {
+			t := ev.Time
+			record.DeletedAt = &t
+			record.DurationSeconds = t.Sub(record.CreatedAt).Seconds()
+		}
+	}
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
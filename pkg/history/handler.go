@@ -0,0 +1,187 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/alcounit/selenosis/v2/pkg/auth"
+)
+
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+// Handler serves the history API. When the request carries an authenticated
+// owner, results are restricted to that owner.
+type Handler struct {
+	store Store
+}
+
+func NewHandler(store Store) *Handler {
+	return &Handler{store: store}
+}
+
+func (h *Handler) Sessions(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	q, format, err := parseQuery(req)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := h.store.Sessions(req.Context(), q)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query session history")
+		http.Error(rw, "failed to query history", http.StatusInternalServerError)
+		return
+	}
+
+	if format == formatCSV {
+		rows := make([][]string, 0, len(records))
+		for _, r := range records {
+			rows = append(rows, []string{
+				r.BrowserId, r.SessionId, r.BrowserName, r.BrowserVersion, r.Owner,
+				strconv.FormatBool(r.StartedManually), r.Phase,
+				formatTime(&r.CreatedAt), formatTime(r.RunningAt), formatTime(r.DeletedAt),
+				strconv.FormatFloat(r.DurationSeconds, 'f', -1, 64),
+				r.Cluster, r.Namespace,
+			})
+		}
+		writeCSV(rw, "sessions.csv", []string{
+			"browserId", "sessionId", "browserName", "browserVersion", "owner",
+			"startedManually", "phase", "createdAt", "runningAt", "deletedAt", "durationSeconds",
+			"cluster", "namespace",
+		}, rows)
+		return
+	}
+
+	response := struct {
+		Sessions []SessionRecord `json:"sessions"`
+	}{
+		Sessions: records,
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(&response); err != nil {
+		log.Error().Err(err).Msg("failed to encode session history response")
+		http.Error(rw, "failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) Actions(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	q, format, err := parseQuery(req)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	actions, err := h.store.Actions(req.Context(), q)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query action history")
+		http.Error(rw, "failed to query history", http.StatusInternalServerError)
+		return
+	}
+
+	if format == formatCSV {
+		rows := make([][]string, 0, len(actions))
+		for _, a := range actions {
+			rows = append(rows, []string{
+				strconv.FormatUint(a.ID, 10), formatTime(&a.Time), string(a.Type), a.User,
+				a.BrowserId, a.BrowserName, a.BrowserVersion, a.Outcome, a.Cluster, a.Namespace,
+			})
+		}
+		writeCSV(rw, "actions.csv", []string{
+			"id", "time", "action", "user", "browserId", "browserName", "browserVersion", "outcome", "cluster", "namespace",
+		}, rows)
+		return
+	}
+
+	response := struct {
+		Actions []Action `json:"actions"`
+	}{
+		Actions: actions,
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(&response); err != nil {
+		log.Error().Err(err).Msg("failed to encode action history response")
+		http.Error(rw, "failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func parseQuery(req *http.Request) (Query, string, error) {
+	values := req.URL.Query()
+
+	q := Query{
+		Cluster:        values.Get("cluster"),
+		Namespace:      values.Get("namespace"),
+		BrowserId:      values.Get("browserId"),
+		BrowserName:    values.Get("browserName"),
+		BrowserVersion: values.Get("browserVersion"),
+		Owner:          values.Get("owner"),
+		Action:         ActionType(values.Get("action")),
+	}
+
+	var err error
+	if q.Since, err = parseTime(values.Get("since")); err != nil {
+		return q, "", fmt.Errorf("invalid since: %w", err)
+	}
+	if q.Until, err = parseTime(values.Get("until")); err != nil {
+		return q, "", fmt.Errorf("invalid until: %w", err)
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		q.Limit, err = strconv.Atoi(raw)
+		if err != nil || q.Limit < 0 {
+			return q, "", errors.New("invalid limit")
+		}
+	}
+
+	format := values.Get("format")
+	switch format {
+	case "":
+		format = formatJSON
+	case formatJSON, formatCSV:
+	default:
+		return q, "", fmt.Errorf("unsupported format %q", format)
+	}
+
+	if owner, ok := auth.OwnerFrom(req.Context()); ok {
+		q.Owner = owner.Name
+	}
+
+	return q, format, nil
+}
+
+func parseTime(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func writeCSV(rw http.ResponseWriter, filename string, header []string, rows [][]string) {
+	rw.Header().Set("Content-Type", "text/csv")
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	w := csv.NewWriter(rw)
+	w.Write(header)  //nolint:errcheck
+	w.WriteAll(rows) //nolint:errcheck
+}
@@ -0,0 +1,160 @@
+package history
+
+import (
+	"context"
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/types"
+)
+
+type SessionEventType string
+
+const (
+	SessionCreated SessionEventType = "created"
+	SessionRunning SessionEventType = "running"
+	SessionFailed  SessionEventType = "failed"
+	SessionDeleted SessionEventType = "deleted"
+)
+
+type ActionType string
+
+const (
+	ActionCreate ActionType = "create"
+	ActionDelete ActionType = "delete"
+	ActionVNC    ActionType = "vnc"
+)
+
+// SessionEvent is a lifecycle transition observed by the collector.
+type SessionEvent struct {
+	Type    SessionEventType
+	Time    time.Time
+	Session *types.Session
+}
+
+// SessionRecord is the persisted lifecycle of a single Browser.
+type SessionRecord struct {
+	Cluster         string     `json:"cluster,omitempty"`
+	Namespace       string     `json:"namespace,omitempty"`
+	BrowserId       string     `json:"browserId"`
+	SessionId       string     `json:"sessionId,omitempty"`
+	BrowserName     string     `json:"browserName"`
+	BrowserVersion  string     `json:"browserVersion"`
+	Owner           string     `json:"owner,omitempty"`
+	StartedManually bool       `json:"startedManually"`
+	Phase           string     `json:"phase,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	RunningAt       *time.Time `json:"runningAt,omitempty"`
+	DeletedAt       *time.Time `json:"deletedAt,omitempty"`
+	DurationSeconds float64    `json:"durationSeconds,omitempty"`
+}
+
+func (r *SessionRecord) key() string {
+	return types.StoreKey(r.Cluster, r.Namespace, r.BrowserId)
+}
+
+// Action is a user action performed through the API.
+type Action struct {
+	ID             uint64     `json:"id"`
+	Time           time.Time  `json:"time"`
+	Type           ActionType `json:"action"`
+	User           string     `json:"user,omitempty"`
+	Cluster        string     `json:"cluster,omitempty"`
+	Namespace      string     `json:"namespace,omitempty"`
+	BrowserId      string     `json:"browserId,omitempty"`
+	BrowserName    string     `json:"browserName,omitempty"`
+	BrowserVersion string     `json:"browserVersion,omitempty"`
+	Outcome        string     `json:"outcome,omitempty"`
+}
+
+// Query filters history records. Zero values match everything; Limit 0 means no limit.
+type Query struct {
+	Cluster        string
+	Namespace      string
+	BrowserId      string
+	BrowserName    string
+	BrowserVersion string
+	Owner          string
+	Action         ActionType
+	Since          time.Time
+	Until          time.Time
+	Limit          int
+}
+
+func (q Query) matchTime(t time.Time) bool {
+	if !q.Since.IsZero() && t.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !t.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+func (q Query) matchSession(r *SessionRecord) bool {
+	return (q.Cluster == "" || r.Cluster == q.Cluster) &&
+		(q.Namespace == "" || r.Namespace == q.Namespace) &&
+		(q.BrowserId == "" || r.BrowserId == q.BrowserId) &&
+		(q.BrowserName == "" || r.BrowserName == q.BrowserName) &&
+		(q.BrowserVersion == "" || r.BrowserVersion == q.BrowserVersion) &&
+		(q.Owner == "" || r.Owner == q.Owner) &&
+		q.matchTime(r.CreatedAt)
+}
+
+func (q Query) matchAction(a *Action) bool {
+	return (q.Cluster == "" || a.Cluster == q.Cluster) &&
+		(q.Namespace == "" || a.Namespace == q.Namespace) &&
+		(q.BrowserId == "" || a.BrowserId == q.BrowserId) &&
+		(q.BrowserName == "" || a.BrowserName == q.BrowserName) &&
+		(q.BrowserVersion == "" || a.BrowserVersion == q.BrowserVersion) &&
+		(q.Owner == "" || a.User == q.Owner) &&
+		(q.Action == "" || a.Type == q.Action) &&
+		q.matchTime(a.Time)
+}
+
+// Recorder receives session lifecycle events and user actions. Implementations
+// must not block the caller for long and report their own failures.
+type Recorder interface {
+	RecordSession(ctx context.Context, ev SessionEvent)
+	RecordAction(ctx context.Context, action Action)
+}
+
+type Store interface {
+	Recorder
+	Sessions(ctx context.Context, q Query) ([]SessionRecord, error)
+	Actions(ctx context.Context, q Query) ([]Action, error)
+	DeleteBefore(ctx context.Context, t time.Time) (int, error)
+	Close() error
+}
+
+type discard struct{}
+
+func (discard) RecordSession(context.Context, SessionEvent) {}
+func (discard) RecordAction(context.Context, Action)        {}
+
+// Discard is a Recorder that drops everything; it is used when history is disabled.
+var Discard Recorder = discard{}
+
+type multi []Recorder
+
+func (m multi) RecordSession(ctx context.Context, ev SessionEvent) {
+	for _, r := range m {
+		r.RecordSession(ctx, ev)
+	}
+}
+
+func (m multi) RecordAction(ctx context.Context, action Action) {
+	for _, r := range m {
+		r.RecordAction(ctx, action)
+	}
+}
+
+// Multi returns a Recorder that forwards to every recorder in order.
+func Multi(recorders ...Recorder) Recorder {
+	switch len(recorders) {
+	case 0:
+		return Discard
+	case 1:
+		return recorders[0]
+	}
+	return multi(recorders)
+}
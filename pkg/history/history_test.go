@@ -0,0 +1,338 @@
+package history
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/types"
+	"github.com/alcounit/selenosis/v2/pkg/auth"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func openTestStore(t *testing.T) *BoltStore {
+	t.Helper()
+	st, err := OpenBoltStore(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func testSession(browserId, owner string, created time.Time) *types.Session {
+	start := metav1.NewTime(created)
+	return &types.Session{
+		BrowserId:      browserId,
+		BrowserName:    "chrome",
+		BrowserVersion: "123",
+		Owner:          owner,
+		StartTime:      &start,
+		Phase:          corev1.PodPending,
+	}
+}
+
+func TestBoltStoreSessionLifecycle(t *testing.T) {
+	st := openTestStore(t)
+	ctx := context.Background()
+
+	created := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	sess := testSession("browser-1", "alice", created)
+
+	st.RecordSession(ctx, SessionEvent{Type: SessionCreated, Time: created.Add(time.Second), Session: sess})
+
+	running := *sess
+	running.SessionId = "sid"
+	running.Phase = corev1.PodRunning
+	st.RecordSession(ctx, SessionEvent{Type: SessionRunning, Time: created.Add(5 * time.Second), Session: &running})
+	// A replay after a collector resync must not move timestamps.
+	st.RecordSession(ctx, SessionEvent{Type: SessionCreated, Time: created.Add(time.Hour), Session: &running})
+	st.RecordSession(ctx, SessionEvent{Type: SessionRunning, Time: created.Add(time.Hour), Session: &running})
+
+	// Pruned sessions only carry the name, the rest must be kept.
+	st.RecordSession(ctx, SessionEvent{Type: SessionDeleted, Time: created.Add(time.Minute), Session: &types.Session{BrowserId: "browser-1"}})
+
+	records, err := st.Sessions(ctx, Query{})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+
+	r := records[0]
+	if !r.CreatedAt.Equal(created) {
+		t.Fatalf("expected createdAt %v, got %v", created, r.CreatedAt)
+	}
+	if r.RunningAt == nil || !r.RunningAt.Equal(created.Add(5*time.Second)) {
+		t.Fatalf("expected runningAt %v, got %v", created.Add(5*time.Second), r.RunningAt)
+	}
+	if r.DeletedAt == nil || !r.DeletedAt.Equal(created.Add(time.Minute)) {
+		t.Fatalf("expected deletedAt %v, got %v", created.Add(time.Minute), r.DeletedAt)
+	}
+	if r.DurationSeconds != 60 {
+		t.Fatalf("expected duration 60s, got %v", r.DurationSeconds)
+	}
+	if r.Owner != "alice" || r.BrowserName != "chrome" || r.BrowserVersion != "123" || r.SessionId != "sid" {
+		t.Fatalf("unexpected record: %+v", r)
+	}
+}
+
+func TestBoltStoreSessionsQuery(t *testing.T) {
+	st := openTestStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	st.RecordSession(ctx, SessionEvent{Type: SessionCreated, Time: base, Session: testSession("b1", "alice", base)})
+	st.RecordSession(ctx, SessionEvent{Type: SessionCreated, Time: base, Session: testSession("b2", "bob", base.Add(time.Hour))})
+	st.RecordSession(ctx, SessionEvent{Type: SessionCreated, Time: base, Session: testSession("b3", "alice", base.Add(2*time.Hour))})
+
+	records, err := st.Sessions(ctx, Query{Owner: "alice"})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(records) != 2 || records[0].BrowserId != "b3" || records[1].BrowserId != "b1" {
+		t.Fatalf("expected alice's sessions newest first, got %+v", records)
+	}
+
+	records, _ = st.Sessions(ctx, Query{Since: base.Add(30 * time.Minute), Until: base.Add(2 * time.Hour)})
+	if len(records) != 1 || records[0].BrowserId != "b2" {
+		t.Fatalf("expected only b2 in range, got %+v", records)
+	}
+
+	records, _ = st.Sessions(ctx, Query{Limit: 1})
+	if len(records) != 1 || records[0].BrowserId != "b3" {
+		t.Fatalf("expected newest session only, got %+v", records)
+	}
+}
+
+func TestBoltStoreSessionsKeyedByNamespaceAndCluster(t *testing.T) {
+	st := openTestStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, target := range []struct{ cluster, namespace, owner string }{
+		{"eu", "default", "alice"},
+		{"eu", "qa", "bob"},
+		{"us", "default", "carol"},
+	} {
+		sess := testSession("b1", target.owner, base)
+		sess.Cluster, sess.Namespace = target.cluster, target.namespace
+		st.RecordSession(ctx, SessionEvent{Type: SessionCreated, Time: base, Session: sess})
+	}
+	deleted := &types.Session{Cluster: "eu", Namespace: "qa", BrowserId: "b1"}
+	st.RecordSession(ctx, SessionEvent{Type: SessionDeleted, Time: base.Add(time.Minute), Session: deleted})
+
+	records, _ := st.Sessions(ctx, Query{BrowserId: "b1"})
+	if len(records) != 3 {
+		t.Fatalf("expected a record per namespace and cluster, got %+v", records)
+	}
+
+	records, _ = st.Sessions(ctx, Query{Cluster: "eu", Namespace: "qa"})
+	if len(records) != 1 || records[0].Owner != "bob" || records[0].DeletedAt == nil {
+		t.Fatalf("expected bob's deleted session in eu/qa only, got %+v", records)
+	}
+
+	records, _ = st.Sessions(ctx, Query{Namespace: "default"})
+	if len(records) != 2 || records[0].Namespace != "default" || records[1].Namespace != "default" {
+		t.Fatalf("expected the default namespace sessions of both clusters, got %+v", records)
+	}
+}
+
+func TestBoltStoreActions(t *testing.T) {
+	st := openTestStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	st.RecordAction(ctx, Action{Time: base, Type: ActionCreate, User: "alice", BrowserId: "b1"})
+	st.RecordAction(ctx, Action{Time: base.Add(time.Minute), Type: ActionVNC, User: "bob", BrowserId: "b1"})
+	st.RecordAction(ctx, Action{Time: base.Add(2 * time.Minute), Type: ActionDelete, User: "alice", BrowserId: "b1"})
+
+	actions, err := st.Actions(ctx, Query{})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(actions) != 3 || actions[0].Type != ActionDelete || actions[2].Type != ActionCreate {
+		t.Fatalf("expected actions newest first, got %+v", actions)
+	}
+	if actions[0].ID <= actions[2].ID {
+		t.Fatalf("expected increasing ids, got %d and %d", actions[2].ID, actions[0].ID)
+	}
+
+	actions, _ = st.Actions(ctx, Query{Owner: "alice", Action: ActionCreate})
+	if len(actions) != 1 || actions[0].User != "alice" {
+		t.Fatalf("expected alice's create only, got %+v", actions)
+	}
+}
+
+func TestBoltStoreActionsFilterByNamespaceAndCluster(t *testing.T) {
+	st := openTestStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	st.RecordAction(ctx, Action{Time: base, Type: ActionCreate, Cluster: "eu", Namespace: "qa", BrowserId: "b1"})
+	st.RecordAction(ctx, Action{Time: base, Type: ActionCreate, Cluster: "eu", Namespace: "dev", BrowserId: "b1"})
+	st.RecordAction(ctx, Action{Time: base, Type: ActionCreate, Cluster: "us", Namespace: "qa", BrowserId: "b1"})
+
+	actions, err := st.Actions(ctx, Query{Cluster: "eu", Namespace: "qa", BrowserId: "b1"})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(actions) != 1 || actions[0].Cluster != "eu" || actions[0].Namespace != "qa" {
+		t.Fatalf("expected only the eu/qa action, got %+v", actions)
+	}
+}
+
+func TestBoltStoreDeleteBefore(t *testing.T) {
+	st := openTestStore(t)
+	ctx := context.Background()
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	st.RecordSession(ctx, SessionEvent{Type: SessionCreated, Time: base, Session: testSession("old", "", base)})
+	st.RecordSession(ctx, SessionEvent{Type: SessionDeleted, Time: base.Add(time.Minute), Session: &types.Session{BrowserId: "old"}})
+	st.RecordSession(ctx, SessionEvent{Type: SessionCreated, Time: base, Session: testSession("alive", "", base)})
+	for i := 0; i < 3; i++ {
+		st.RecordAction(ctx, Action{Time: base.Add(time.Duration(i) * time.Hour), Type: ActionVNC})
+	}
+
+	removed, err := st.DeleteBefore(ctx, base.Add(90*time.Minute))
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if removed != 3 {
+		t.Fatalf("expected 3 records removed, got %d", removed)
+	}
+
+	records, _ := st.Sessions(ctx, Query{})
+	if len(records) != 1 || records[0].BrowserId != "alive" {
+		t.Fatalf("expected only the live session to remain, got %+v", records)
+	}
+	actions, _ := st.Actions(ctx, Query{})
+	if len(actions) != 1 {
+		t.Fatalf("expected 1 action to remain, got %d", len(actions))
+	}
+}
+
+func TestHandlerSessionsJSON(t *testing.T) {
+	st := openTestStore(t)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	st.RecordSession(context.Background(), SessionEvent{Type: SessionCreated, Time: base, Session: testSession("b1", "alice", base)})
+	st.RecordSession(context.Background(), SessionEvent{Type: SessionCreated, Time: base, Session: testSession("b2", "bob", base)})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/history?browserName=chrome", nil)
+	rw := httptest.NewRecorder()
+	NewHandler(st).Sessions(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rw.Code)
+	}
+
+	var resp struct {
+		Sessions []SessionRecord `json:"sessions"`
+	}
+	if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(resp.Sessions))
+	}
+}
+
+func TestHandlerSessionsScopedToOwner(t *testing.T) {
+	st := openTestStore(t)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	st.RecordSession(context.Background(), SessionEvent{Type: SessionCreated, Time: base, Session: testSession("b1", "alice", base)})
+	st.RecordSession(context.Background(), SessionEvent{Type: SessionCreated, Time: base, Session: testSession("b2", "bob", base)})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/history?owner=bob", nil)
+	req = req.WithContext(auth.WithOwner(req.Context(), auth.Owner{Name: "alice"}))
+	rw := httptest.NewRecorder()
+	NewHandler(st).Sessions(rw, req)
+
+	var resp struct {
+		Sessions []SessionRecord `json:"sessions"`
+	}
+	if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Sessions) != 1 || resp.Sessions[0].Owner != "alice" {
+		t.Fatalf("expected only alice's session, got %+v", resp.Sessions)
+	}
+}
+
+func TestHandlerActionsCSVExport(t *testing.T) {
+	st := openTestStore(t)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	st.RecordAction(context.Background(), Action{Time: base, Type: ActionCreate, User: "alice", BrowserId: "b1", BrowserName: "chrome", Outcome: "success"})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/history/actions?format=csv", nil)
+	rw := httptest.NewRecorder()
+	NewHandler(st).Actions(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rw.Code)
+	}
+	if ct := rw.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Fatalf("expected text/csv, got %q", ct)
+	}
+	if cd := rw.Header().Get("Content-Disposition"); !strings.Contains(cd, "actions.csv") {
+		t.Fatalf("expected attachment filename, got %q", cd)
+	}
+
+	rows, err := csv.NewReader(rw.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected header and 1 row, got %d rows", len(rows))
+	}
+	if rows[1][1] != "2026-01-01T00:00:00Z" || rows[1][2] != "create" || rows[1][3] != "alice" {
+		t.Fatalf("unexpected row: %v", rows[1])
+	}
+}
+
+func TestHandlerInvalidQuery(t *testing.T) {
+	st := openTestStore(t)
+
+	for _, query := range []string{"since=yesterday", "until=1", "limit=-1", "limit=x", "format=xml"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/history?"+query, nil)
+		rw := httptest.NewRecorder()
+		NewHandler(st).Sessions(rw, req)
+
+		if rw.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rw.Code)
+		}
+	}
+}
+
+type countingRecorder struct {
+	sessions, actions int
+}
+
+func (r *countingRecorder) RecordSession(context.Context, SessionEvent) { r.sessions++ }
+func (r *countingRecorder) RecordAction(context.Context, Action)        { r.actions++ }
+
+func TestMultiForwardsToEveryRecorder(t *testing.T) {
+	a, b := &countingRecorder{}, &countingRecorder{}
+	rec := Multi(a, b)
+
+	rec.RecordSession(context.Background(), SessionEvent{Type: SessionCreated})
+	rec.RecordAction(context.Background(), Action{Type: ActionVNC})
+
+	if a.sessions != 1 || b.sessions != 1 || a.actions != 1 || b.actions != 1 {
+		t.Fatalf("expected both recorders to receive one of each, got %+v %+v", a, b)
+	}
+	if Multi() != Discard {
+		t.Fatal("expected Multi() to be Discard")
+	}
+	if Multi(a) != Recorder(a) {
+		t.Fatal("expected a single recorder to be returned as is")
+	}
+}
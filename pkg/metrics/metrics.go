@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"github.com/alcounit/browser-ui/pkg/types"
+	"github.com/alcounit/seleniferous/v2/pkg/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "browser_ui"
+
+const (
+	OutcomeSuccess    = "success"
+	OutcomeBadRequest = "bad_request"
+	OutcomeTimeout    = "timeout"
+	OutcomeError      = "error"
+)
+
+const (
+	DirectionClientToBackend = "client_to_backend"
+	DirectionBackendToClient = "backend_to_client"
+)
+
+var (
+	CreateBrowserDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "create_browser_duration_seconds",
+		Help:      "Duration of CreateBrowser requests by outcome.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 180, 300},
+	}, []string{"outcome"})
+
+	WaitForSessionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "wait_for_session_duration_seconds",
+		Help:      "Time spent waiting for a created browser to appear in the session store.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 180, 300},
+	}, []string{"outcome"})
+
+	VNCConnectionsOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "vnc_connections_open",
+		Help:      "Number of currently proxied VNC connections.",
+	})
+
+	VNCBytesProxied = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vnc_bytes_proxied_total",
+		Help:      "Bytes proxied over VNC connections by direction.",
+	}, []string{"direction"})
+
+	VNCDisconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vnc_disconnects_total",
+		Help:      "Proxied VNC connections that ended, by reason.",
+	}, []string{"reason"})
+
+	VNCMessagesProxied = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vnc_messages_proxied_total",
+		Help:      "Websocket messages proxied over VNC connections by direction.",
+	}, []string{"direction"})
+
+	VNCConnectionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "vnc_connection_duration_seconds",
+		Help:      "How long proxied VNC connections stayed open, by close reason.",
+		Buckets:   []float64{1, 10, 30, 60, 300, 600, 1800, 3600, 7200, 14400},
+	}, []string{"close_reason"})
+
+	CollectorReconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "collector_reconnects_total",
+		Help:      "Number of times the event collector reconnected to browser-service.",
+	})
+
+	CollectorPruned = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "collector_pruned_total",
+		Help:      "Stale store entries removed during collector resync by resource kind.",
+	}, []string{"kind"})
+
+	CollectorItemErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "collector_item_errors_total",
+		Help:      "Items the collector skipped by resource kind and reason.",
+	}, []string{"kind", "reason"})
+
+	CollectorInvalidBrowsers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "collector_invalid_browsers",
+		Help:      "Browsers currently skipped because their pod IP could not be parsed by cluster.",
+	}, []string{"cluster"})
+
+	ClusterReady = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cluster_ready",
+		Help:      "1 while a cluster's collector and browser-service are healthy, by cluster.",
+	}, []string{"cluster"})
+
+	CollectorEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "collector_events_total",
+		Help:      "Events processed by the collector by resource kind and event type.",
+	}, []string{"kind", "type"})
+
+	HistoryWriteErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "history_write_errors_total",
+		Help:      "Session history and action records that could not be persisted.",
+	})
+
+	AuditSinkErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_sink_errors_total",
+		Help:      "Audit events a sink failed to write or deliver by sink.",
+	}, []string{"sink"})
+
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts by webhook and result (success, retry, dead_letter, dropped).",
+	}, []string{"webhook", "result"})
+
+	LoginFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_failures_total",
+		Help:      "Rejected logins by reason (invalid_credentials, locked_out).",
+	}, []string{"reason"})
+
+	LoginLockouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_lockouts_total",
+		Help:      "Lockouts started after repeated failed logins by scope (ip, user).",
+	}, []string{"scope"})
+
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Requests rejected with 429 by limiter scope.",
+	}, []string{"scope"})
+)
+
+var sessionsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "sessions"),
+	"Active sessions by browser, version and phase.",
+	[]string{"browser", "version", "phase"}, nil,
+)
+
+// SessionCollector reports session gauges computed from the session store at scrape time,
+// so phase transitions and deletions never leave stale series behind.
+type SessionCollector struct {
+	sessionStore store.Store[*types.Session]
+}
+
+func NewSessionCollector(sessionStore store.Store[*types.Session]) *SessionCollector {
+	return &SessionCollector{sessionStore: sessionStore}
+}
+
+func (c *SessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sessionsDesc
+}
+
+func (c *SessionCollector) Collect(ch chan<- prometheus.Metric) {
+	type key struct{ browser, version, phase string }
+
+	counts := map[key]int{}
+	for _, sess := range c.sessionStore.List() {
+		counts[key{sess.BrowserName, sess.BrowserVersion, string(sess.Phase)}]++
+	}
+
+	for k, n := range counts {
+		ch <- prometheus.MustNewConstMetric(sessionsDesc, prometheus.GaugeValue, float64(n), k.browser, k.version, k.phase)
+	}
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alcounit/browser-ui/pkg/types"
+	"github.com/alcounit/seleniferous/v2/pkg/store"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSessionCollectorGroupsByBrowserVersionPhase(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("b1", &types.Session{BrowserName: "chrome", BrowserVersion: "123", Phase: corev1.PodRunning})
+	st.Set("b2", &types.Session{BrowserName: "chrome", BrowserVersion: "123", Phase: corev1.PodRunning})
+	st.Set("b3", &types.Session{BrowserName: "firefox", BrowserVersion: "100", Phase: corev1.PodPending})
+
+	expected := `
+# HELP browser_ui_sessions Active sessions by browser, version and phase.
+# TYPE browser_ui_sessions gauge
+browser_ui_sessions{browser="chrome",phase="Running",version="123"} 2
+browser_ui_sessions{browser="firefox",phase="Pending",version="100"} 1
+`
+	if err := testutil.CollectAndCompare(NewSessionCollector(st), strings.NewReader(expected)); err != nil {
+		t.Fatalf("unexpected metrics: %v", err)
+	}
+}
+
+func TestSessionCollectorDropsDeletedSessions(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("b1", &types.Session{BrowserName: "chrome", BrowserVersion: "123", Phase: corev1.PodRunning})
+	col := NewSessionCollector(st)
+
+	if n := testutil.CollectAndCount(col); n != 1 {
+		t.Fatalf("expected 1 series, got %d", n)
+	}
+
+	st.Delete("b1")
+	if n := testutil.CollectAndCount(col); n != 0 {
+		t.Fatalf("expected no series after delete, got %d", n)
+	}
+}
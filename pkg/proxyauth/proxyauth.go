@@ -0,0 +1,139 @@
+// Package proxyauth trusts the user name set by an authenticating reverse
+// proxy such as oauth2-proxy, for requests that come from the proxy.
+package proxyauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrUntrusted is returned for requests from outside the trusted CIDRs;
+	// their headers are ignored.
+	ErrUntrusted = errors.New("request did not come from a trusted proxy")
+	ErrNoUser    = errors.New("proxy did not send a user")
+	ErrGroup     = errors.New("user is not in an allowed group")
+)
+
+// Authenticator reads the user, and optionally the groups, from headers set
+// by a proxy in one of the trusted prefixes.
+type Authenticator struct {
+	trusted       []netip.Prefix
+	userHeader    string
+	groupsHeader  string
+	allowedGroups []string
+}
+
+type Option func(*Authenticator)
+
+// WithUserHeader sets the header carrying the user name, X-Forwarded-User by
+// default.
+func WithUserHeader(name string) Option {
+	return func(a *Authenticator) { a.userHeader = name }
+}
+
+// WithGroupsHeader sets the header carrying the comma-separated groups of the
+// user, X-Forwarded-Groups by default.
+func WithGroupsHeader(name string) Option {
+	return func(a *Authenticator) { a.groupsHeader = name }
+}
+
+// WithAllowedGroups admits only users in at least one of groups.
+func WithAllowedGroups(groups ...string) Option {
+	return func(a *Authenticator) { a.allowedGroups = groups }
+}
+
+// New trusts proxies in cidrs, given as prefixes ("10.0.0.0/8") or single
+// addresses.
+func New(cidrs []string, opts ...Option) (*Authenticator, error) {
+	a := &Authenticator{
+		userHeader:   "X-Forwarded-User",
+		groupsHeader: "X-Forwarded-Groups",
+	}
+	for _, cidr := range cidrs {
+		prefix, err := ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		a.trusted = append(a.trusted, prefix)
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a, nil
+}
+
+// ParsePrefix parses a CIDR or a single IP address.
+func ParsePrefix(cidr string) (netip.Prefix, error) {
+	if prefix, err := netip.ParsePrefix(cidr); err == nil {
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid trusted proxy %q: must be a CIDR or an IP address", cidr)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Authenticate returns the user and groups the proxy sent with req.
+func (a *Authenticator) Authenticate(req *http.Request) (string, []string, error) {
+	if !a.Trusted(req) {
+		return "", nil, ErrUntrusted
+	}
+	user := strings.TrimSpace(req.Header.Get(a.userHeader))
+	if user == "" {
+		return "", nil, ErrNoUser
+	}
+
+	var groups []string
+	for _, group := range strings.Split(req.Header.Get(a.groupsHeader), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	if len(a.allowedGroups) > 0 && !slices.ContainsFunc(groups, func(g string) bool {
+		return slices.Contains(a.allowedGroups, g)
+	}) {
+		return user, groups, ErrGroup
+	}
+	return user, groups, nil
+}
+
+// Trusted reports whether req was sent by a trusted proxy, judged by the
+// address of the connection itself.
+func (a *Authenticator) Trusted(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	return slices.ContainsFunc(a.trusted, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// UserHeader is the header the proxy sets, so that callers can tell whether
+// an untrusted request tried to claim a user.
+func (a *Authenticator) UserHeader() string {
+	return a.userHeader
+}
+
+type groupsKey struct{}
+
+// WithGroups stores the groups the proxy sent for the user.
+func WithGroups(ctx context.Context, groups []string) context.Context {
+	return context.WithValue(ctx, groupsKey{}, groups)
+}
+
+func GroupsFrom(ctx context.Context) []string {
+	groups, _ := ctx.Value(groupsKey{}).([]string)
+	return groups
+}
@@ -0,0 +1,73 @@
+package proxyauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func request(remoteAddr string, headers map[string]string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func TestAuthenticateTrustsOnlyConfiguredProxies(t *testing.T) {
+	a, err := New([]string{"10.0.0.0/8", "192.168.1.5", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, addr := range []string{"10.1.2.3:4000", "192.168.1.5:80", "[fd00::1]:443", "[::ffff:10.0.0.1]:80"} {
+		user, _, err := a.Authenticate(request(addr, map[string]string{"X-Forwarded-User": "alice"}))
+		if err != nil || user != "alice" {
+			t.Fatalf("expected alice from %s, got %q, %v", addr, user, err)
+		}
+	}
+
+	for _, addr := range []string{"192.168.1.6:80", "127.0.0.1:80", "[::1]:80", "garbage"} {
+		if _, _, err := a.Authenticate(request(addr, map[string]string{"X-Forwarded-User": "alice"})); !errors.Is(err, ErrUntrusted) {
+			t.Fatalf("expected ErrUntrusted from %s, got %v", addr, err)
+		}
+	}
+
+	if _, _, err := a.Authenticate(request("10.1.2.3:4000", nil)); !errors.Is(err, ErrNoUser) {
+		t.Fatalf("expected ErrNoUser, got %v", err)
+	}
+}
+
+func TestAuthenticateGroups(t *testing.T) {
+	a, err := New([]string{"10.0.0.0/8"},
+		WithUserHeader("X-Auth-Request-User"),
+		WithGroupsHeader("X-Auth-Request-Groups"),
+		WithAllowedGroups("qa", "admins"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	user, groups, err := a.Authenticate(request("10.0.0.1:80", map[string]string{
+		"X-Auth-Request-User":   "alice",
+		"X-Auth-Request-Groups": "dev, qa ,",
+	}))
+	if err != nil || user != "alice" || !slices.Equal(groups, []string{"dev", "qa"}) {
+		t.Fatalf("expected alice in dev and qa, got %q, %v, %v", user, groups, err)
+	}
+
+	if _, _, err := a.Authenticate(request("10.0.0.1:80", map[string]string{
+		"X-Auth-Request-User":   "bob",
+		"X-Auth-Request-Groups": "dev",
+	})); !errors.Is(err, ErrGroup) {
+		t.Fatalf("expected ErrGroup, got %v", err)
+	}
+}
+
+func TestNewRejectsInvalidCIDR(t *testing.T) {
+	if _, err := New([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatalf("expected error for invalid CIDR")
+	}
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/alcounit/browser-ui/pkg/proxyauth"
+)
+
+// ClientIP finds the address of the client behind trusted proxies. On
+// connections from a trusted proxy it walks X-Forwarded-For from the right
+// and returns the first address that is not itself a trusted proxy; anything
+// else is judged by the connection's own address, so clients cannot pick
+// their key by sending the header themselves.
+type ClientIP struct {
+	trusted []netip.Prefix
+}
+
+// NewClientIP trusts X-Forwarded-For from proxies in cidrs, given as prefixes
+// or single addresses.
+func NewClientIP(cidrs []string) (*ClientIP, error) {
+	c := &ClientIP{}
+	for _, cidr := range cidrs {
+		prefix, err := proxyauth.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		c.trusted = append(c.trusted, prefix)
+	}
+	return c, nil
+}
+
+func (c *ClientIP) IP(req *http.Request) string {
+	host := remoteHost(req)
+	if !c.isTrusted(host) {
+		return host
+	}
+
+	var hops []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if _, err := netip.ParseAddr(hops[i]); err != nil {
+			break
+		}
+		if !c.isTrusted(hops[i]) {
+			return hops[i]
+		}
+	}
+	return host
+}
+
+type clientIPKey struct{}
+
+// Middleware records the client IP of each request for RequestIP.
+func (c *ClientIP) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx := context.WithValue(req.Context(), clientIPKey{}, c.IP(req))
+		next.ServeHTTP(rw, req.WithContext(ctx))
+	})
+}
+
+// RequestIP returns the client IP Middleware recorded for req, or the
+// connection's address when it did not run.
+func RequestIP(req *http.Request) string {
+	if ip, ok := req.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteHost(req)
+}
+
+// remoteHost returns the connection's address without the port.
+func remoteHost(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+func (c *ClientIP) isTrusted(host string) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	return slices.ContainsFunc(c.trusted, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/metrics"
+)
+
+// LoginGuard locks out client IPs and user names that keep failing to log in.
+// Both are tracked, so neither spraying one user from many addresses nor many
+// users from one address gets far. Unknown user names are counted like known
+// ones, so a lockout does not reveal whether a user exists.
+type LoginGuard struct {
+	ips   *Lockout
+	users *Lockout
+}
+
+func NewLoginGuard(ips, users *Lockout) *LoginGuard {
+	return &LoginGuard{ips: ips, users: users}
+}
+
+// Check returns how long a login for user from ip has to wait, or 0.
+func (g *LoginGuard) Check(ip, user string) time.Duration {
+	return max(g.ips.Locked(ip), g.users.Locked(user))
+}
+
+func (g *LoginGuard) Failed(ip, user string) {
+	if g.ips.Fail(ip) > 0 {
+		metrics.LoginLockouts.WithLabelValues("ip").Inc()
+	}
+	if g.users.Fail(user) > 0 {
+		metrics.LoginLockouts.WithLabelValues("user").Inc()
+	}
+}
+
+// CheckIP returns how long requests from ip have to wait, or 0. It is for
+// credentials checked outside the login form, where no user name can be
+// trusted to count against.
+func (g *LoginGuard) CheckIP(ip string) time.Duration {
+	return g.ips.Locked(ip)
+}
+
+// FailedIP records a failure for ip alone, so a forged auth cookie cannot
+// lock out the user it names.
+func (g *LoginGuard) FailedIP(ip string) {
+	if g.ips.Fail(ip) > 0 {
+		metrics.LoginLockouts.WithLabelValues("ip").Inc()
+	}
+}
+
+func (g *LoginGuard) Succeeded(ip, user string) {
+	g.ips.Reset(ip)
+	g.users.Reset(user)
+}
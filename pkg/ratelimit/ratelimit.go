@@ -0,0 +1,191 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/alcounit/browser-ui/pkg/metrics"
+)
+
+// sweepEvery is how many calls pass between removals of idle entries.
+const sweepEvery = 1024
+
+// Limiter is a token bucket per key: each key may make limit requests at once
+// and regains one every window/limit.
+type Limiter struct {
+	limit    float64
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:    float64(limit),
+		interval: window / time.Duration(limit),
+		now:      time.Now,
+		buckets:  map[string]*bucket{},
+	}
+}
+
+// Allow takes a token for key. When none is left it returns false and how
+// long until the next one is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.limit, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.limit, b.tokens+float64(now.Sub(b.last))/float64(l.interval))
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(l.interval))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that have refilled completely; they behave exactly
+// like a missing one.
+func (l *Limiter) sweep(now time.Time) {
+	if l.calls++; l.calls%sweepEvery != 0 {
+		return
+	}
+	full := time.Duration(l.limit) * l.interval
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware answers 429 with Retry-After once the key returned by keyFunc
+// is out of tokens. scope labels the rejections in metrics.
+func (l *Limiter) Middleware(scope string, keyFunc func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			key := keyFunc(req)
+			if ok, wait := l.Allow(key); !ok {
+				log := logctx.FromContext(req.Context())
+				log.Warn().Str("scope", scope).Str("key", key).Dur("retryAfter", wait).Msg("request rate limited")
+				metrics.RateLimited.WithLabelValues(scope).Inc()
+				rw.Header().Set("Retry-After", RetryAfter(wait))
+				http.Error(rw, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// RetryAfter formats d as whole seconds for the Retry-After header.
+func RetryAfter(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// Lockout counts failures per key and locks a key out once it reaches
+// threshold failures. Every further failure doubles the lockout, up to max.
+// Failures are forgotten once a key has had none for max.
+type Lockout struct {
+	threshold int
+	base      time.Duration
+	max       time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*lockEntry
+	calls   int
+}
+
+type lockEntry struct {
+	failures int
+	last     time.Time
+	until    time.Time
+}
+
+func NewLockout(threshold int, base, maxLock time.Duration) *Lockout {
+	return &Lockout{
+		threshold: threshold,
+		base:      base,
+		max:       maxLock,
+		now:       time.Now,
+		entries:   map[string]*lockEntry{},
+	}
+}
+
+// Locked returns how long key stays locked out, or 0.
+func (l *Lockout) Locked(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+	return max(0, e.until.Sub(l.now()))
+}
+
+// Fail records a failure for key and returns the lockout it starts, or 0
+// while key is still under the threshold.
+func (l *Lockout) Fail(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.last) >= l.max {
+		e = &lockEntry{}
+		l.entries[key] = e
+	}
+	e.failures++
+	e.last = now
+
+	if e.failures < l.threshold {
+		return 0
+	}
+	lock := l.base << min(e.failures-l.threshold, 30)
+	if lock <= 0 || lock > l.max {
+		lock = l.max
+	}
+	e.until = now.Add(lock)
+	return lock
+}
+
+// Reset forgets the failures of key, e.g. after a successful login.
+func (l *Lockout) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+func (l *Lockout) sweep(now time.Time) {
+	if l.calls++; l.calls%sweepEvery != 0 {
+		return
+	}
+	for key, e := range l.entries {
+		if now.Sub(e.last) >= l.max && !now.Before(e.until) {
+			delete(l.entries, key)
+		}
+	}
+}
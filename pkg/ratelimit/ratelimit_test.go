@@ -0,0 +1,237 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newClock() *fakeClock {
+	return &fakeClock{t: time.Unix(1_700_000_000, 0)}
+}
+
+func TestLimiterAllowsBurstThenRefills(t *testing.T) {
+	clock := newClock()
+	l := NewLimiter(3, time.Minute)
+	l.now = clock.now
+
+	for i := range 3 {
+		if ok, _ := l.Allow("alice"); !ok {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+	}
+	ok, wait := l.Allow("alice")
+	if ok {
+		t.Fatalf("expected fourth request to be limited")
+	}
+	if wait != 20*time.Second {
+		t.Fatalf("expected 20s until the next token, got %v", wait)
+	}
+	if ok, _ := l.Allow("bob"); !ok {
+		t.Fatalf("expected other keys to have their own bucket")
+	}
+
+	clock.advance(20 * time.Second)
+	if ok, _ := l.Allow("alice"); !ok {
+		t.Fatalf("expected a token after the refill interval")
+	}
+	if ok, _ := l.Allow("alice"); ok {
+		t.Fatalf("expected only one token to be refilled")
+	}
+}
+
+func TestLimiterMiddlewareRejectsWithRetryAfter(t *testing.T) {
+	l := NewLimiter(1, time.Minute)
+	called := 0
+	h := l.Middleware("browsers", func(*http.Request) string { return "alice" })(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called++
+	}))
+	before := testutil.ToFloat64(metrics.RateLimited.WithLabelValues("browsers"))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/browsers", nil))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/api/v1/browsers", nil))
+
+	if called != 1 {
+		t.Fatalf("expected handler to be called once, got %d", called)
+	}
+	if rw.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, rw.Code)
+	}
+	if got := rw.Header().Get("Retry-After"); got != "60" {
+		t.Fatalf("expected Retry-After 60, got %q", got)
+	}
+	if got := testutil.ToFloat64(metrics.RateLimited.WithLabelValues("browsers")) - before; got != 1 {
+		t.Fatalf("expected 1 rate limited request, got %v", got)
+	}
+}
+
+func TestLockoutIsProgressive(t *testing.T) {
+	clock := newClock()
+	l := NewLockout(3, time.Minute, 5*time.Minute)
+	l.now = clock.now
+
+	for i := range 2 {
+		if d := l.Fail("alice"); d != 0 {
+			t.Fatalf("expected no lockout after %d failures, got %v", i+1, d)
+		}
+	}
+	if d := l.Fail("alice"); d != time.Minute {
+		t.Fatalf("expected 1m lockout at the threshold, got %v", d)
+	}
+	if d := l.Locked("alice"); d != time.Minute {
+		t.Fatalf("expected alice to be locked for 1m, got %v", d)
+	}
+	if d := l.Locked("bob"); d != 0 {
+		t.Fatalf("expected bob not to be locked, got %v", d)
+	}
+
+	if d := l.Fail("alice"); d != 2*time.Minute {
+		t.Fatalf("expected lockout to double, got %v", d)
+	}
+	if d := l.Fail("alice"); d != 4*time.Minute {
+		t.Fatalf("expected lockout to double again, got %v", d)
+	}
+	if d := l.Fail("alice"); d != 5*time.Minute {
+		t.Fatalf("expected lockout to be capped, got %v", d)
+	}
+
+	clock.advance(5 * time.Minute)
+	if d := l.Locked("alice"); d != 0 {
+		t.Fatalf("expected lockout to expire, got %v", d)
+	}
+	if d := l.Fail("alice"); d != 0 {
+		t.Fatalf("expected failures to be forgotten after a quiet period, got %v", d)
+	}
+}
+
+func TestLockoutReset(t *testing.T) {
+	l := NewLockout(1, time.Minute, time.Hour)
+	l.Fail("alice")
+	l.Reset("alice")
+
+	if d := l.Locked("alice"); d != 0 {
+		t.Fatalf("expected reset to lift the lockout, got %v", d)
+	}
+}
+
+func TestLoginGuardLocksByIPAndUser(t *testing.T) {
+	g := NewLoginGuard(NewLockout(3, time.Minute, time.Hour), NewLockout(2, time.Minute, time.Hour))
+	before := testutil.ToFloat64(metrics.LoginLockouts.WithLabelValues("user"))
+
+	// Guessing one user's password from several addresses.
+	g.Failed("10.0.0.1", "alice")
+	g.Failed("10.0.0.2", "alice")
+	if d := g.Check("10.0.0.3", "alice"); d == 0 {
+		t.Fatalf("expected alice to be locked out from any address")
+	}
+	if d := g.Check("10.0.0.3", "bob"); d != 0 {
+		t.Fatalf("expected bob to be allowed, got %v", d)
+	}
+	if got := testutil.ToFloat64(metrics.LoginLockouts.WithLabelValues("user")) - before; got != 1 {
+		t.Fatalf("expected 1 user lockout, got %v", got)
+	}
+
+	// Spraying several user names, existing or not, from one address.
+	g.Failed("10.0.0.9", "carol")
+	g.Failed("10.0.0.9", "dave")
+	g.Failed("10.0.0.9", "nobody")
+	if d := g.Check("10.0.0.9", "erin"); d == 0 {
+		t.Fatalf("expected 10.0.0.9 to be locked out for every user")
+	}
+
+}
+
+func TestLoginGuardSuccessForgetsFailures(t *testing.T) {
+	g := NewLoginGuard(NewLockout(3, time.Minute, time.Hour), NewLockout(3, time.Minute, time.Hour))
+
+	g.Failed("10.0.0.1", "alice")
+	g.Failed("10.0.0.1", "alice")
+	g.Succeeded("10.0.0.1", "alice")
+	g.Failed("10.0.0.1", "alice")
+	g.Failed("10.0.0.1", "alice")
+
+	if d := g.Check("10.0.0.1", "alice"); d != 0 {
+		t.Fatalf("expected failures before a successful login to be forgotten, got %v", d)
+	}
+}
+
+func TestLoginGuardFailedIPLeavesUserUnlocked(t *testing.T) {
+	g := NewLoginGuard(NewLockout(2, time.Minute, time.Hour), NewLockout(2, time.Minute, time.Hour))
+
+	g.FailedIP("10.0.0.1")
+	g.FailedIP("10.0.0.1")
+
+	if d := g.CheckIP("10.0.0.1"); d == 0 {
+		t.Fatalf("expected 10.0.0.1 to be locked out")
+	}
+	if d := g.Check("10.0.0.2", "alice"); d != 0 {
+		t.Fatalf("expected no user to be locked out, got %v", d)
+	}
+}
+
+func TestClientIPTrustsForwardedForOnlyFromProxies(t *testing.T) {
+	c, err := NewClientIP([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct client", "192.0.2.1:1234", nil, "192.0.2.1"},
+		{"spoofed header from untrusted address", "192.0.2.1:1234", []string{"198.51.100.7"}, "192.0.2.1"},
+		{"behind ingress", "10.0.0.5:1234", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"client prepends a fake hop", "10.0.0.5:1234", []string{"203.0.113.9, 198.51.100.7"}, "198.51.100.7"},
+		{"chained proxies", "10.0.0.5:1234", []string{"198.51.100.7, 10.1.2.3"}, "198.51.100.7"},
+		{"proxy without header", "10.0.0.5:1234", nil, "10.0.0.5"},
+		{"garbage hop", "10.0.0.5:1234", []string{"not-an-ip"}, "10.0.0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if got := c.IP(req); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRequestIPUsesMiddlewareResult(t *testing.T) {
+	c, err := NewClientIP([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "[fd00::1]:80"
+	if got := RequestIP(req); got != "fd00::1" {
+		t.Fatalf("expected the connection's address without middleware, got %q", got)
+	}
+
+	req.RemoteAddr = "10.0.0.5:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	var got string
+	c.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		got = RequestIP(req)
+	})).ServeHTTP(httptest.NewRecorder(), req)
+	if got != "198.51.100.7" {
+		t.Fatalf("expected the forwarded client address, got %q", got)
+	}
+}
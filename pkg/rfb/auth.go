@@ -0,0 +1,239 @@
+package rfb
+
+import (
+	"crypto/des"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"slices"
+)
+
+// ErrAuthFailed is returned when the server rejects the VNC password.
+var ErrAuthFailed = errors.New("vnc authentication failed")
+
+// maxReason caps failure reasons read from a server.
+const maxReason = 4096
+
+// Authenticate performs the client side of the RFB handshake on server up to
+// and including security, answering VNC authentication with password. The
+// caller continues with ClientInit. It returns the minor protocol version
+// agreed with the server.
+func Authenticate(server io.ReadWriter, password string) (int, error) {
+	msg := make([]byte, 12)
+	if _, err := io.ReadFull(server, msg); err != nil {
+		return 0, fmt.Errorf("read server version: %w", err)
+	}
+	major, minor, err := ParseVersion(msg)
+	if err != nil {
+		return 0, err
+	}
+	if major != 3 || minor < 3 {
+		return 0, fmt.Errorf("%w: protocol version %d.%d", ErrUnsupported, major, minor)
+	}
+	minor = knownMinor(minor)
+	if _, err := fmt.Fprintf(server, "RFB 003.%03d\n", minor); err != nil {
+		return 0, err
+	}
+
+	security, err := chooseSecurity(server, minor)
+	if err != nil {
+		return 0, err
+	}
+
+	if security == securityVNC {
+		challenge := make([]byte, 16)
+		if _, err := io.ReadFull(server, challenge); err != nil {
+			return 0, fmt.Errorf("read vnc challenge: %w", err)
+		}
+		if _, err := server.Write(EncryptChallenge(password, challenge)); err != nil {
+			return 0, err
+		}
+	}
+
+	// RFB 3.3 and 3.7 send no result for the None security type.
+	if security == securityNone && minor < 8 {
+		return minor, nil
+	}
+	result := make([]byte, 4)
+	if _, err := io.ReadFull(server, result); err != nil {
+		return 0, fmt.Errorf("read security result: %w", err)
+	}
+	if binary.BigEndian.Uint32(result) != 0 {
+		if minor >= 8 {
+			if reason, err := readReason(server); err == nil && reason != "" {
+				return 0, fmt.Errorf("%w: %s", ErrAuthFailed, reason)
+			}
+		}
+		return 0, ErrAuthFailed
+	}
+	return minor, nil
+}
+
+// chooseSecurity picks VNC authentication, or None, from what the server
+// offers.
+func chooseSecurity(server io.ReadWriter, minor int) (byte, error) {
+	if minor == 3 {
+		// The server decides on its own in RFB 3.3.
+		msg := make([]byte, 4)
+		if _, err := io.ReadFull(server, msg); err != nil {
+			return 0, fmt.Errorf("read security type: %w", err)
+		}
+		switch t := binary.BigEndian.Uint32(msg); t {
+		case 0:
+			return 0, serverRefused(server)
+		case securityNone, securityVNC:
+			return byte(t), nil
+		default:
+			return 0, fmt.Errorf("%w: security type %d", ErrUnsupported, t)
+		}
+	}
+
+	count := make([]byte, 1)
+	if _, err := io.ReadFull(server, count); err != nil {
+		return 0, fmt.Errorf("read security types: %w", err)
+	}
+	if count[0] == 0 {
+		return 0, serverRefused(server)
+	}
+	types := make([]byte, count[0])
+	if _, err := io.ReadFull(server, types); err != nil {
+		return 0, fmt.Errorf("read security types: %w", err)
+	}
+
+	var security byte
+	switch {
+	case slices.Contains(types, securityVNC):
+		security = securityVNC
+	case slices.Contains(types, securityNone):
+		security = securityNone
+	default:
+		return 0, fmt.Errorf("%w: security types %v", ErrUnsupported, types)
+	}
+	if _, err := server.Write([]byte{security}); err != nil {
+		return 0, err
+	}
+	return security, nil
+}
+
+func serverRefused(server io.Reader) error {
+	reason, err := readReason(server)
+	if err != nil {
+		return fmt.Errorf("server refused connection: %w", err)
+	}
+	return fmt.Errorf("server refused connection: %s", reason)
+}
+
+func readReason(r io.Reader) (string, error) {
+	msg := make([]byte, 4)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return "", err
+	}
+	n := binary.BigEndian.Uint32(msg)
+	if n > maxReason {
+		return "", fmt.Errorf("%w: reason of %d bytes", ErrUnsupported, n)
+	}
+	reason := make([]byte, n)
+	if _, err := io.ReadFull(r, reason); err != nil {
+		return "", err
+	}
+	return string(reason), nil
+}
+
+// Accept performs the server side of the RFB handshake on client, offering
+// only the None security type because the proxy has already authenticated
+// to the real server. The client continues with ClientInit.
+func Accept(client io.ReadWriter) error {
+	minor, err := offerVersion(client)
+	if err != nil {
+		return err
+	}
+	if minor == 3 {
+		_, err := client.Write(binary.BigEndian.AppendUint32(nil, securityNone))
+		return err
+	}
+
+	if _, err := client.Write([]byte{1, securityNone}); err != nil {
+		return err
+	}
+	choice := make([]byte, 1)
+	if _, err := io.ReadFull(client, choice); err != nil {
+		return fmt.Errorf("read security type: %w", err)
+	}
+	if choice[0] != securityNone {
+		return fmt.Errorf("%w: client chose security type %d", ErrUnsupported, choice[0])
+	}
+	if minor < 8 {
+		return nil
+	}
+	_, err = client.Write(make([]byte, 4))
+	return err
+}
+
+// Reject performs the server side of the handshake on client only to refuse
+// the connection with reason, which viewers show to the user.
+func Reject(client io.ReadWriter, reason string) error {
+	minor, err := offerVersion(client)
+	if err != nil {
+		return err
+	}
+	var msg []byte
+	if minor == 3 {
+		msg = binary.BigEndian.AppendUint32(msg, 0)
+	} else {
+		msg = append(msg, 0)
+	}
+	msg = binary.BigEndian.AppendUint32(msg, uint32(len(reason)))
+	_, err = client.Write(append(msg, reason...))
+	return err
+}
+
+// offerVersion offers RFB 3.8 to client and returns the minor version it
+// answers with.
+func offerVersion(client io.ReadWriter) (int, error) {
+	if _, err := io.WriteString(client, "RFB 003.008\n"); err != nil {
+		return 0, err
+	}
+	msg := make([]byte, 12)
+	if _, err := io.ReadFull(client, msg); err != nil {
+		return 0, fmt.Errorf("read client version: %w", err)
+	}
+	major, minor, err := ParseVersion(msg)
+	if err != nil {
+		return 0, err
+	}
+	if major != 3 || minor < 3 {
+		return 0, fmt.Errorf("%w: protocol version %d.%d", ErrUnsupported, major, minor)
+	}
+	return knownMinor(minor), nil
+}
+
+// knownMinor maps a 3.x version to the closest of 3.3, 3.7 and 3.8 below it;
+// other versions must be treated as 3.3.
+func knownMinor(minor int) int {
+	switch {
+	case minor >= 8:
+		return 8
+	case minor == 7:
+		return 7
+	default:
+		return 3
+	}
+}
+
+// EncryptChallenge answers a VNC authentication challenge: DES with the
+// first eight bytes of the password as key, each byte bit-reversed.
+func EncryptChallenge(password string, challenge []byte) []byte {
+	key := make([]byte, 8)
+	copy(key, password)
+	for i, b := range key {
+		key[i] = bits.Reverse8(b)
+	}
+	block, _ := des.NewCipher(key) //nolint:errcheck // the key always has the right size
+	response := make([]byte, len(challenge))
+	for i := 0; i+8 <= len(challenge); i += 8 {
+		block.Encrypt(response[i:i+8], challenge[i:i+8])
+	}
+	return response
+}
@@ -0,0 +1,144 @@
+package rfb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// vncServer plays a server offering VNC authentication on conn and reports
+// whether the client answered the challenge with password.
+func vncServer(conn net.Conn, version, password string) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		defer conn.Close()
+		errCh <- func() error {
+			io.WriteString(conn, version)
+			msg := make([]byte, 12)
+			if _, err := io.ReadFull(conn, msg); err != nil {
+				return err
+			}
+			_, minor, _ := ParseVersion(msg)
+			if minor == 3 {
+				conn.Write(binary.BigEndian.AppendUint32(nil, securityVNC))
+			} else {
+				conn.Write([]byte{2, securityNone, securityVNC})
+				choice := make([]byte, 1)
+				if _, err := io.ReadFull(conn, choice); err != nil {
+					return err
+				}
+				if choice[0] != securityVNC {
+					return errors.New("client did not choose vnc authentication")
+				}
+			}
+			challenge := []byte("0123456789abcdef")
+			conn.Write(challenge)
+			response := make([]byte, 16)
+			if _, err := io.ReadFull(conn, response); err != nil {
+				return err
+			}
+			if !bytes.Equal(response, EncryptChallenge(password, challenge)) {
+				result := binary.BigEndian.AppendUint32(nil, 1)
+				if minor >= 8 {
+					result = binary.BigEndian.AppendUint32(result, uint32(len("bad password")))
+					result = append(result, "bad password"...)
+				}
+				conn.Write(result)
+				return nil
+			}
+			_, err := conn.Write(make([]byte, 4))
+			return err
+		}()
+	}()
+	return errCh
+}
+
+func TestAuthenticate(t *testing.T) {
+	for _, version := range []string{"RFB 003.003\n", "RFB 003.007\n", "RFB 003.008\n", "RFB 003.889\n"} {
+		client, server := net.Pipe()
+		errCh := vncServer(server, version, "secret")
+
+		minor, err := Authenticate(client, "secret")
+		if err != nil {
+			t.Fatalf("expected authentication with %q to succeed, got %v", version, err)
+		}
+		_, offered, _ := ParseVersion([]byte(version))
+		if want := knownMinor(offered); minor != want {
+			t.Fatalf("expected minor version %d for %q, got %d", want, version, minor)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("server failed for %q: %v", version, err)
+		}
+		client.Close()
+	}
+}
+
+func TestAuthenticateWrongPassword(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	vncServer(server, "RFB 003.008\n", "secret")
+
+	_, err := Authenticate(client, "wrong")
+	if !errors.Is(err, ErrAuthFailed) || !bytes.Contains([]byte(err.Error()), []byte("bad password")) {
+		t.Fatalf("expected ErrAuthFailed with the server's reason, got %v", err)
+	}
+}
+
+func TestAcceptOffersNone(t *testing.T) {
+	for _, tt := range []struct {
+		version string
+		reply   []byte
+		want    []byte
+	}{
+		{"RFB 003.003\n", nil, []byte{0, 0, 0, securityNone}},
+		{"RFB 003.007\n", []byte{securityNone}, []byte{1, securityNone}},
+		{"RFB 003.008\n", []byte{securityNone}, []byte{1, securityNone, 0, 0, 0, 0}},
+	} {
+		proxy, client := net.Pipe()
+		errCh := make(chan error, 1)
+		go func() { errCh <- Accept(proxy) }()
+
+		version := make([]byte, 12)
+		io.ReadFull(client, version)
+		if string(version) != "RFB 003.008\n" {
+			t.Fatalf("expected RFB 3.8 to be offered, got %q", version)
+		}
+		io.WriteString(client, tt.version)
+		got := make([]byte, len(tt.want))
+		if len(tt.reply) == 0 {
+			io.ReadFull(client, got)
+		} else {
+			io.ReadFull(client, got[:2])
+			client.Write(tt.reply)
+			io.ReadFull(client, got[2:])
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Fatalf("expected %v for %q, got %v", tt.want, tt.version, got)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("expected accept to succeed for %q, got %v", tt.version, err)
+		}
+		proxy.Close()
+		client.Close()
+	}
+}
+
+func TestRejectSendsReason(t *testing.T) {
+	proxy, client := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer proxy.Close()
+		Reject(proxy, "no password")
+	}()
+
+	io.ReadFull(client, make([]byte, 12))
+	io.WriteString(client, "RFB 003.008\n")
+	got, _ := io.ReadAll(client)
+	want := append([]byte{0, 0, 0, 0, byte(len("no password"))}, "no password"...)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,195 @@
+// Package rfb understands just enough of the RFB (VNC) protocol for the proxy
+// to inspect what a client sends and to authenticate on its behalf.
+package rfb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrUnsupported is returned for protocol versions, security types and
+// messages the filter cannot parse; the connection must be closed then.
+var ErrUnsupported = errors.New("unsupported rfb stream")
+
+const (
+	securityNone = 1
+	securityVNC  = 2
+)
+
+type state int
+
+const (
+	stateVersion state = iota
+	stateSecurityType
+	stateVNCAuthResponse
+	stateClientInit
+	stateMessages
+)
+
+// ViewOnly filters the client-to-server half of an RFB connection: the
+// handshake and display requests pass, while keyboard, pointer, clipboard
+// and desktop-size messages are dropped.
+//
+// Only RFB 3.7 and later with the None or VNC security types are supported,
+// since for anything else the client's part of the handshake cannot be told
+// apart from the messages that follow it.
+type ViewOnly struct {
+	state state
+	buf   []byte
+	// skip counts bytes of a dropped message that have not arrived yet.
+	skip int
+}
+
+// HandshakeDone tells the filter that the client's handshake up to ClientInit
+// has already taken place, e.g. with Accept.
+func (f *ViewOnly) HandshakeDone() {
+	f.state = stateClientInit
+}
+
+// Filter takes the next chunk of client data and returns what may be
+// forwarded to the server. Incomplete messages are held back until the
+// rest arrives.
+func (f *ViewOnly) Filter(p []byte) ([]byte, error) {
+	if f.skip > 0 {
+		n := min(f.skip, len(p))
+		f.skip -= n
+		p = p[n:]
+	}
+	f.buf = append(f.buf, p...)
+
+	var out []byte
+	for len(f.buf) > 0 {
+		size, forward, next, err := f.next()
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 || (size > len(f.buf) && forward) {
+			break
+		}
+		f.state = next
+		if size > len(f.buf) {
+			// Dropped messages are discarded as they arrive instead of
+			// being buffered, however long they are.
+			f.skip = size - len(f.buf)
+			f.buf = f.buf[:0]
+			break
+		}
+		if forward {
+			out = append(out, f.buf[:size]...)
+		}
+		f.buf = f.buf[size:]
+	}
+	if len(f.buf) == 0 {
+		f.buf = nil
+	}
+	return out, nil
+}
+
+// next returns the size of the message at the start of buf, whether it is
+// forwarded and the state once it has been consumed. A size of 0 means more
+// data is needed to tell.
+func (f *ViewOnly) next() (int, bool, state, error) {
+	buf := f.buf
+	switch f.state {
+	case stateVersion:
+		if len(buf) < 12 {
+			return 0, false, f.state, nil
+		}
+		major, minor, err := ParseVersion(buf[:12])
+		if err != nil {
+			return 0, false, f.state, err
+		}
+		if major != 3 || minor < 7 {
+			return 0, false, f.state, fmt.Errorf("%w: protocol version %d.%d", ErrUnsupported, major, minor)
+		}
+		return 12, true, stateSecurityType, nil
+
+	case stateSecurityType:
+		switch buf[0] {
+		case securityNone:
+			return 1, true, stateClientInit, nil
+		case securityVNC:
+			return 1, true, stateVNCAuthResponse, nil
+		default:
+			return 0, false, f.state, fmt.Errorf("%w: security type %d", ErrUnsupported, buf[0])
+		}
+
+	case stateVNCAuthResponse:
+		return 16, true, stateClientInit, nil
+
+	case stateClientInit:
+		return 1, true, stateMessages, nil
+	}
+
+	size, forward, err := messageSize(buf)
+	return size, forward, stateMessages, err
+}
+
+// ParseVersion parses a 12 byte ProtocolVersion message such as
+// "RFB 003.008\n".
+func ParseVersion(msg []byte) (major, minor int, err error) {
+	if len(msg) != 12 || string(msg[:4]) != "RFB " || msg[7] != '.' || msg[11] != '\n' {
+		return 0, 0, fmt.Errorf("%w: bad protocol version %q", ErrUnsupported, msg)
+	}
+	major, err = strconv.Atoi(string(msg[4:7]))
+	if err == nil {
+		minor, err = strconv.Atoi(string(msg[8:11]))
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: bad protocol version %q", ErrUnsupported, msg)
+	}
+	return major, minor, nil
+}
+
+// messageSize sizes the client-to-server message at the start of buf.
+func messageSize(buf []byte) (int, bool, error) {
+	need := func(n int) bool { return len(buf) >= n }
+
+	switch t := buf[0]; t {
+	case 0: // SetPixelFormat
+		return 20, true, nil
+	case 2: // SetEncodings
+		if !need(4) {
+			return 0, false, nil
+		}
+		return 4 + 4*int(binary.BigEndian.Uint16(buf[2:4])), true, nil
+	case 3: // FramebufferUpdateRequest
+		return 10, true, nil
+	case 4: // KeyEvent
+		return 8, false, nil
+	case 5: // PointerEvent
+		return 6, false, nil
+	case 6: // ClientCutText; a negative length marks the extended format.
+		if !need(8) {
+			return 0, false, nil
+		}
+		length := int64(int32(binary.BigEndian.Uint32(buf[4:8])))
+		return 8 + int(max(length, -length)), false, nil
+	case 150: // EnableContinuousUpdates
+		return 10, true, nil
+	case 248: // ClientFence
+		if !need(9) {
+			return 0, false, nil
+		}
+		return 9 + int(buf[8]), true, nil
+	case 250: // xvp, which can reboot or shut down the server
+		return 4, false, nil
+	case 251: // SetDesktopSize
+		if !need(7) {
+			return 0, false, nil
+		}
+		return 8 + 16*int(buf[6]), false, nil
+	case 255: // QEMU, of which only the extended key event is known
+		if !need(2) {
+			return 0, false, nil
+		}
+		if buf[1] != 0 {
+			return 0, false, fmt.Errorf("%w: qemu message %d", ErrUnsupported, buf[1])
+		}
+		return 12, false, nil
+	default:
+		return 0, false, fmt.Errorf("%w: message type %d", ErrUnsupported, t)
+	}
+}
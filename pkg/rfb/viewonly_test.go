@@ -0,0 +1,75 @@
+package rfb
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func handshake(security byte) []byte {
+	msg := []byte("RFB 003.008\n")
+	msg = append(msg, security)
+	if security == securityVNC {
+		msg = append(msg, bytes.Repeat([]byte{0xaa}, 16)...)
+	}
+	return append(msg, 1)
+}
+
+var (
+	setEncodings  = []byte{2, 0, 0, 2, 0, 0, 0, 7, 0, 0, 0, 16}
+	updateRequest = []byte{3, 1, 0, 0, 0, 0, 4, 0, 3, 0}
+	keyEvent      = []byte{4, 1, 0, 0, 0, 0, 0xff, 0x0d}
+	pointerEvent  = []byte{5, 1, 0, 10, 0, 20}
+	cutText       = []byte{6, 0, 0, 0, 0, 0, 0, 3, 'a', 'b', 'c'}
+)
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestViewOnlyDropsInput(t *testing.T) {
+	for _, security := range []byte{securityNone, securityVNC} {
+		f := &ViewOnly{}
+		in := concat(handshake(security), setEncodings, keyEvent, updateRequest, pointerEvent, cutText, updateRequest)
+		want := concat(handshake(security), setEncodings, updateRequest, updateRequest)
+
+		got, err := f.Filter(in)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("security %d: expected %v, got %v", security, want, got)
+		}
+	}
+}
+
+func TestViewOnlyHandlesSplitMessages(t *testing.T) {
+	f := &ViewOnly{}
+	in := concat(handshake(securityVNC), keyEvent, setEncodings, cutText, updateRequest)
+	want := concat(handshake(securityVNC), setEncodings, updateRequest)
+
+	var got []byte
+	for _, b := range in {
+		out, err := f.Filter([]byte{b})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		got = append(got, out...)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestViewOnlyRejectsUnknownStreams(t *testing.T) {
+	for name, in := range map[string][]byte{
+		"rfb 3.3":       []byte("RFB 003.003\n"),
+		"bad version":   []byte("HTTP/1.1 200\n"),
+		"security type": concat([]byte("RFB 003.008\n"), []byte{19}),
+		"message type":  concat(handshake(securityNone), []byte{99, 0, 0, 0}),
+	} {
+		if _, err := (&ViewOnly{}).Filter(in); !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("%s: expected ErrUnsupported, got %v", name, err)
+		}
+	}
+}
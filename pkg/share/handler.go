@@ -0,0 +1,252 @@
+package share
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/alcounit/browser-ui/pkg/audit"
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	"github.com/alcounit/browser-ui/pkg/ratelimit"
+	"github.com/alcounit/browser-ui/pkg/types"
+	"github.com/alcounit/selenosis/v2/pkg/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler serves /api/v1/browsers/{browserId}/share and lets share links
+// through to the VNC proxy.
+type Handler struct {
+	registry   *Registry
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+	lookup     func(req *http.Request, browserId string) (*types.Session, bool)
+	isAdmin    func(req *http.Request) bool
+	auditor    audit.Auditor
+}
+
+type HandlerOption func(*Handler)
+
+// WithTTL sets the lifetime of links created without expiresIn and the
+// longest lifetime a client may ask for.
+func WithTTL(defaultTTL, maxTTL time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.defaultTTL = defaultTTL
+		h.maxTTL = maxTTL
+	}
+}
+
+// WithSessionLookup resolves the session a request refers to; links are only
+// managed for sessions lookup finds.
+func WithSessionLookup(lookup func(req *http.Request, browserId string) (*types.Session, bool)) HandlerOption {
+	return func(h *Handler) { h.lookup = lookup }
+}
+
+// WithAdminCheck lets requests isAdmin accepts manage links to every session;
+// everyone else only manages links to sessions they own. Without it every
+// request may.
+func WithAdminCheck(isAdmin func(req *http.Request) bool) HandlerOption {
+	return func(h *Handler) { h.isAdmin = isAdmin }
+}
+
+func WithAuditor(auditor audit.Auditor) HandlerOption {
+	return func(h *Handler) { h.auditor = auditor }
+}
+
+func NewHandler(registry *Registry, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		registry:   registry,
+		defaultTTL: time.Hour,
+		maxTTL:     24 * time.Hour,
+		lookup: func(_ *http.Request, browserId string) (*types.Session, bool) {
+			return &types.Session{BrowserId: browserId}, true
+		},
+		isAdmin: func(*http.Request) bool { return true },
+		auditor: audit.Discard,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *Handler) Create(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	browserId := chi.URLParam(req, "browserId")
+
+	var body struct {
+		ExpiresIn  string `json:"expiresIn"`
+		ViewOnly   *bool  `json:"viewOnly"`
+		MaxViewers int    `json:"maxViewers"`
+	}
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(rw, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	ttl := h.defaultTTL
+	if body.ExpiresIn != "" {
+		var err error
+		ttl, err = time.ParseDuration(body.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			http.Error(rw, "expiresIn must be a positive duration such as \"1h\"", http.StatusBadRequest)
+			return
+		}
+	}
+	if ttl > h.maxTTL {
+		http.Error(rw, "expiresIn exceeds the maximum of "+h.maxTTL.String(), http.StatusBadRequest)
+		return
+	}
+	if body.MaxViewers < 0 {
+		http.Error(rw, "maxViewers must not be negative", http.StatusBadRequest)
+		return
+	}
+	viewOnly := body.ViewOnly == nil || *body.ViewOnly
+
+	session, ok := h.session(rw, req)
+	if !ok {
+		return
+	}
+
+	owner := ownerName(req)
+	token, link := h.registry.Create(owner, session, ttl, viewOnly, body.MaxViewers)
+	log.Info().Str("browserId", browserId).Str("shareId", link.ID).Bool("viewOnly", viewOnly).Msg("share link created")
+	h.audit(req, audit.ShareCreate, link)
+
+	response := struct {
+		Link
+		Token string `json:"token"`
+		URL   string `json:"url"`
+	}{
+		Link:  link,
+		Token: token,
+		URL:   shareURL(req, link, token),
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(rw).Encode(&response); err != nil {
+		log.Error().Err(err).Msg("failed to encode share response")
+	}
+}
+
+func (h *Handler) List(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	session, ok := h.session(rw, req)
+	if !ok {
+		return
+	}
+
+	response := struct {
+		Links []Link `json:"links"`
+	}{
+		Links: h.registry.List(session.Key()),
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(&response); err != nil {
+		log.Error().Err(err).Msg("failed to encode share links response")
+		http.Error(rw, "failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) Revoke(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	session, ok := h.session(rw, req)
+	if !ok {
+		return
+	}
+
+	link, err := h.registry.Revoke(session.Key(), chi.URLParam(req, "shareId"))
+	if errors.Is(err, ErrNotFound) {
+		http.Error(rw, "share link not found", http.StatusNotFound)
+		return
+	}
+	log.Info().Str("browserId", link.BrowserId).Str("shareId", link.ID).Msg("share link revoked")
+	h.audit(req, audit.ShareRevoke, link)
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// Authorize admits requests whose token query parameter is a valid link to
+// the browserId of the route, in place of the usual authentication. The
+// viewer is counted until the wrapped handler returns.
+func (h *Handler) Authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		log := logctx.FromContext(req.Context())
+
+		browserId := chi.URLParam(req, "browserId")
+		viewer, err := h.registry.Join(browserId, req.URL.Query().Get("token"))
+		switch {
+		case errors.Is(err, ErrFull):
+			log.Warn().Str("browserId", browserId).Msg("share link viewer limit reached")
+			http.Error(rw, err.Error(), http.StatusTooManyRequests)
+			return
+		case err != nil:
+			log.Warn().Str("browserId", browserId).Str("sourceIP", ratelimit.RequestIP(req)).Msg("invalid share link rejected")
+			http.Error(rw, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		defer viewer.Leave()
+
+		next.ServeHTTP(rw, req.WithContext(WithViewer(req.Context(), viewer)))
+	})
+}
+
+// session resolves the session of the request and checks that the caller
+// owns it or is an admin, answering the request itself when not.
+func (h *Handler) session(rw http.ResponseWriter, req *http.Request) (*types.Session, bool) {
+	browserId := chi.URLParam(req, "browserId")
+	session, ok := h.lookup(req, browserId)
+	if !ok {
+		http.Error(rw, "invalid session", http.StatusNotFound)
+		return nil, false
+	}
+	if !h.isAdmin(req) && (session.Owner == "" || session.Owner != ownerName(req)) {
+		logctx.FromContext(req.Context()).Warn().Str("browserId", browserId).Str("owner", ownerName(req)).Msg("share links of another user's session rejected")
+		http.Error(rw, "only the session owner may manage its share links", http.StatusForbidden)
+		return nil, false
+	}
+	return session, true
+}
+
+func (h *Handler) audit(req *http.Request, typ audit.EventType, link Link) {
+	h.auditor.Audit(req.Context(), audit.Event{
+		Type:      typ,
+		Owner:     ownerName(req),
+		BrowserId: link.BrowserId,
+		SourceIP:  ratelimit.RequestIP(req),
+		Outcome:   metrics.OutcomeSuccess,
+		Details:   map[string]any{"shareId": link.ID, "viewOnly": link.ViewOnly, "expiresAt": link.ExpiresAt},
+	})
+}
+
+func ownerName(req *http.Request) string {
+	owner, _ := auth.OwnerFrom(req.Context())
+	return owner.Name
+}
+
+// shareURL is the UI page that opens the link, on the host the request was
+// sent to.
+func shareURL(req *http.Request, link Link, token string) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	if proto := req.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	query := url.Values{"share": {token}}
+	if link.ViewOnly {
+		query.Set("viewOnly", "1")
+	}
+	return fmt.Sprintf("%s://%s/session/%s?%s", scheme, req.Host, url.PathEscape(link.BrowserId), query.Encode())
+}
@@ -0,0 +1,212 @@
+// Package share issues signed links that let anyone holding them watch one
+// browser session over VNC without logging in.
+package share
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/types"
+)
+
+var (
+	ErrNotFound = errors.New("share link not found")
+	ErrInvalid  = errors.New("invalid, expired or revoked share link")
+	ErrFull     = errors.New("share link has reached its viewer limit")
+)
+
+// Link lets the holder of its token open the VNC session of BrowserId in
+// Cluster and Namespace until ExpiresAt or until it is revoked.
+type Link struct {
+	ID        string `json:"id"`
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	BrowserId string `json:"browserId"`
+	Owner     string `json:"owner,omitempty"`
+	ViewOnly  bool   `json:"viewOnly"`
+	// MaxViewers limits concurrent viewers; 0 means no limit.
+	MaxViewers int       `json:"maxViewers,omitempty"`
+	Viewers    int       `json:"viewers"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// Key is the session store key of the session the link opens.
+func (l Link) Key() string {
+	return types.StoreKey(l.Cluster, l.Namespace, l.BrowserId)
+}
+
+type entry struct {
+	link  Link
+	done  chan struct{}
+	timer *time.Timer
+}
+
+// Registry keeps the links in memory. Tokens are signed with a key generated
+// on start, so links stop working on restart.
+type Registry struct {
+	key []byte
+	now func() time.Time
+
+	mu    sync.Mutex
+	links map[string]*entry
+}
+
+func NewRegistry() *Registry {
+	key := make([]byte, 32)
+	rand.Read(key) //nolint:errcheck // crypto/rand.Read never fails
+	return &Registry{key: key, now: time.Now, links: map[string]*entry{}}
+}
+
+// Create adds a link to session and returns it with its token.
+func (r *Registry) Create(owner string, session *types.Session, ttl time.Duration, viewOnly bool, maxViewers int) (string, Link) {
+	id := make([]byte, 8)
+	rand.Read(id) //nolint:errcheck
+
+	now := r.now().UTC()
+	e := &entry{
+		link: Link{
+			ID:         hex.EncodeToString(id),
+			Cluster:    session.Cluster,
+			Namespace:  session.Namespace,
+			BrowserId:  session.BrowserId,
+			Owner:      owner,
+			ViewOnly:   viewOnly,
+			MaxViewers: maxViewers,
+			CreatedAt:  now,
+			ExpiresAt:  now.Add(ttl),
+		},
+		done: make(chan struct{}),
+	}
+
+	r.mu.Lock()
+	r.links[e.link.ID] = e
+	// Expired links are removed, which also ends the sessions of their viewers.
+	e.timer = time.AfterFunc(ttl, func() { r.remove(e.link.ID) })
+	r.mu.Unlock()
+
+	return e.link.ID + "." + r.sign(e.link.ID, e.link.Key()), e.link
+}
+
+// List returns the links to the session stored under key, newest first.
+func (r *Registry) List(key string) []Link {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := []Link{}
+	for _, e := range r.links {
+		if e.link.Key() == key {
+			result = append(result, e.link)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Revoke removes the link id to the session stored under key and disconnects
+// its viewers.
+func (r *Registry) Revoke(key, id string) (Link, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.links[id]
+	if !ok || e.link.Key() != key {
+		return Link{}, ErrNotFound
+	}
+	e.timer.Stop()
+	r.removeLocked(id)
+	return e.link, nil
+}
+
+func (r *Registry) remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removeLocked(id)
+}
+
+func (r *Registry) removeLocked(id string) {
+	if e, ok := r.links[id]; ok {
+		delete(r.links, id)
+		close(e.done)
+	}
+}
+
+// Viewer is one connection opened through a link. Done is closed when the
+// link expires or is revoked; Leave must be called when the viewer is gone.
+type Viewer struct {
+	Link Link
+	done chan struct{}
+	once sync.Once
+	r    *Registry
+}
+
+func (v *Viewer) Done() <-chan struct{} {
+	return v.done
+}
+
+func (v *Viewer) Leave() {
+	v.once.Do(func() {
+		v.r.mu.Lock()
+		defer v.r.mu.Unlock()
+		if e, ok := v.r.links[v.Link.ID]; ok {
+			e.link.Viewers--
+		}
+	})
+}
+
+// Join checks token against browserId and counts a new viewer. It returns
+// ErrInvalid for tokens that are malformed, signed for another session,
+// expired or revoked, and ErrFull when the viewer limit is reached. The
+// viewer's Link names the session to open, whatever the request asks for.
+func (r *Registry) Join(browserId, token string) (*Viewer, error) {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalid
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.links[id]
+	if !ok || e.link.BrowserId != browserId ||
+		!hmac.Equal([]byte(sig), []byte(r.sign(id, e.link.Key()))) ||
+		!r.now().Before(e.link.ExpiresAt) {
+		return nil, ErrInvalid
+	}
+	if e.link.MaxViewers > 0 && e.link.Viewers >= e.link.MaxViewers {
+		return nil, ErrFull
+	}
+	e.link.Viewers++
+	return &Viewer{Link: e.link, done: e.done, r: r}, nil
+}
+
+// sign binds a link id to the store key of the session it was created for,
+// so it cannot open a Browser of the same name in another namespace or
+// cluster.
+func (r *Registry) sign(id, key string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(id + "|" + key))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type viewerKey struct{}
+
+// WithViewer marks the request context as opened through a share link.
+func WithViewer(ctx context.Context, viewer *Viewer) context.Context {
+	return context.WithValue(ctx, viewerKey{}, viewer)
+}
+
+func FromContext(ctx context.Context) (*Viewer, bool) {
+	viewer, ok := ctx.Value(viewerKey{}).(*Viewer)
+	return viewer, ok
+}
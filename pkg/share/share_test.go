@@ -0,0 +1,266 @@
+package share
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/types"
+	"github.com/alcounit/selenosis/v2/pkg/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestJoinChecksTokenAndBrowser(t *testing.T) {
+	r := NewRegistry()
+	token, link := r.Create("alice", &types.Session{BrowserId: "b1"}, time.Minute, true, 0)
+
+	viewer, err := r.Join("b1", token)
+	if err != nil {
+		t.Fatalf("expected token to be accepted, got %v", err)
+	}
+	if viewer.Link.ID != link.ID || !viewer.Link.ViewOnly {
+		t.Fatalf("unexpected link: %+v", viewer.Link)
+	}
+
+	id, _, _ := strings.Cut(token, ".")
+	for _, tt := range []struct{ browserId, token string }{
+		{"b2", token},
+		{"b1", ""},
+		{"b1", id},
+		{"b1", id + ".forged"},
+		{"b1", "0000000000000000." + strings.TrimPrefix(token, id+".")},
+	} {
+		if _, err := r.Join(tt.browserId, tt.token); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("expected ErrInvalid for %q on %s, got %v", tt.token, tt.browserId, err)
+		}
+	}
+}
+
+func TestJoinLimitsViewers(t *testing.T) {
+	r := NewRegistry()
+	token, _ := r.Create("alice", &types.Session{BrowserId: "b1"}, time.Minute, true, 1)
+
+	first, err := r.Join("b1", token)
+	if err != nil {
+		t.Fatalf("expected first viewer to join, got %v", err)
+	}
+	if _, err := r.Join("b1", token); !errors.Is(err, ErrFull) {
+		t.Fatalf("expected ErrFull, got %v", err)
+	}
+	first.Leave()
+	first.Leave()
+	if _, err := r.Join("b1", token); err != nil {
+		t.Fatalf("expected a viewer to join after the first left, got %v", err)
+	}
+	if _, err := r.Join("b1", token); !errors.Is(err, ErrFull) {
+		t.Fatalf("expected Leave to be counted once, got %v", err)
+	}
+}
+
+func TestRevokeAndExpiryEndViewers(t *testing.T) {
+	r := NewRegistry()
+	token, link := r.Create("alice", &types.Session{BrowserId: "b1"}, time.Minute, true, 0)
+	viewer, _ := r.Join("b1", token)
+
+	if _, err := r.Revoke("b2", link.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected links of other browsers not to be revoked, got %v", err)
+	}
+	if _, err := r.Revoke("b1", link.ID); err != nil {
+		t.Fatalf("expected revoke to succeed, got %v", err)
+	}
+	select {
+	case <-viewer.Done():
+	default:
+		t.Fatalf("expected revoke to end the viewer")
+	}
+	if _, err := r.Join("b1", token); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected revoked link to be rejected, got %v", err)
+	}
+
+	token, _ = r.Create("alice", &types.Session{BrowserId: "b1"}, 20*time.Millisecond, true, 0)
+	viewer, _ = r.Join("b1", token)
+	select {
+	case <-viewer.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("expected expiry to end the viewer")
+	}
+	if _, err := r.Join("b1", token); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected expired link to be rejected, got %v", err)
+	}
+	if links := r.List("b1"); len(links) != 0 {
+		t.Fatalf("expected no links left, got %+v", links)
+	}
+}
+
+func TestHandlerCreateAndAuthorize(t *testing.T) {
+	r := NewRegistry()
+	h := NewHandler(r, WithTTL(time.Hour, 2*time.Hour))
+
+	router := chi.NewRouter()
+	router.Post("/api/v1/browsers/{browserId}/share", h.Create)
+	router.Get("/api/v1/browsers/{browserId}/share", h.List)
+	router.Delete("/api/v1/browsers/{browserId}/share/{shareId}", h.Revoke)
+	router.With(h.Authorize).Get("/api/v1/share/{browserId}/vnc", func(rw http.ResponseWriter, req *http.Request) {
+		viewer, ok := FromContext(req.Context())
+		if !ok {
+			t.Fatalf("expected viewer in context")
+		}
+		rw.Write([]byte(viewer.Link.ID))
+	})
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "http://ui.example/api/v1/browsers/b1/share", strings.NewReader(`{"maxViewers":2}`)))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rw.Code, rw.Body.String())
+	}
+	var created struct {
+		ID         string    `json:"id"`
+		Token      string    `json:"token"`
+		URL        string    `json:"url"`
+		ViewOnly   bool      `json:"viewOnly"`
+		MaxViewers int       `json:"maxViewers"`
+		CreatedAt  time.Time `json:"createdAt"`
+		ExpiresAt  time.Time `json:"expiresAt"`
+	}
+	if err := json.NewDecoder(rw.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !created.ViewOnly || created.MaxViewers != 2 || created.ExpiresAt.Sub(created.CreatedAt) != time.Hour {
+		t.Fatalf("expected a view-only link with the default expiry, got %+v", created)
+	}
+	want := "http://ui.example/session/b1?" + url.Values{"share": {created.Token}, "viewOnly": {"1"}}.Encode()
+	if created.URL != want {
+		t.Fatalf("expected url %q, got %q", want, created.URL)
+	}
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/api/v1/share/b1/vnc?token="+url.QueryEscape(created.Token), nil))
+	if rw.Code != http.StatusOK || rw.Body.String() != created.ID {
+		t.Fatalf("expected the link to be accepted, got %d: %s", rw.Code, rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/api/v1/share/b2/vnc?token="+url.QueryEscape(created.Token), nil))
+	if rw.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d for another browser, got %d", http.StatusUnauthorized, rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/api/v1/browsers/b1/share", nil))
+	if !strings.Contains(rw.Body.String(), created.ID) || strings.Contains(rw.Body.String(), created.Token) {
+		t.Fatalf("expected list to include the link but not its token, got %s", rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodDelete, "/api/v1/browsers/b1/share/"+created.ID, nil))
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/api/v1/share/b1/vnc?token="+url.QueryEscape(created.Token), nil))
+	if rw.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d after revoke, got %d", http.StatusUnauthorized, rw.Code)
+	}
+}
+
+func TestHandlerCreateValidation(t *testing.T) {
+	h := NewHandler(NewRegistry(), WithTTL(time.Hour, 2*time.Hour), WithSessionLookup(func(_ *http.Request, browserId string) (*types.Session, bool) {
+		return &types.Session{BrowserId: browserId}, browserId == "b1"
+	}))
+
+	router := chi.NewRouter()
+	router.Post("/api/v1/browsers/{browserId}/share", h.Create)
+
+	for _, tt := range []struct {
+		browserId, body string
+		want            int
+	}{
+		{"b1", `{"expiresIn":"soon"}`, http.StatusBadRequest},
+		{"b1", `{"expiresIn":"3h"}`, http.StatusBadRequest},
+		{"b1", `{"maxViewers":-1}`, http.StatusBadRequest},
+		{"b1", `not json`, http.StatusBadRequest},
+		{"b2", `{}`, http.StatusNotFound},
+		{"b1", `{"viewOnly":false,"expiresIn":"90m"}`, http.StatusCreated},
+	} {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/api/v1/browsers/"+tt.browserId+"/share", strings.NewReader(tt.body)))
+		if rw.Code != tt.want {
+			t.Fatalf("expected status %d for %s on %s, got %d", tt.want, tt.body, tt.browserId, rw.Code)
+		}
+	}
+}
+
+func TestJoinIsBoundToNamespaceAndCluster(t *testing.T) {
+	r := NewRegistry()
+	token, link := r.Create("alice", &types.Session{Cluster: "eu", Namespace: "qa", BrowserId: "b1"}, time.Minute, true, 0)
+	if link.Key() != "eu/qa/b1" {
+		t.Fatalf("expected link key eu/qa/b1, got %s", link.Key())
+	}
+
+	viewer, err := r.Join("b1", token)
+	if err != nil {
+		t.Fatalf("expected token to be accepted, got %v", err)
+	}
+	if viewer.Link.Key() != "eu/qa/b1" {
+		t.Fatalf("expected viewer to open eu/qa/b1, got %s", viewer.Link.Key())
+	}
+
+	// A signature made for the same id and browserId in another namespace
+	// must not be accepted.
+	id, _, _ := strings.Cut(token, ".")
+	if _, err := r.Join("b1", id+"."+r.sign(id, "eu/default/b1")); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for a signature of another namespace, got %v", err)
+	}
+	if links := r.List("eu/default/b1"); len(links) != 0 {
+		t.Fatalf("expected no links for another namespace, got %+v", links)
+	}
+	if _, err := r.Revoke("b1", link.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected revoke by bare browserId to fail, got %v", err)
+	}
+}
+
+func TestHandlerRequiresSessionOwnerOrAdmin(t *testing.T) {
+	r := NewRegistry()
+	h := NewHandler(r,
+		WithSessionLookup(func(_ *http.Request, browserId string) (*types.Session, bool) {
+			return &types.Session{BrowserId: browserId, Owner: "alice"}, true
+		}),
+		WithAdminCheck(func(req *http.Request) bool { return ownerName(req) == "root" }))
+
+	router := chi.NewRouter()
+	router.Post("/api/v1/browsers/{browserId}/share", h.Create)
+	router.Get("/api/v1/browsers/{browserId}/share", h.List)
+	router.Delete("/api/v1/browsers/{browserId}/share/{shareId}", h.Revoke)
+
+	as := func(user, method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req = req.WithContext(auth.WithOwner(req.Context(), auth.Owner{Name: user}))
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+
+	if rw := as("bob", http.MethodPost, "/api/v1/browsers/b1/share"); rw.Code != http.StatusForbidden {
+		t.Fatalf("expected bob to be refused a link to alice's session, got %d", rw.Code)
+	}
+	_, link := r.Create("alice", &types.Session{BrowserId: "b1"}, time.Minute, true, 0)
+	if rw := as("bob", http.MethodGet, "/api/v1/browsers/b1/share"); rw.Code != http.StatusForbidden || strings.Contains(rw.Body.String(), link.ID) {
+		t.Fatalf("expected bob not to list alice's links, got %d: %s", rw.Code, rw.Body.String())
+	}
+	if rw := as("bob", http.MethodDelete, "/api/v1/browsers/b1/share/"+link.ID); rw.Code != http.StatusForbidden {
+		t.Fatalf("expected bob not to revoke alice's link, got %d", rw.Code)
+	}
+
+	if rw := as("alice", http.MethodPost, "/api/v1/browsers/b1/share"); rw.Code != http.StatusCreated {
+		t.Fatalf("expected the owner to create a link, got %d", rw.Code)
+	}
+	if rw := as("root", http.MethodDelete, "/api/v1/browsers/b1/share/"+link.ID); rw.Code != http.StatusNoContent {
+		t.Fatalf("expected an admin to revoke the link, got %d", rw.Code)
+	}
+}
@@ -0,0 +1,111 @@
+package tokens
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var tokensBucket = []byte("tokens")
+
+// BoltStore persists tokens in a BoltDB file, keyed by token id.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// record is the stored form of a Token, which leaves the hash and the
+// sealed credential out of JSON.
+type record struct {
+	*Token
+	Hash       string `json:"hash"`
+	Credential []byte `json:"credential,omitempty"`
+}
+
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(tokensBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *BoltStore) Put(_ context.Context, token *Token) error {
+	raw, err := json.Marshal(&record{Token: token, Hash: token.Hash, Credential: token.Credential})
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokensBucket).Put([]byte(token.ID), raw)
+	})
+}
+
+func (s *BoltStore) Get(_ context.Context, id string) (*Token, error) {
+	var token *Token
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(tokensBucket).Get([]byte(id))
+		if raw == nil {
+			return ErrNotFound
+		}
+		var err error
+		token, err = decode(raw)
+		return err
+	})
+	return token, err
+}
+
+func (s *BoltStore) List(_ context.Context, owner string) ([]*Token, error) {
+	result := []*Token{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokensBucket).ForEach(func(_, raw []byte) error {
+			token, err := decode(raw)
+			if err != nil {
+				return err
+			}
+			if token.Owner == owner {
+				result = append(result, token)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortNewestFirst(result)
+	return result, nil
+}
+
+func (s *BoltStore) Delete(_ context.Context, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tokensBucket)
+		if b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+func decode(raw []byte) (*Token, error) {
+	r := record{Token: &Token{}}
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, err
+	}
+	r.Token.Hash = r.Hash
+	r.Token.Credential = r.Credential
+	return r.Token, nil
+}
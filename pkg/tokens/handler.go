@@ -0,0 +1,194 @@
+package tokens
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/alcounit/browser-ui/pkg/audit"
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	"github.com/alcounit/browser-ui/pkg/ratelimit"
+	"github.com/alcounit/selenosis/v2/pkg/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+const maxNameLength = 64
+
+// Handler serves /api/v1/tokens for the logged-in user. Tokens cannot be
+// used to manage tokens, so a leaked token cannot mint or revoke others.
+type Handler struct {
+	manager    *Manager
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+	auditor    audit.Auditor
+}
+
+type HandlerOption func(*Handler)
+
+// WithTTL sets the lifetime of tokens created without expiresIn and the
+// longest lifetime a client may ask for.
+func WithTTL(defaultTTL, maxTTL time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.defaultTTL = defaultTTL
+		h.maxTTL = maxTTL
+	}
+}
+
+func WithAuditor(auditor audit.Auditor) HandlerOption {
+	return func(h *Handler) { h.auditor = auditor }
+}
+
+func NewHandler(manager *Manager, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		manager:    manager,
+		defaultTTL: 30 * 24 * time.Hour,
+		maxTTL:     365 * 24 * time.Hour,
+		auditor:    audit.Discard,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// owner returns the logged-in user, rejecting anonymous and token requests.
+func (h *Handler) owner(rw http.ResponseWriter, req *http.Request) (string, bool) {
+	if _, ok := FromContext(req.Context()); ok {
+		http.Error(rw, "tokens cannot be managed with a token", http.StatusForbidden)
+		return "", false
+	}
+	owner, ok := auth.OwnerFrom(req.Context())
+	if !ok || owner.Name == "" {
+		http.Error(rw, "authentication required", http.StatusUnauthorized)
+		return "", false
+	}
+	return owner.Name, true
+}
+
+func (h *Handler) Create(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	owner, ok := h.owner(rw, req)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Name      string   `json:"name"`
+		Scopes    []string `json:"scopes"`
+		ExpiresIn string   `json:"expiresIn"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(rw, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.Name == "" || len(body.Name) > maxNameLength {
+		http.Error(rw, "name is required and must be at most 64 characters", http.StatusBadRequest)
+		return
+	}
+	scopes, err := ParseScopes(body.Scopes)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ttl := h.defaultTTL
+	if body.ExpiresIn != "" {
+		ttl, err = time.ParseDuration(body.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			http.Error(rw, "expiresIn must be a positive duration such as \"720h\"", http.StatusBadRequest)
+			return
+		}
+	}
+	if ttl > h.maxTTL {
+		http.Error(rw, "expiresIn exceeds the maximum of "+h.maxTTL.String(), http.StatusBadRequest)
+		return
+	}
+
+	value, token, err := h.manager.Issue(req.Context(), owner, body.Name, scopes, ttl)
+	if err != nil {
+		log.Error().Err(err).Str("owner", owner).Msg("failed to create token")
+		http.Error(rw, "failed to create token", http.StatusInternalServerError)
+		return
+	}
+	log.Info().Str("owner", owner).Str("tokenId", token.ID).Msg("token created")
+	h.audit(req, audit.TokenCreate, owner, token)
+
+	// The value is only ever returned here.
+	response := struct {
+		*Token
+		Value string `json:"token"`
+	}{
+		Token: token,
+		Value: value,
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(rw).Encode(&response); err != nil {
+		log.Error().Err(err).Msg("failed to encode token response")
+	}
+}
+
+func (h *Handler) List(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	owner, ok := h.owner(rw, req)
+	if !ok {
+		return
+	}
+
+	list, err := h.manager.List(req.Context(), owner)
+	if err != nil {
+		log.Error().Err(err).Str("owner", owner).Msg("failed to list tokens")
+		http.Error(rw, "failed to list tokens", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Tokens []*Token `json:"tokens"`
+	}{
+		Tokens: list,
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(&response); err != nil {
+		log.Error().Err(err).Msg("failed to encode tokens response")
+		http.Error(rw, "failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) Revoke(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	owner, ok := h.owner(rw, req)
+	if !ok {
+		return
+	}
+
+	token, err := h.manager.Revoke(req.Context(), owner, chi.URLParam(req, "tokenId"))
+	if errors.Is(err, ErrNotFound) {
+		http.Error(rw, "token not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("owner", owner).Msg("failed to revoke token")
+		http.Error(rw, "failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+	log.Info().Str("owner", owner).Str("tokenId", token.ID).Msg("token revoked")
+	h.audit(req, audit.TokenRevoke, owner, token)
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) audit(req *http.Request, typ audit.EventType, owner string, token *Token) {
+	h.auditor.Audit(req.Context(), audit.Event{
+		Type:     typ,
+		Owner:    owner,
+		SourceIP: ratelimit.RequestIP(req),
+		Outcome:  metrics.OutcomeSuccess,
+		Details:  map[string]any{"tokenId": token.ID, "name": token.Name, "scopes": token.Scopes},
+	})
+}
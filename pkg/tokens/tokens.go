@@ -0,0 +1,340 @@
+package tokens
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+)
+
+type Scope string
+
+const (
+	ScopeRead   Scope = "read"
+	ScopeCreate Scope = "create"
+	ScopeDelete Scope = "delete"
+	ScopeVNC    Scope = "vnc"
+)
+
+var allScopes = []Scope{ScopeRead, ScopeCreate, ScopeDelete, ScopeVNC}
+
+// Prefix starts every token so that leaked tokens are easy to recognise.
+const Prefix = "bui_"
+
+var (
+	ErrNotFound = errors.New("token not found")
+	ErrInvalid  = errors.New("invalid or expired token")
+)
+
+// Token is a personal access token. Only a SHA-256 hash of its secret is
+// stored; the secret is shown once when the token is created.
+type Token struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	Scopes    []Scope   `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Hash      string    `json:"-"`
+	// Credential is the password the owner was logged in with when the
+	// token was issued, sealed with the token's secret, so it can only be
+	// read back while the token is being used.
+	Credential []byte `json:"-"`
+}
+
+func (t *Token) Has(scope Scope) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+func (t *Token) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// ParseScopes checks names against the known scopes and drops duplicates.
+func ParseScopes(names []string) ([]Scope, error) {
+	if len(names) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	var result []Scope
+	for _, name := range names {
+		scope := Scope(name)
+		if !slices.Contains(allScopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q", name)
+		}
+		if !slices.Contains(result, scope) {
+			result = append(result, scope)
+		}
+	}
+	return result, nil
+}
+
+type Store interface {
+	Put(ctx context.Context, token *Token) error
+	Get(ctx context.Context, id string) (*Token, error)
+	// List returns the owner's tokens, newest first.
+	List(ctx context.Context, owner string) ([]*Token, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// Manager issues, checks and revokes tokens kept in a Store.
+type Manager struct {
+	store      Store
+	now        func() time.Time
+	checkOwner func(owner, credential string) bool
+}
+
+type ManagerOption func(*Manager)
+
+// WithOwnerCheck makes every use of a token ask check whether its owner may
+// still sign in, so tokens stop working once the owner is removed.
+// credential is the password from the login the token was issued under, or
+// empty when the owner was not logged in with one.
+func WithOwnerCheck(check func(owner, credential string) bool) ManagerOption {
+	return func(m *Manager) { m.checkOwner = check }
+}
+
+func NewManager(store Store, opts ...ManagerOption) *Manager {
+	m := &Manager{store: store, now: time.Now}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Issue creates a token and returns it together with the secret value the
+// client has to send; the value cannot be recovered later.
+func (m *Manager) Issue(ctx context.Context, owner, name string, scopes []Scope, ttl time.Duration) (string, *Token, error) {
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	rand.Read(id)     //nolint:errcheck // crypto/rand.Read never fails
+	rand.Read(secret) //nolint:errcheck
+
+	now := m.now().UTC()
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+	token := &Token{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Owner:     owner,
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		Hash:      hash(encodedSecret),
+	}
+	if credential, ok := credentialFrom(ctx); ok {
+		sealed, err := seal(token.ID, encodedSecret, credential)
+		if err != nil {
+			return "", nil, err
+		}
+		token.Credential = sealed
+	}
+	if err := m.store.Put(ctx, token); err != nil {
+		return "", nil, err
+	}
+	return Prefix + token.ID + "_" + encodedSecret, token, nil
+}
+
+// Authenticate returns the token for value, or ErrInvalid when it is unknown,
+// does not match, has expired or its owner is no longer accepted.
+func (m *Manager) Authenticate(ctx context.Context, value string) (*Token, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(value, Prefix), "_")
+	if !ok || !strings.HasPrefix(value, Prefix) {
+		return nil, ErrInvalid
+	}
+
+	token, err := m.store.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hash(secret)), []byte(token.Hash)) != 1 || token.Expired(m.now()) {
+		return nil, ErrInvalid
+	}
+	if m.checkOwner != nil {
+		credential, err := open(token.ID, secret, token.Credential)
+		if err != nil || !m.checkOwner(token.Owner, credential) {
+			return nil, ErrInvalid
+		}
+	}
+	return token, nil
+}
+
+func (m *Manager) List(ctx context.Context, owner string) ([]*Token, error) {
+	return m.store.List(ctx, owner)
+}
+
+// Revoke deletes the owner's token id. Tokens of other owners are reported
+// as not found.
+func (m *Manager) Revoke(ctx context.Context, owner, id string) (*Token, error) {
+	token, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if token.Owner != owner {
+		return nil, ErrNotFound
+	}
+	return token, m.store.Delete(ctx, id)
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// credentialAEAD derives the key credentials are sealed with from the token
+// secret; it is kept apart from the stored hash by a fixed prefix.
+func credentialAEAD(secret string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("credential:" + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(id, secret, credential string) ([]byte, error) {
+	aead, err := credentialAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce) //nolint:errcheck
+	return aead.Seal(nonce, nonce, []byte(credential), []byte(id)), nil
+}
+
+// open returns the credential sealed for token id, or "" when there is none.
+func open(id, secret string, sealed []byte) (string, error) {
+	if len(sealed) == 0 {
+		return "", nil
+	}
+	aead, err := credentialAEAD(secret)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrInvalid
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+type credentialKey struct{}
+
+// WithCredential records the password the request was logged in with, so
+// tokens issued for it can check the owner on every use.
+func WithCredential(ctx context.Context, password string) context.Context {
+	return context.WithValue(ctx, credentialKey{}, password)
+}
+
+func credentialFrom(ctx context.Context) (string, bool) {
+	password, ok := ctx.Value(credentialKey{}).(string)
+	return password, ok
+}
+
+type tokenKey struct{}
+
+// WithToken marks the request context as authenticated by token.
+func WithToken(ctx context.Context, token *Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+func FromContext(ctx context.Context) (*Token, bool) {
+	token, ok := ctx.Value(tokenKey{}).(*Token)
+	return token, ok
+}
+
+// Require rejects token-authenticated requests whose token lacks the scope
+// scopeFor returns for them. Requests authenticated otherwise pass.
+func Require(scopeFor func(*http.Request) Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if token, ok := FromContext(req.Context()); ok {
+				if scope := scopeFor(req); !token.Has(scope) {
+					log := logctx.FromContext(req.Context())
+					log.Warn().Str("tokenId", token.ID).Str("scope", string(scope)).Msg("token lacks required scope")
+					http.Error(rw, fmt.Sprintf("token lacks the %q scope", scope), http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// MemoryStore keeps tokens in memory; they are lost on restart.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	tokens map[string]*Token
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tokens: map[string]*Token{}}
+}
+
+func (s *MemoryStore) Put(_ context.Context, token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := *token
+	s.tokens[t.ID] = &t
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) (*Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, ok := s.tokens[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	t := *token
+	return &t, nil
+}
+
+func (s *MemoryStore) List(_ context.Context, owner string) ([]*Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := []*Token{}
+	for _, token := range s.tokens {
+		if token.Owner == owner {
+			t := *token
+			result = append(result, &t)
+		}
+	}
+	sortNewestFirst(result)
+	return result, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tokens[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.tokens, id)
+	return nil
+}
+
+func sortNewestFirst(tokens []*Token) {
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+}
@@ -0,0 +1,310 @@
+package tokens
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alcounit/selenosis/v2/pkg/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestIssueAndAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	m := NewManager(store)
+
+	value, token, err := m.Issue(ctx, "alice", "ci", []Scope{ScopeRead, ScopeCreate}, time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(value, Prefix+token.ID+"_") {
+		t.Fatalf("expected value to start with prefix and id, got %q", value)
+	}
+
+	stored, err := store.Get(ctx, token.ID)
+	if err != nil {
+		t.Fatalf("expected stored token, got %v", err)
+	}
+	if stored.Hash == "" || strings.Contains(value, stored.Hash) {
+		t.Fatalf("expected only a hash of the secret to be stored, got %q", stored.Hash)
+	}
+
+	got, err := m.Authenticate(ctx, value)
+	if err != nil {
+		t.Fatalf("expected token to authenticate, got %v", err)
+	}
+	if got.Owner != "alice" || !got.Has(ScopeCreate) || got.Has(ScopeDelete) {
+		t.Fatalf("unexpected token: %+v", got)
+	}
+}
+
+func TestAuthenticateRejectsBadTokens(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(NewMemoryStore())
+	value, token, err := m.Issue(ctx, "alice", "ci", []Scope{ScopeRead}, time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, bad := range []string{
+		"",
+		"not-a-token",
+		Prefix + token.ID,
+		Prefix + token.ID + "_wrong-secret",
+		Prefix + "0000000000000000_" + strings.TrimPrefix(value, Prefix+token.ID+"_"),
+		strings.TrimPrefix(value, Prefix),
+	} {
+		if _, err := m.Authenticate(ctx, bad); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("expected ErrInvalid for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestAuthenticateRejectsExpiredAndRevokedTokens(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(NewMemoryStore())
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	expiring, _, _ := m.Issue(ctx, "alice", "short", []Scope{ScopeRead}, time.Minute)
+	revoked, token, _ := m.Issue(ctx, "alice", "revoked", []Scope{ScopeRead}, time.Hour)
+
+	if _, err := m.Revoke(ctx, "bob", token.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected other owners not to see the token, got %v", err)
+	}
+	if _, err := m.Revoke(ctx, "alice", token.ID); err != nil {
+		t.Fatalf("expected revoke to succeed, got %v", err)
+	}
+	if _, err := m.Authenticate(ctx, revoked); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected revoked token to be rejected, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := m.Authenticate(ctx, expiring); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected expired token to be rejected, got %v", err)
+	}
+}
+
+func TestAuthenticateChecksOwner(t *testing.T) {
+	users := map[string]string{"alice": "s3cr3t"}
+	store := NewMemoryStore()
+	m := NewManager(store, WithOwnerCheck(func(owner, credential string) bool {
+		password, ok := users[owner]
+		return ok && password == credential
+	}))
+
+	ctx := WithCredential(context.Background(), "s3cr3t")
+	value, token, err := m.Issue(ctx, "alice", "ci", []Scope{ScopeRead}, time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	stored, _ := store.Get(ctx, token.ID)
+	if len(stored.Credential) == 0 || strings.Contains(string(stored.Credential), "s3cr3t") {
+		t.Fatalf("expected the credential to be stored sealed, got %q", stored.Credential)
+	}
+
+	if _, err := m.Authenticate(ctx, value); err != nil {
+		t.Fatalf("expected token to authenticate, got %v", err)
+	}
+
+	delete(users, "alice")
+	if _, err := m.Authenticate(ctx, value); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid once the owner is removed, got %v", err)
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes([]string{"read", "vnc", "read"})
+	if err != nil || len(scopes) != 2 {
+		t.Fatalf("expected read and vnc, got %v, %v", scopes, err)
+	}
+	if _, err := ParseScopes(nil); err == nil {
+		t.Fatalf("expected error for empty scopes")
+	}
+	if _, err := ParseScopes([]string{"admin"}); err == nil {
+		t.Fatalf("expected error for unknown scope")
+	}
+}
+
+func TestBoltStorePersistsTokens(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tokens.db")
+
+	store, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	value, token, err := NewManager(store).Issue(ctx, "alice", "ci", []Scope{ScopeVNC}, time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	store.Close()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read db: %v", err)
+	}
+	if strings.Contains(string(raw), strings.TrimPrefix(value, Prefix+token.ID+"_")) {
+		t.Fatalf("expected the secret not to be stored in plain text")
+	}
+
+	store, err = OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer store.Close()
+	m := NewManager(store)
+
+	got, err := m.Authenticate(ctx, value)
+	if err != nil {
+		t.Fatalf("expected token to survive a reopen, got %v", err)
+	}
+	if got.Name != "ci" || !got.Has(ScopeVNC) {
+		t.Fatalf("unexpected token: %+v", got)
+	}
+
+	list, err := m.List(ctx, "alice")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expected one token, got %v, %v", list, err)
+	}
+	if _, err := m.Revoke(ctx, "alice", token.ID); err != nil {
+		t.Fatalf("expected revoke to succeed, got %v", err)
+	}
+	if err := store.Delete(ctx, token.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	h := Require(func(*http.Request) Scope { return ScopeDelete })(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name  string
+		token *Token
+		want  int
+	}{
+		{name: "cookie session", want: http.StatusNoContent},
+		{name: "token with scope", token: &Token{Scopes: []Scope{ScopeDelete}}, want: http.StatusNoContent},
+		{name: "token without scope", token: &Token{Scopes: []Scope{ScopeRead}}, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/browsers/b1", nil)
+		if tt.token != nil {
+			req = req.WithContext(WithToken(req.Context(), tt.token))
+		}
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+		if rw.Code != tt.want {
+			t.Fatalf("%s: expected status %d, got %d", tt.name, tt.want, rw.Code)
+		}
+	}
+}
+
+func asUser(req *http.Request, name string) *http.Request {
+	return req.WithContext(auth.WithOwner(req.Context(), auth.Owner{Name: name}))
+}
+
+func TestHandlerCreateListRevoke(t *testing.T) {
+	m := NewManager(NewMemoryStore())
+	h := NewHandler(m, WithTTL(time.Hour, 24*time.Hour))
+
+	req := asUser(httptest.NewRequest(http.MethodPost, "/api/v1/tokens", strings.NewReader(`{"name":"ci","scopes":["read","create"]}`)), "alice")
+	rw := httptest.NewRecorder()
+	h.Create(rw, req)
+
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rw.Code, rw.Body.String())
+	}
+	var created struct {
+		ID        string    `json:"id"`
+		Token     string    `json:"token"`
+		CreatedAt time.Time `json:"createdAt"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+	if err := json.NewDecoder(rw.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if created.Token == "" || created.ExpiresAt.Sub(created.CreatedAt) != time.Hour {
+		t.Fatalf("expected token value and default expiry, got %+v", created)
+	}
+
+	rw = httptest.NewRecorder()
+	h.List(rw, asUser(httptest.NewRequest(http.MethodGet, "/api/v1/tokens", nil), "alice"))
+	if strings.Contains(rw.Body.String(), created.Token) || strings.Contains(rw.Body.String(), "hash") {
+		t.Fatalf("expected list to omit the token value and hash, got %s", rw.Body.String())
+	}
+	if !strings.Contains(rw.Body.String(), created.ID) {
+		t.Fatalf("expected list to include the token, got %s", rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	h.List(rw, asUser(httptest.NewRequest(http.MethodGet, "/api/v1/tokens", nil), "bob"))
+	if strings.Contains(rw.Body.String(), created.ID) {
+		t.Fatalf("expected other users not to see the token, got %s", rw.Body.String())
+	}
+
+	router := chi.NewRouter()
+	router.Delete("/api/v1/tokens/{tokenId}", h.Revoke)
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, asUser(httptest.NewRequest(http.MethodDelete, "/api/v1/tokens/"+created.ID, nil), "bob"))
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d revoking another user's token, got %d", http.StatusNotFound, rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, asUser(httptest.NewRequest(http.MethodDelete, "/api/v1/tokens/"+created.ID, nil), "alice"))
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, rw.Code)
+	}
+	if _, err := m.Authenticate(context.Background(), created.Token); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected revoked token to be rejected, got %v", err)
+	}
+}
+
+func TestHandlerCreateValidation(t *testing.T) {
+	h := NewHandler(NewManager(NewMemoryStore()), WithTTL(time.Hour, 24*time.Hour))
+
+	for _, body := range []string{
+		`{"scopes":["read"]}`,
+		`{"name":"ci","scopes":[]}`,
+		`{"name":"ci","scopes":["admin"]}`,
+		`{"name":"ci","scopes":["read"],"expiresIn":"soon"}`,
+		`{"name":"ci","scopes":["read"],"expiresIn":"48h"}`,
+	} {
+		rw := httptest.NewRecorder()
+		h.Create(rw, asUser(httptest.NewRequest(http.MethodPost, "/api/v1/tokens", strings.NewReader(body)), "alice"))
+		if rw.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d for %s, got %d", http.StatusBadRequest, body, rw.Code)
+		}
+	}
+}
+
+func TestHandlerRejectsTokenAuthentication(t *testing.T) {
+	h := NewHandler(NewManager(NewMemoryStore()))
+
+	req := asUser(httptest.NewRequest(http.MethodPost, "/api/v1/tokens", strings.NewReader(`{"name":"ci","scopes":["read"]}`)), "alice")
+	req = req.WithContext(WithToken(req.Context(), &Token{Owner: "alice", Scopes: allScopes}))
+	rw := httptest.NewRecorder()
+	h.Create(rw, req)
+
+	if rw.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	h.List(rw, httptest.NewRequest(http.MethodGet, "/api/v1/tokens", nil))
+	if rw.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d without a user, got %d", http.StatusUnauthorized, rw.Code)
+	}
+}
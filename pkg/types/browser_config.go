@@ -1,3 +1,42 @@
 package types
 
+import corev1 "k8s.io/api/core/v1"
+
 type BrowserVersions map[string][]string
+
+// VNCPasswordsAnnotation is the BrowserConfig annotation holding a JSON object
+// of VNC passwords for its browsers, keyed by "name@version", "name" or "*".
+const VNCPasswordsAnnotation = "browser-ui.alcounit.io/vnc-passwords"
+
+// VNCPasswords are the passwords one BrowserConfig annotates for the browsers
+// of its namespace.
+type VNCPasswords struct {
+	Cluster   string
+	Namespace string
+	Passwords map[string]string
+}
+
+// DefaultVersionsAnnotation is the BrowserConfig annotation holding a JSON
+// object of the version to offer first for each of its browsers.
+const DefaultVersionsAnnotation = "browser-ui.alcounit.io/default-versions"
+
+// BrowserConfigCatalog is everything one BrowserConfig says about the
+// browsers it offers.
+type BrowserConfigCatalog struct {
+	Cluster   string
+	Namespace string
+	Name      string
+	// Browsers maps browser name and version to its image.
+	Browsers map[string]map[string]BrowserImage
+	// Defaults maps browser name to its default version.
+	Defaults map[string]string
+}
+
+// BrowserImage is how one browser version is run. VNC and Video are nil when
+// the config does not say, i.e. sets no ENABLE_VNC or ENABLE_VIDEO env var.
+type BrowserImage struct {
+	Image     string                      `json:"image"`
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	VNC       *bool                       `json:"vnc,omitempty"`
+	Video     *bool                       `json:"video,omitempty"`
+}
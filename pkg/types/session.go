@@ -1,12 +1,18 @@
 package types
 
 import (
+	"encoding/json"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// Session is stored from the first Browser event, so SessionId and BrowserIP
+// stay empty until the pod gets an IP. An empty SessionId is encoded as null.
 type Session struct {
 	SessionId       string          `json:"sessionId"`
+	Cluster         string          `json:"cluster,omitempty"`
+	Namespace       string          `json:"namespace,omitempty"`
 	BrowserId       string          `json:"browserId"`
 	BrowserIP       string          `json:"-"`
 	BrowserName     string          `json:"browserName"`
@@ -15,4 +21,77 @@ type Session struct {
 	StartedManually bool            `json:"startedManually"`
 	StartTime       *metav1.Time    `json:"startTime"`
 	Phase           corev1.PodPhase `json:"phase"`
+	Reason          string          `json:"reason,omitempty"`
+	Message         string          `json:"message,omitempty"`
+
+	NodeName         string            `json:"nodeName,omitempty"`
+	Conditions       []Condition       `json:"conditions,omitempty"`
+	Containers       []ContainerStatus `json:"containers,omitempty"`
+	SelenosisOptions json.RawMessage   `json:"selenosisOptions,omitempty"`
+
+	// VNCPasswordManaged tells the UI that the proxy answers VNC
+	// authentication itself and no password should be asked for.
+	VNCPasswordManaged bool `json:"vncPasswordManaged,omitempty"`
+}
+
+type Condition struct {
+	Type               string       `json:"type"`
+	Status             string       `json:"status"`
+	Reason             string       `json:"reason,omitempty"`
+	Message            string       `json:"message,omitempty"`
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// ContainerStatus is the part of a pod container status that is safe to show
+// in the UI; container and image IDs are left out.
+type ContainerStatus struct {
+	Name         string            `json:"name"`
+	Image        string            `json:"image"`
+	Ready        bool              `json:"ready"`
+	RestartCount int32             `json:"restartCount"`
+	State        string            `json:"state,omitempty"`
+	Reason       string            `json:"reason,omitempty"`
+	Message      string            `json:"message,omitempty"`
+	Requests     map[string]string `json:"requests,omitempty"`
+}
+
+// StoreKey is the session and config store key of an object, so the same name
+// in two namespaces or clusters does not collide. An empty cluster or
+// namespace is left out of the key.
+func StoreKey(cluster, namespace, name string) string {
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + key
+	}
+	if cluster != "" {
+		key = cluster + "/" + key
+	}
+	return key
+}
+
+// Key returns the session store key of the session's Browser.
+func (s *Session) Key() string {
+	return StoreKey(s.Cluster, s.Namespace, s.BrowserId)
+}
+
+// Ready reports whether the session has a pod IP and can be reached.
+func (s *Session) Ready() bool {
+	return s.SessionId != "" && s.BrowserIP != ""
+}
+
+func (s Session) MarshalJSON() ([]byte, error) {
+	type session Session
+
+	var sessionId *string
+	if s.SessionId != "" {
+		sessionId = &s.SessionId
+	}
+
+	return json.Marshal(struct {
+		session
+		SessionId *string `json:"sessionId"`
+	}{
+		session:   session(s),
+		SessionId: sessionId,
+	})
 }
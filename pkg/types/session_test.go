@@ -34,3 +34,53 @@ func TestSessionJSONOmitsBrowserIP(t *testing.T) {
 		t.Fatalf("expected sessionId in JSON, got %s", string(raw))
 	}
 }
+
+func TestSessionJSONPendingHasNullSessionId(t *testing.T) {
+	sess := Session{
+		BrowserId:      "browser-1",
+		BrowserName:    "chrome",
+		BrowserVersion: "123",
+		Phase:          corev1.PodPending,
+		Reason:         "ErrImagePull",
+		Message:        "image not found",
+	}
+
+	raw, err := json.Marshal(sess)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("failed to decode %s: %v", string(raw), err)
+	}
+	if v, ok := got["sessionId"]; !ok || v != nil {
+		t.Fatalf("expected sessionId to be null, got %s", string(raw))
+	}
+	if got["reason"] != "ErrImagePull" || got["message"] != "image not found" {
+		t.Fatalf("expected reason and message in JSON, got %s", string(raw))
+	}
+	if sess.Ready() {
+		t.Fatalf("expected pending session to be not ready")
+	}
+}
+
+func TestSessionJSONRoundTrip(t *testing.T) {
+	sess := Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "10.0.0.1"}
+
+	raw, err := json.Marshal(&sess)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var got Session
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("failed to decode %s: %v", string(raw), err)
+	}
+	if got.SessionId != "sess-1" || got.BrowserId != "browser-1" {
+		t.Fatalf("unexpected round trip result: %+v", got)
+	}
+	if strings.Count(string(raw), "sessionId") != 1 {
+		t.Fatalf("expected a single sessionId key, got %s", string(raw))
+	}
+}
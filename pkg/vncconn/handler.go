@@ -0,0 +1,122 @@
+package vncconn
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/alcounit/browser-ui/pkg/audit"
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	"github.com/alcounit/browser-ui/pkg/ratelimit"
+	"github.com/alcounit/selenosis/v2/pkg/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler serves /api/v1/vnc/connections.
+type Handler struct {
+	registry *Registry
+	isAdmin  func(req *http.Request) bool
+	auditor  audit.Auditor
+}
+
+type HandlerOption func(*Handler)
+
+// WithAdminCheck limits force-closing, and listing connections of other
+// users, to requests isAdmin accepts; session owners keep access to share
+// link viewers of their sessions. Without it every request may.
+func WithAdminCheck(isAdmin func(req *http.Request) bool) HandlerOption {
+	return func(h *Handler) { h.isAdmin = isAdmin }
+}
+
+func WithAuditor(auditor audit.Auditor) HandlerOption {
+	return func(h *Handler) { h.auditor = auditor }
+}
+
+func NewHandler(registry *Registry, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		registry: registry,
+		isAdmin:  func(*http.Request) bool { return true },
+		auditor:  audit.Discard,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// List returns the open connections; users who are not admins only see
+// their own and those of share link viewers of their sessions.
+func (h *Handler) List(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	conns := h.registry.List()
+	if !h.isAdmin(req) {
+		owner := ownerName(req)
+		filtered := make([]Connection, 0, len(conns))
+		for _, conn := range conns {
+			if conn.Owner == owner || sharedWith(conn, owner) {
+				filtered = append(filtered, conn)
+			}
+		}
+		conns = filtered
+	}
+
+	response := struct {
+		Connections []Connection `json:"connections"`
+	}{
+		Connections: conns,
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(&response); err != nil {
+		log.Error().Err(err).Msg("failed to encode vnc connections response")
+		http.Error(rw, "failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// Close force-closes one connection. Admins may close any; session owners
+// the ones share link viewers opened to their sessions.
+func (h *Handler) Close(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	connectionId := chi.URLParam(req, "connectionId")
+	if !h.isAdmin(req) {
+		conn, ok := h.registry.Get(connectionId)
+		if !ok || !sharedWith(conn, ownerName(req)) {
+			log.Warn().Str("connectionId", connectionId).Str("owner", ownerName(req)).Msg("vnc force-close by non-admin rejected")
+			http.Error(rw, "only admins and session owners may close vnc connections", http.StatusForbidden)
+			return
+		}
+	}
+
+	conn, err := h.registry.Close(connectionId)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(rw, "vnc connection not found", http.StatusNotFound)
+		return
+	}
+	log.Info().Str("connectionId", conn.ID).Str("browserId", conn.BrowserId).Str("viewer", conn.Owner).Msg("vnc connection force-closed")
+	h.auditor.Audit(req.Context(), audit.Event{
+		Type:           audit.VNCForceClose,
+		Owner:          ownerName(req),
+		SourceIP:       ratelimit.RequestIP(req),
+		BrowserId:      conn.BrowserId,
+		BrowserName:    conn.BrowserName,
+		BrowserVersion: conn.BrowserVersion,
+		Outcome:        metrics.OutcomeSuccess,
+		Details:        map[string]any{"connectionId": conn.ID, "viewer": conn.Owner, "shareId": conn.ShareID},
+	})
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// sharedWith reports whether conn came through a share link to a session
+// owner owns.
+func sharedWith(conn Connection, owner string) bool {
+	return conn.ShareID != "" && owner != "" && conn.SessionOwner == owner
+}
+
+func ownerName(req *http.Request) string {
+	owner, _ := auth.OwnerFrom(req.Context())
+	return owner.Name
+}
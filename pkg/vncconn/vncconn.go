@@ -0,0 +1,212 @@
+// Package vncconn accounts for proxied VNC connections: who watched which
+// browser, for how long and how much went each way.
+package vncconn
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrNotFound = errors.New("vnc connection not found")
+
+// Close reasons of a Connection.
+const (
+	CloseNormal       = "normal"
+	CloseClientError  = "client_error"
+	CloseBackendError = "backend_error"
+	CloseRevoked      = "revoked"
+	CloseForced       = "closed_by_admin"
+	CloseShutdown     = "shutdown"
+)
+
+// Traffic is what went one way over a connection.
+type Traffic struct {
+	Bytes    int64 `json:"bytes"`
+	Messages int64 `json:"messages"`
+}
+
+// Connection is one viewer of a browser. EndedAt and CloseReason are only set
+// once it has ended.
+type Connection struct {
+	ID             string `json:"id"`
+	BrowserId      string `json:"browserId"`
+	BrowserName    string `json:"browserName,omitempty"`
+	BrowserVersion string `json:"browserVersion,omitempty"`
+	Owner          string `json:"owner,omitempty"`
+	// SessionOwner owns the browser being viewed, which is not Owner for
+	// viewers who came through a share link.
+	SessionOwner   string     `json:"sessionOwner,omitempty"`
+	SourceIP       string     `json:"sourceIP,omitempty"`
+	ShareID        string     `json:"shareId,omitempty"`
+	ViewOnly       bool       `json:"viewOnly"`
+	StartedAt      time.Time  `json:"startedAt"`
+	EndedAt        *time.Time `json:"endedAt,omitempty"`
+	ClientToServer Traffic    `json:"clientToServer"`
+	ServerToClient Traffic    `json:"serverToClient"`
+	CloseReason    string     `json:"closeReason,omitempty"`
+	CloseCode      int        `json:"closeCode,omitempty"`
+}
+
+// Counter counts the traffic of one direction; it is safe for concurrent use.
+type Counter struct {
+	bytes, messages atomic.Int64
+}
+
+// Add counts one message of n bytes.
+func (c *Counter) Add(n int) {
+	c.bytes.Add(int64(n))
+	c.messages.Add(1)
+}
+
+func (c *Counter) Traffic() Traffic {
+	return Traffic{Bytes: c.bytes.Load(), Messages: c.messages.Load()}
+}
+
+// Tracker follows one open connection. The proxy counts traffic into
+// ClientToServer and ServerToClient and calls Finish when the connection ends.
+type Tracker struct {
+	ClientToServer Counter
+	ServerToClient Counter
+
+	conn  Connection
+	close func(reason string)
+	r     *Registry
+}
+
+// Finish removes the connection from the registry and returns its final record.
+func (t *Tracker) Finish(reason string, code int) Connection {
+	t.r.mu.Lock()
+	delete(t.r.conns, t.conn.ID)
+	t.r.mu.Unlock()
+
+	conn := t.snapshot()
+	ended := t.r.now().UTC()
+	conn.EndedAt = &ended
+	conn.CloseReason = reason
+	conn.CloseCode = code
+	return conn
+}
+
+func (t *Tracker) snapshot() Connection {
+	conn := t.conn
+	conn.ClientToServer = t.ClientToServer.Traffic()
+	conn.ServerToClient = t.ServerToClient.Traffic()
+	return conn
+}
+
+// Registry keeps the open connections in memory.
+type Registry struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	conns    map[string]*Tracker
+	draining bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{now: time.Now, conns: map[string]*Tracker{}}
+}
+
+// Open registers conn, assigning its ID and start time. close is called with
+// CloseForced or CloseShutdown when the connection has to end, and must end
+// it without blocking. Connections opened while draining are closed at once.
+func (r *Registry) Open(conn Connection, close func(reason string)) *Tracker {
+	id := make([]byte, 8)
+	rand.Read(id) //nolint:errcheck // crypto/rand.Read never fails
+
+	conn.ID = hex.EncodeToString(id)
+	conn.StartedAt = r.now().UTC()
+	t := &Tracker{conn: conn, close: close, r: r}
+
+	r.mu.Lock()
+	r.conns[conn.ID] = t
+	draining := r.draining
+	r.mu.Unlock()
+
+	if draining {
+		close(CloseShutdown)
+	}
+	return t
+}
+
+// List returns the open connections, oldest first.
+func (r *Registry) List() []Connection {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]Connection, 0, len(r.conns))
+	for _, t := range r.conns {
+		result = append(result, t.snapshot())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartedAt.Before(result[j].StartedAt)
+	})
+	return result
+}
+
+// Get returns the open connection id.
+func (r *Registry) Get(id string) (Connection, bool) {
+	r.mu.Lock()
+	t, ok := r.conns[id]
+	r.mu.Unlock()
+	if !ok {
+		return Connection{}, false
+	}
+	return t.snapshot(), true
+}
+
+// Close asks the open connection id to end. The connection leaves the
+// registry once its proxy has shut it down.
+func (r *Registry) Close(id string) (Connection, error) {
+	r.mu.Lock()
+	t, ok := r.conns[id]
+	r.mu.Unlock()
+	if !ok {
+		return Connection{}, ErrNotFound
+	}
+	t.close(CloseForced)
+	return t.snapshot(), nil
+}
+
+// Drain closes every open connection, and any opened from now on, with
+// CloseShutdown. It waits until they have all finished or ctx is done.
+func (r *Registry) Drain(ctx context.Context) error {
+	r.mu.Lock()
+	r.draining = true
+	open := make([]*Tracker, 0, len(r.conns))
+	for _, t := range r.conns {
+		open = append(open, t)
+	}
+	r.mu.Unlock()
+
+	for _, t := range open {
+		t.close(CloseShutdown)
+	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if r.Len() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d vnc connections still open: %w", r.Len(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Len returns the number of open connections.
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.conns)
+}
@@ -0,0 +1,182 @@
+package vncconn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alcounit/selenosis/v2/pkg/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRegistryTracksConnections(t *testing.T) {
+	r := NewRegistry()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	closed := 0
+	first := r.Open(Connection{BrowserId: "b1", Owner: "alice"}, func(reason string) {
+		if reason == CloseForced {
+			closed++
+		}
+	})
+	now = now.Add(time.Minute)
+	r.Open(Connection{BrowserId: "b2", Owner: "bob"}, func(string) {})
+
+	first.ClientToServer.Add(10)
+	first.ServerToClient.Add(100)
+	first.ServerToClient.Add(50)
+
+	list := r.List()
+	if len(list) != 2 || list[0].BrowserId != "b1" || list[1].BrowserId != "b2" {
+		t.Fatalf("expected both connections oldest first, got %+v", list)
+	}
+	if list[0].ClientToServer != (Traffic{Bytes: 10, Messages: 1}) || list[0].ServerToClient != (Traffic{Bytes: 150, Messages: 2}) {
+		t.Fatalf("expected traffic so far, got %+v", list[0])
+	}
+
+	if _, err := r.Close(list[0].ID); err != nil || closed != 1 {
+		t.Fatalf("expected close to reach the connection, got %v and %d calls", err, closed)
+	}
+	if _, err := r.Close("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	record := first.Finish(CloseForced, 1008)
+	if record.EndedAt == nil || record.EndedAt.Sub(record.StartedAt) != 2*time.Minute || record.CloseReason != CloseForced || record.CloseCode != 1008 {
+		t.Fatalf("unexpected final record: %+v", record)
+	}
+	if list := r.List(); len(list) != 1 || list[0].BrowserId != "b2" {
+		t.Fatalf("expected the finished connection to be gone, got %+v", list)
+	}
+}
+
+func TestHandlerLimitsNonAdmins(t *testing.T) {
+	r := NewRegistry()
+	r.Open(Connection{BrowserId: "b1", Owner: "alice"}, func(string) {})
+	bobs := r.Open(Connection{BrowserId: "b2", Owner: "bob"}, func(string) {})
+
+	h := NewHandler(r, WithAdminCheck(func(req *http.Request) bool {
+		owner, _ := auth.OwnerFrom(req.Context())
+		return owner.Name == "admin"
+	}))
+
+	request := func(method, user, id string) *http.Request {
+		req := httptest.NewRequest(method, "/api/v1/vnc/connections", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("connectionId", id)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		return req.WithContext(auth.WithOwner(ctx, auth.Owner{Name: user}))
+	}
+	list := func(user string) []Connection {
+		rw := httptest.NewRecorder()
+		h.List(rw, request(http.MethodGet, user, ""))
+		var response struct {
+			Connections []Connection `json:"connections"`
+		}
+		if err := json.NewDecoder(rw.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return response.Connections
+	}
+
+	if conns := list("bob"); len(conns) != 1 || conns[0].Owner != "bob" {
+		t.Fatalf("expected bob to see only his connection, got %+v", conns)
+	}
+	if conns := list("admin"); len(conns) != 2 {
+		t.Fatalf("expected admin to see every connection, got %+v", conns)
+	}
+
+	rw := httptest.NewRecorder()
+	h.Close(rw, request(http.MethodDelete, "bob", bobs.conn.ID))
+	if rw.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin, got %d", rw.Code)
+	}
+	rw = httptest.NewRecorder()
+	h.Close(rw, request(http.MethodDelete, "admin", "missing"))
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown connection, got %d", rw.Code)
+	}
+	rw = httptest.NewRecorder()
+	h.Close(rw, request(http.MethodDelete, "admin", bobs.conn.ID))
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rw.Code)
+	}
+}
+
+func TestDrainClosesAndWaits(t *testing.T) {
+	r := NewRegistry()
+	var first *Tracker
+	first = r.Open(Connection{BrowserId: "b1"}, func(reason string) {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			first.Finish(reason, 1012)
+		}()
+	})
+
+	if err := r.Drain(context.Background()); err != nil {
+		t.Fatalf("expected drain to succeed, got %v", err)
+	}
+
+	var reason string
+	late := r.Open(Connection{BrowserId: "b2"}, func(r string) { reason = r })
+	if reason != CloseShutdown {
+		t.Fatalf("expected a connection opened while draining to be closed, got %q", reason)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected drain to give up on a connection that stays open, got %v", err)
+	}
+	late.Finish(CloseShutdown, 1012)
+}
+
+func TestHandlerLetsSessionOwnerManageShareViewers(t *testing.T) {
+	r := NewRegistry()
+	viewer := r.Open(Connection{BrowserId: "b1", SessionOwner: "alice", ShareID: "s1"}, func(string) {})
+
+	h := NewHandler(r, WithAdminCheck(func(*http.Request) bool { return false }))
+
+	request := func(method, user, id string) *http.Request {
+		req := httptest.NewRequest(method, "/api/v1/vnc/connections", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("connectionId", id)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		return req.WithContext(auth.WithOwner(ctx, auth.Owner{Name: user}))
+	}
+	list := func(user string) []Connection {
+		rw := httptest.NewRecorder()
+		h.List(rw, request(http.MethodGet, user, ""))
+		var response struct {
+			Connections []Connection `json:"connections"`
+		}
+		if err := json.NewDecoder(rw.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return response.Connections
+	}
+
+	if conns := list("alice"); len(conns) != 1 || conns[0].ShareID != "s1" {
+		t.Fatalf("expected alice to see the share viewer, got %+v", conns)
+	}
+	if conns := list("bob"); len(conns) != 0 {
+		t.Fatalf("expected bob to see nothing, got %+v", conns)
+	}
+
+	rw := httptest.NewRecorder()
+	h.Close(rw, request(http.MethodDelete, "bob", viewer.conn.ID))
+	if rw.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another user, got %d", rw.Code)
+	}
+	rw = httptest.NewRecorder()
+	h.Close(rw, request(http.MethodDelete, "alice", viewer.conn.ID))
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 for the session owner, got %d", rw.Code)
+	}
+}
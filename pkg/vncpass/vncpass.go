@@ -0,0 +1,156 @@
+// Package vncpass finds the VNC password of a session on the server, so that
+// the proxy can authenticate to the browser without the user knowing it.
+package vncpass
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/alcounit/browser-ui/pkg/types"
+	"github.com/alcounit/seleniferous/v2/pkg/store"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Passwords maps "name@version", "name" or "*" to a password, the same keys
+// the UI uses for passwords it remembers.
+type Passwords map[string]string
+
+// Keys returns the keys matching a browser, most specific first.
+func Keys(name, version string) []string {
+	return []string{name + "@" + version, name, "*"}
+}
+
+// Resolver looks passwords up in a file, in passwords from the configuration
+// and in BrowserConfig annotations. The most specific key wins; for the same
+// key the file beats the configuration, which beats annotations.
+type Resolver struct {
+	path      string
+	static    Passwords
+	annotated store.Store[types.VNCPasswords]
+
+	mu   sync.RWMutex
+	file Passwords
+}
+
+type Option func(*Resolver)
+
+// WithPasswords adds passwords set in the configuration.
+func WithPasswords(passwords map[string]string) Option {
+	return func(r *Resolver) { r.static = passwords }
+}
+
+// WithFile reads passwords from a JSON object in the file at path, e.g. a
+// mounted Secret. Watch keeps it up to date.
+func WithFile(path string) Option {
+	return func(r *Resolver) { r.path = path }
+}
+
+// WithAnnotations looks passwords up in BrowserConfig annotations stored by
+// the collector, for configs in the namespace and cluster of the session.
+func WithAnnotations(annotated store.Store[types.VNCPasswords]) Option {
+	return func(r *Resolver) { r.annotated = annotated }
+}
+
+// New returns a resolver; a password file that cannot be loaded is an error.
+func New(opts ...Option) (*Resolver, error) {
+	r := &Resolver{}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.path != "" {
+		if err := r.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Lookup returns the password for the browser of sess.
+func (r *Resolver) Lookup(sess *types.Session) (string, bool) {
+	r.mu.RLock()
+	sources := []Passwords{r.file, r.static}
+	r.mu.RUnlock()
+
+	if r.annotated != nil {
+		for _, cfg := range r.annotated.List() {
+			if cfg.Cluster == sess.Cluster && cfg.Namespace == sess.Namespace {
+				sources = append(sources, cfg.Passwords)
+			}
+		}
+	}
+
+	for _, key := range Keys(sess.BrowserName, sess.BrowserVersion) {
+		for _, passwords := range sources {
+			if password, ok := passwords[key]; ok {
+				return password, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Reload reads the password file again. On error the current passwords stay
+// in use.
+func (r *Resolver) Reload() error {
+	raw, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	var passwords Passwords
+	if err := json.Unmarshal(raw, &passwords); err != nil {
+		return fmt.Errorf("parse %s: %w", r.path, err)
+	}
+
+	r.mu.Lock()
+	r.file = passwords
+	r.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the password file whenever its directory changes, until ctx
+// is done. It returns at once when no file is configured.
+func (r *Resolver) Watch(ctx context.Context) error {
+	if r.path == "" {
+		return nil
+	}
+	log := logctx.FromContext(ctx)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// The directory is watched so that Kubernetes Secret symlink swaps are
+	// noticed.
+	if err := watcher.Add(filepath.Dir(r.path)); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if err := r.Reload(); err != nil {
+				log.Warn().Err(err).Str("path", r.path).Msg("vnc password reload failed, keeping previous passwords")
+				continue
+			}
+			log.Debug().Str("path", r.path).Msg("vnc passwords reloaded")
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error().Err(err).Str("path", r.path).Msg("vnc password watcher error")
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package vncpass
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alcounit/browser-ui/pkg/types"
+	"github.com/alcounit/seleniferous/v2/pkg/store"
+)
+
+func TestLookupPrefersSpecificKeysThenSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passwords.json")
+	if err := os.WriteFile(path, []byte(`{"chrome":"from-file"}`), 0o600); err != nil {
+		t.Fatalf("failed to write passwords: %v", err)
+	}
+
+	annotated := store.NewDefaultStore[types.VNCPasswords]()
+	annotated.Set("eu/qa/cfg", types.VNCPasswords{Cluster: "eu", Namespace: "qa", Passwords: map[string]string{
+		"chrome@120.0": "from-annotation",
+		"firefox":      "firefox-annotation",
+	}})
+	annotated.Set("eu/dev/cfg", types.VNCPasswords{Cluster: "eu", Namespace: "dev", Passwords: map[string]string{
+		"edge": "other-namespace",
+	}})
+
+	r, err := New(
+		WithFile(path),
+		WithPasswords(map[string]string{"chrome": "from-config", "firefox": "firefox-config", "*": "fallback"}),
+		WithAnnotations(annotated),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, tt := range []struct {
+		name, version, want string
+	}{
+		{"chrome", "120.0", "from-annotation"},
+		{"chrome", "121.0", "from-file"},
+		{"firefox", "130.0", "firefox-config"},
+		{"edge", "1.0", "fallback"},
+	} {
+		got, ok := r.Lookup(&types.Session{Cluster: "eu", Namespace: "qa", BrowserName: tt.name, BrowserVersion: tt.version})
+		if !ok || got != tt.want {
+			t.Fatalf("expected %q for %s@%s, got %q, %v", tt.want, tt.name, tt.version, got, ok)
+		}
+	}
+}
+
+func TestLookupWithoutPasswords(t *testing.T) {
+	r, _ := New()
+	if _, ok := r.Lookup(&types.Session{BrowserName: "chrome", BrowserVersion: "120.0"}); ok {
+		t.Fatalf("expected no password")
+	}
+}
+
+func TestReloadKeepsPasswordsOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passwords.json")
+	os.WriteFile(path, []byte(`{"*":"one"}`), 0o600)
+
+	r, err := New(WithFile(path))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	os.WriteFile(path, []byte(`{not json`), 0o600)
+	if err := r.Reload(); err == nil {
+		t.Fatalf("expected invalid file to fail")
+	}
+	if got, _ := r.Lookup(&types.Session{BrowserName: "chrome"}); got != "one" {
+		t.Fatalf("expected previous password to stay in use, got %q", got)
+	}
+
+	os.WriteFile(path, []byte(`{"*":"two"}`), 0o600)
+	if err := r.Reload(); err != nil {
+		t.Fatalf("expected reload to succeed, got %v", err)
+	}
+	if got, _ := r.Lookup(&types.Session{BrowserName: "chrome"}); got != "two" {
+		t.Fatalf("expected reloaded password, got %q", got)
+	}
+
+	if _, err := New(WithFile(filepath.Join(t.TempDir(), "missing.json"))); err == nil {
+		t.Fatalf("expected missing file to be an error")
+	}
+}
@@ -0,0 +1,395 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/history"
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+const (
+	deliverySuccess    = "success"
+	deliveryRetry      = "retry"
+	deliveryDeadLetter = "dead_letter"
+	deliveryDropped    = "dropped"
+)
+
+type Retry struct {
+	// MaxAttempts is the number of deliveries tried before dead-lettering.
+	MaxAttempts int
+	// Initial is the delay before the first retry; it doubles up to Max.
+	Initial time.Duration
+	Max     time.Duration
+}
+
+var DefaultRetry = Retry{
+	MaxAttempts: 5,
+	Initial:     time.Second,
+	Max:         time.Minute,
+}
+
+func (r Retry) delay(attempt int) time.Duration {
+	d := r.Initial << attempt
+	if d <= 0 || (r.Max > 0 && d > r.Max) {
+		d = r.Max
+	}
+	return d
+}
+
+// DeadLetter is a failed delivery written as one JSON line to the dead-letter log.
+type DeadLetter struct {
+	Time     time.Time `json:"time"`
+	Webhook  string    `json:"webhook"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Event    Event     `json:"event"`
+}
+
+// Dispatcher delivers events to every hook that wants them. Each hook has its
+// own queue and worker, so a slow endpoint only delays its own deliveries.
+type Dispatcher struct {
+	client    *http.Client
+	retry     Retry
+	queueSize int
+	log       zerolog.Logger
+
+	deadLetterMu sync.Mutex
+	deadLetter   io.Writer
+
+	workers []*worker
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+type worker struct {
+	hook  Hook
+	queue chan Event
+}
+
+type Option func(*Dispatcher)
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) { d.client = client }
+}
+
+func WithRetry(retry Retry) Option {
+	return func(d *Dispatcher) { d.retry = retry }
+}
+
+func WithQueueSize(n int) Option {
+	return func(d *Dispatcher) { d.queueSize = n }
+}
+
+// WithDeadLetter writes deliveries that exhausted their retries to w.
+func WithDeadLetter(w io.Writer) Option {
+	return func(d *Dispatcher) { d.deadLetter = w }
+}
+
+func WithLogger(log zerolog.Logger) Option {
+	return func(d *Dispatcher) { d.log = log }
+}
+
+func NewDispatcher(hooks []Hook, opts ...Option) (*Dispatcher, error) {
+	d := &Dispatcher{
+		client:    &http.Client{Timeout: 10 * time.Second},
+		retry:     DefaultRetry,
+		queueSize: 100,
+		log:       zerolog.Nop(),
+		stop:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	names := map[string]struct{}{}
+	for _, hook := range hooks {
+		if err := hook.validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := names[hook.Name]; ok {
+			return nil, fmt.Errorf("duplicate webhook name %q", hook.Name)
+		}
+		names[hook.Name] = struct{}{}
+		d.workers = append(d.workers, &worker{hook: hook, queue: make(chan Event, d.queueSize)})
+	}
+
+	for _, w := range d.workers {
+		d.wg.Add(1)
+		go d.run(w)
+	}
+	return d, nil
+}
+
+// Hooks returns the configured hooks without their secrets, and with URLs cut
+// down to scheme and host.
+func (d *Dispatcher) Hooks() []Hook {
+	result := make([]Hook, 0, len(d.workers))
+	for _, w := range d.workers {
+		hook := w.hook
+		hook.Secret = ""
+		hook.URL = redactURL(hook.URL)
+		result = append(result, hook)
+	}
+	return result
+}
+
+// redactURL keeps only the scheme and host of raw: for chat webhooks the path
+// and query are the credential.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Emit queues ev for every hook that wants it. It never blocks: when a hook's
+// queue is full the event is dropped for that hook.
+func (d *Dispatcher) Emit(ev Event) {
+	if ev.ID == "" {
+		ev.ID = uuid.NewString()
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+
+	select {
+	case <-d.stop:
+		return
+	default:
+	}
+
+	for _, w := range d.workers {
+		if !w.hook.Wants(ev.Type) {
+			continue
+		}
+		select {
+		case w.queue <- ev:
+		default:
+			metrics.WebhookDeliveries.WithLabelValues(w.hook.Name, deliveryDropped).Inc()
+			d.log.Error().Str("webhook", w.hook.Name).Str("type", string(ev.Type)).Msg("webhook queue is full, dropping event")
+		}
+	}
+}
+
+// Test sends a sample event to the named hook once, without retries, and
+// returns the response status code.
+func (d *Dispatcher) Test(ctx context.Context, name string) (int, error) {
+	for _, w := range d.workers {
+		if w.hook.Name != name {
+			continue
+		}
+		ev := Event{
+			ID:             uuid.NewString(),
+			Type:           Test,
+			Time:           time.Now().UTC(),
+			BrowserId:      "00000000-0000-0000-0000-000000000000",
+			BrowserName:    "chrome",
+			BrowserVersion: "latest",
+			Message:        "test event from browser-ui",
+		}
+		return d.send(ctx, w.hook, ev)
+	}
+	return 0, ErrUnknownHook
+}
+
+var ErrUnknownHook = errors.New("unknown webhook")
+
+// Close stops the workers. Events still queued or waiting for a retry are
+// written to the dead-letter log.
+func (d *Dispatcher) Close() error {
+	d.once.Do(func() { close(d.stop) })
+	d.wg.Wait()
+	return nil
+}
+
+func (d *Dispatcher) run(w *worker) {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case ev := <-w.queue:
+			d.deliver(w.hook, ev)
+		case <-d.stop:
+			for {
+				select {
+				case ev := <-w.queue:
+					d.deadLetterEvent(w.hook, ev, 0, errors.New("dispatcher stopped"))
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(hook Hook, ev Event) {
+	var err error
+	attempts := 0
+	for attempts < max(d.retry.MaxAttempts, 1) {
+		if attempts > 0 {
+			metrics.WebhookDeliveries.WithLabelValues(hook.Name, deliveryRetry).Inc()
+			timer := time.NewTimer(d.retry.delay(attempts - 1))
+			select {
+			case <-timer.C:
+			case <-d.stop:
+				timer.Stop()
+				d.deadLetterEvent(hook, ev, attempts, fmt.Errorf("dispatcher stopped: %w", err))
+				return
+			}
+		}
+		attempts++
+
+		var code int
+		code, err = d.send(context.Background(), hook, ev)
+		if err == nil {
+			metrics.WebhookDeliveries.WithLabelValues(hook.Name, deliverySuccess).Inc()
+			return
+		}
+		if !retryable(code) {
+			break
+		}
+	}
+
+	d.deadLetterEvent(hook, ev, attempts, err)
+}
+
+// retryable reports whether a failed delivery is worth repeating: network
+// errors, timeouts, rate limiting and server errors are; other 4xx are not.
+func retryable(code int) bool {
+	return code == 0 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+func (d *Dispatcher) send(ctx context.Context, hook Hook, ev Event) (int, error) {
+	body, err := json.Marshal(&ev)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(ev.Type))
+	req.Header.Set(DeliveryHeader, ev.ID)
+	if hook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body) //nolint:errcheck
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) deadLetterEvent(hook Hook, ev Event, attempts int, err error) {
+	metrics.WebhookDeliveries.WithLabelValues(hook.Name, deliveryDeadLetter).Inc()
+
+	entry := DeadLetter{
+		Time:     time.Now().UTC(),
+		Webhook:  hook.Name,
+		Attempts: attempts,
+		Event:    ev,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	d.log.Error().Str("webhook", hook.Name).Str("type", string(ev.Type)).Str("eventId", ev.ID).Int("attempts", attempts).Str("error", entry.Error).Msg("webhook delivery failed, dead-lettered")
+
+	if d.deadLetter == nil {
+		return
+	}
+
+	line, mErr := json.Marshal(&entry)
+	if mErr != nil {
+		return
+	}
+	d.deadLetterMu.Lock()
+	defer d.deadLetterMu.Unlock()
+	if _, wErr := d.deadLetter.Write(append(line, '\n')); wErr != nil {
+		d.log.Error().Err(wErr).Str("webhook", hook.Name).Msg("failed to write webhook dead letter")
+	}
+}
+
+// RecordSession turns collector lifecycle transitions into session events.
+func (d *Dispatcher) RecordSession(_ context.Context, ev history.SessionEvent) {
+	var t EventType
+	switch ev.Type {
+	case history.SessionCreated:
+		t = SessionCreated
+	case history.SessionRunning:
+		t = SessionStarted
+	case history.SessionFailed:
+		t = SessionFailed
+	case history.SessionDeleted:
+		t = SessionEnded
+	default:
+		return
+	}
+
+	sess := ev.Session
+	d.Emit(Event{
+		Type:           t,
+		Time:           ev.Time.UTC(),
+		Cluster:        sess.Cluster,
+		Namespace:      sess.Namespace,
+		BrowserId:      sess.BrowserId,
+		BrowserName:    sess.BrowserName,
+		BrowserVersion: sess.BrowserVersion,
+		Owner:          sess.Owner,
+		Phase:          string(sess.Phase),
+		Reason:         sess.Reason,
+		Message:        sess.Message,
+	})
+}
+
+// RecordAction turns service actions into browser events; VNC views are not sent.
+func (d *Dispatcher) RecordAction(_ context.Context, action history.Action) {
+	var t EventType
+	switch {
+	case action.Type == history.ActionCreate && action.Outcome == metrics.OutcomeSuccess:
+		t = BrowserCreated
+	case action.Type == history.ActionCreate && action.Outcome != metrics.OutcomeBadRequest:
+		// Rejected requests are the client's problem, not a failed create.
+		t = BrowserCreateFailed
+	case action.Type == history.ActionDelete && action.Outcome == metrics.OutcomeSuccess:
+		t = BrowserDeleted
+	default:
+		return
+	}
+
+	d.Emit(Event{
+		Type:           t,
+		Time:           action.Time.UTC(),
+		Cluster:        action.Cluster,
+		Namespace:      action.Namespace,
+		BrowserId:      action.BrowserId,
+		BrowserName:    action.BrowserName,
+		BrowserVersion: action.BrowserVersion,
+		Owner:          action.User,
+		Outcome:        action.Outcome,
+	})
+}
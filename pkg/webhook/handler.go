@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	dispatcher *Dispatcher
+	isAdmin    func(req *http.Request) bool
+}
+
+type HandlerOption func(*Handler)
+
+// WithAdminCheck limits listing and testing webhooks to requests isAdmin
+// accepts. Without it every request may.
+func WithAdminCheck(isAdmin func(req *http.Request) bool) HandlerOption {
+	return func(h *Handler) { h.isAdmin = isAdmin }
+}
+
+func NewHandler(dispatcher *Dispatcher, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		dispatcher: dispatcher,
+		isAdmin:    func(*http.Request) bool { return true },
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// List returns the configured webhooks; secrets are never included and URLs
+// are reduced to scheme and host.
+func (h *Handler) List(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	if !h.isAdmin(req) {
+		http.Error(rw, "only admins may list webhooks", http.StatusForbidden)
+		return
+	}
+
+	response := struct {
+		Webhooks []Hook `json:"webhooks"`
+	}{
+		Webhooks: h.dispatcher.Hooks(),
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(&response); err != nil {
+		log.Error().Err(err).Msg("failed to encode webhooks response")
+		http.Error(rw, "failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// Test sends a sample event to the webhook named in the URL and reports how
+// the endpoint answered. The delivery is not retried.
+func (h *Handler) Test(rw http.ResponseWriter, req *http.Request) {
+	log := logctx.FromContext(req.Context())
+
+	name := chi.URLParam(req, "name")
+	if !h.isAdmin(req) {
+		log.Warn().Str("webhook", name).Msg("webhook test by non-admin rejected")
+		http.Error(rw, "only admins may test webhooks", http.StatusForbidden)
+		return
+	}
+
+	code, err := h.dispatcher.Test(req.Context(), name)
+	if errors.Is(err, ErrUnknownHook) {
+		http.Error(rw, "webhook not found", http.StatusNotFound)
+		return
+	}
+
+	response := struct {
+		Delivered  bool   `json:"delivered"`
+		StatusCode int    `json:"statusCode,omitempty"`
+		Error      string `json:"error,omitempty"`
+	}{
+		Delivered:  err == nil,
+		StatusCode: code,
+	}
+	if err != nil {
+		response.Error = err.Error()
+		log.Error().Err(err).Str("webhook", name).Msg("webhook test delivery failed")
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(&response); err != nil {
+		log.Error().Err(err).Msg("failed to encode webhook test response")
+		http.Error(rw, "failed to encode response", http.StatusInternalServerError)
+	}
+}
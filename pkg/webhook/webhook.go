@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type EventType string
+
+const (
+	SessionCreated      EventType = "session.created"
+	SessionStarted      EventType = "session.started"
+	SessionFailed       EventType = "session.failed"
+	SessionEnded        EventType = "session.ended"
+	BrowserCreated      EventType = "browser.created"
+	BrowserCreateFailed EventType = "browser.create_failed"
+	BrowserDeleted      EventType = "browser.deleted"
+	Test                EventType = "webhook.test"
+)
+
+const (
+	SignatureHeader = "X-Browser-UI-Signature"
+	EventHeader     = "X-Browser-UI-Event"
+	DeliveryHeader  = "X-Browser-UI-Delivery"
+)
+
+// Event is the JSON payload POSTed to webhooks.
+type Event struct {
+	ID             string    `json:"id"`
+	Type           EventType `json:"type"`
+	Time           time.Time `json:"time"`
+	Cluster        string    `json:"cluster,omitempty"`
+	Namespace      string    `json:"namespace,omitempty"`
+	BrowserId      string    `json:"browserId,omitempty"`
+	BrowserName    string    `json:"browserName,omitempty"`
+	BrowserVersion string    `json:"browserVersion,omitempty"`
+	Owner          string    `json:"owner,omitempty"`
+	Phase          string    `json:"phase,omitempty"`
+	Reason         string    `json:"reason,omitempty"`
+	Message        string    `json:"message,omitempty"`
+	Outcome        string    `json:"outcome,omitempty"`
+}
+
+// Hook is a single webhook endpoint. Events lists the event types it receives;
+// "*" or a "session.*" style prefix match several, an empty list matches all.
+type Hook struct {
+	Name   string   `json:"name"`
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events,omitempty"`
+}
+
+func (h Hook) Wants(t EventType) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, pattern := range h.Events {
+		if pattern == "*" || pattern == string(t) {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(string(t), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h Hook) validate() error {
+	if h.Name == "" {
+		return errors.New("webhook name is required")
+	}
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook %q: invalid url %q", h.Name, h.URL)
+	}
+	return nil
+}
+
+// LoadHooks reads a JSON array of hooks from path.
+func LoadHooks(path string) ([]Hook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var hooks []Hook
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return hooks, nil
+}
+
+// Sign returns the value of the signature header for body: "sha256=" followed
+// by the hex HMAC-SHA256 of the raw body keyed with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature matches body for secret.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
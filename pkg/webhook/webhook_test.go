@@ -0,0 +1,446 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/history"
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	"github.com/alcounit/browser-ui/pkg/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var fastRetry = Retry{MaxAttempts: 3, Initial: time.Millisecond, Max: 5 * time.Millisecond}
+
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a local webhook endpoint answering with the queued status codes,
+// then 200 once they run out.
+type receiver struct {
+	mu         sync.Mutex
+	codes      []int
+	deliveries []delivery
+	received   chan struct{}
+}
+
+func newReceiver(codes ...int) (*receiver, *httptest.Server) {
+	r := &receiver{codes: codes, received: make(chan struct{}, 100)}
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		r.deliveries = append(r.deliveries, delivery{header: req.Header.Clone(), body: body})
+		code := http.StatusOK
+		if len(r.codes) > 0 {
+			code, r.codes = r.codes[0], r.codes[1:]
+		}
+		r.mu.Unlock()
+
+		rw.WriteHeader(code)
+		r.received <- struct{}{}
+	}))
+	return r, srv
+}
+
+func (r *receiver) wait(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.received:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for delivery %d of %d", i+1, n)
+		}
+	}
+}
+
+// syncBuffer is a dead-letter writer safe to read while workers write to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) deadLetters(t *testing.T) []DeadLetter {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var result []DeadLetter
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var dl DeadLetter
+		if err := json.Unmarshal([]byte(line), &dl); err != nil {
+			t.Fatalf("unmarshal dead letter %q: %v", line, err)
+		}
+		result = append(result, dl)
+	}
+	return result
+}
+
+func TestHookWants(t *testing.T) {
+	cases := []struct {
+		events []string
+		t      EventType
+		want   bool
+	}{
+		{nil, SessionStarted, true},
+		{[]string{"*"}, BrowserDeleted, true},
+		{[]string{"session.started"}, SessionStarted, true},
+		{[]string{"session.started"}, SessionEnded, false},
+		{[]string{"session.*"}, SessionFailed, true},
+		{[]string{"session.*"}, BrowserCreated, false},
+		{[]string{"browser.created", "session.failed"}, SessionFailed, true},
+	}
+
+	for _, tc := range cases {
+		if got := (Hook{Events: tc.events}).Wants(tc.t); got != tc.want {
+			t.Fatalf("events %v, type %s: expected %v, got %v", tc.events, tc.t, tc.want, got)
+		}
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"type":"session.started"}`)
+	sig := Sign("s3cr3t", body)
+
+	if !strings.HasPrefix(sig, "sha256=") {
+		t.Fatalf("expected sha256= prefix, got %q", sig)
+	}
+	if !Verify("s3cr3t", body, sig) {
+		t.Fatal("expected signature to verify")
+	}
+	if Verify("other", body, sig) || Verify("s3cr3t", []byte(`{}`), sig) {
+		t.Fatal("expected signature to fail for a different secret or body")
+	}
+}
+
+func TestNewDispatcherValidatesHooks(t *testing.T) {
+	for _, hooks := range [][]Hook{
+		{{Name: "", URL: "http://example.com"}},
+		{{Name: "a", URL: "ftp://example.com"}},
+		{{Name: "a", URL: "not a url"}},
+		{{Name: "a", URL: "http://example.com"}, {Name: "a", URL: "http://example.org"}},
+	} {
+		if _, err := NewDispatcher(hooks); err == nil {
+			t.Fatalf("expected %+v to be rejected", hooks)
+		}
+	}
+}
+
+func TestDispatcherDeliversSignedEvent(t *testing.T) {
+	rcv, srv := newReceiver()
+	defer srv.Close()
+
+	d, err := NewDispatcher([]Hook{{Name: "ci", URL: srv.URL, Secret: "s3cr3t", Events: []string{"session.*"}}}, WithRetry(fastRetry))
+	if err != nil {
+		t.Fatalf("new dispatcher: %v", err)
+	}
+	defer d.Close()
+
+	d.Emit(Event{Type: BrowserCreated, BrowserId: "ignored"})
+	d.Emit(Event{Type: SessionStarted, BrowserId: "b1", Owner: "alice"})
+	rcv.wait(t, 1)
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	if len(rcv.deliveries) != 1 {
+		t.Fatalf("expected only the session event to be delivered, got %d", len(rcv.deliveries))
+	}
+
+	got := rcv.deliveries[0]
+	if !Verify("s3cr3t", got.body, got.header.Get(SignatureHeader)) {
+		t.Fatalf("expected a valid signature, got %q", got.header.Get(SignatureHeader))
+	}
+	if got.header.Get(EventHeader) != string(SessionStarted) || got.header.Get(DeliveryHeader) == "" {
+		t.Fatalf("unexpected headers: %v", got.header)
+	}
+
+	var ev Event
+	if err := json.Unmarshal(got.body, &ev); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if ev.BrowserId != "b1" || ev.Owner != "alice" || ev.ID == "" || ev.Time.IsZero() {
+		t.Fatalf("unexpected payload: %+v", ev)
+	}
+}
+
+func TestDispatcherRetriesThenSucceeds(t *testing.T) {
+	rcv, srv := newReceiver(http.StatusInternalServerError, http.StatusTooManyRequests)
+	defer srv.Close()
+
+	dl := &syncBuffer{}
+	d, _ := NewDispatcher([]Hook{{Name: "retry-ok", URL: srv.URL}}, WithRetry(fastRetry), WithDeadLetter(dl))
+
+	d.Emit(Event{Type: SessionEnded})
+	rcv.wait(t, 3)
+	d.Close()
+
+	if n := len(dl.deadLetters(t)); n != 0 {
+		t.Fatalf("expected no dead letters, got %d", n)
+	}
+	if got := testutilValue("retry-ok", deliverySuccess); got != 1 {
+		t.Fatalf("expected 1 successful delivery, got %v", got)
+	}
+	if got := testutilValue("retry-ok", deliveryRetry); got != 2 {
+		t.Fatalf("expected 2 retries, got %v", got)
+	}
+}
+
+func TestDispatcherDeadLettersAfterMaxAttempts(t *testing.T) {
+	rcv, srv := newReceiver(http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	defer srv.Close()
+
+	dl := &syncBuffer{}
+	d, _ := NewDispatcher([]Hook{{Name: "always-down", URL: srv.URL}}, WithRetry(fastRetry), WithDeadLetter(dl))
+
+	d.Emit(Event{Type: SessionFailed, BrowserId: "b1"})
+	rcv.wait(t, 3)
+	d.Close()
+
+	letters := dl.deadLetters(t)
+	if len(letters) != 1 {
+		t.Fatalf("expected 1 dead letter, got %d", len(letters))
+	}
+	if letters[0].Webhook != "always-down" || letters[0].Attempts != 3 || letters[0].Event.BrowserId != "b1" || !strings.Contains(letters[0].Error, "502") {
+		t.Fatalf("unexpected dead letter: %+v", letters[0])
+	}
+}
+
+func TestDispatcherDoesNotRetryClientErrors(t *testing.T) {
+	rcv, srv := newReceiver(http.StatusBadRequest)
+	defer srv.Close()
+
+	dl := &syncBuffer{}
+	d, _ := NewDispatcher([]Hook{{Name: "rejects", URL: srv.URL}}, WithRetry(fastRetry), WithDeadLetter(dl))
+
+	d.Emit(Event{Type: SessionCreated})
+	rcv.wait(t, 1)
+	d.Close()
+
+	letters := dl.deadLetters(t)
+	if len(letters) != 1 || letters[0].Attempts != 1 {
+		t.Fatalf("expected a single attempt to be dead-lettered, got %+v", letters)
+	}
+}
+
+func TestDispatcherCloseDeadLettersPendingRetry(t *testing.T) {
+	rcv, srv := newReceiver(http.StatusServiceUnavailable)
+	defer srv.Close()
+
+	dl := &syncBuffer{}
+	d, _ := NewDispatcher([]Hook{{Name: "slow-retry", URL: srv.URL}}, WithRetry(Retry{MaxAttempts: 3, Initial: time.Hour, Max: time.Hour}), WithDeadLetter(dl))
+
+	d.Emit(Event{Type: SessionEnded})
+	rcv.wait(t, 1)
+
+	done := make(chan struct{})
+	go func() {
+		d.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Close to interrupt the retry wait")
+	}
+
+	letters := dl.deadLetters(t)
+	if len(letters) != 1 || !strings.Contains(letters[0].Error, "dispatcher stopped") {
+		t.Fatalf("expected the pending event to be dead-lettered, got %+v", letters)
+	}
+}
+
+func TestDispatcherHooksHideSecretsAndURLs(t *testing.T) {
+	d, _ := NewDispatcher([]Hook{{Name: "a", URL: "https://hooks.slack.com/services/T000/B000/XXXX?token=t", Secret: "s3cr3t"}})
+	defer d.Close()
+
+	hooks := d.Hooks()
+	if len(hooks) != 1 || hooks[0].Secret != "" || hooks[0].Name != "a" || hooks[0].URL != "https://hooks.slack.com" {
+		t.Fatalf("unexpected hooks: %+v", hooks)
+	}
+}
+
+func TestDispatcherRecordsLifecycleAndActions(t *testing.T) {
+	rcv, srv := newReceiver()
+	defer srv.Close()
+
+	d, _ := NewDispatcher([]Hook{{Name: "all", URL: srv.URL}}, WithRetry(fastRetry))
+	defer d.Close()
+
+	sess := &types.Session{Cluster: "eu", Namespace: "qa", BrowserId: "b1", BrowserName: "chrome", Owner: "alice", Phase: corev1.PodFailed, Reason: "ImagePullBackOff"}
+	d.RecordSession(context.Background(), history.SessionEvent{Type: history.SessionFailed, Time: time.Now(), Session: sess})
+	d.RecordAction(context.Background(), history.Action{Type: history.ActionCreate, Outcome: metrics.OutcomeBadRequest, User: "alice", BrowserId: "b0"})
+	d.RecordAction(context.Background(), history.Action{Type: history.ActionCreate, Outcome: metrics.OutcomeTimeout, User: "alice", BrowserId: "b1"})
+	d.RecordAction(context.Background(), history.Action{Type: history.ActionVNC, Outcome: metrics.OutcomeSuccess})
+	d.RecordAction(context.Background(), history.Action{Type: history.ActionDelete, Outcome: metrics.OutcomeSuccess, Cluster: "eu", Namespace: "qa", BrowserId: "b1"})
+	rcv.wait(t, 3)
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	var got []EventType
+	for _, dlv := range rcv.deliveries {
+		var ev Event
+		json.Unmarshal(dlv.body, &ev) //nolint:errcheck
+		got = append(got, ev.Type)
+		if ev.Type == SessionFailed && ev.Reason != "ImagePullBackOff" {
+			t.Fatalf("expected failure reason in payload, got %+v", ev)
+		}
+		if ev.Type != BrowserCreateFailed && (ev.Cluster != "eu" || ev.Namespace != "qa") {
+			t.Fatalf("expected cluster and namespace in payload, got %+v", ev)
+		}
+	}
+	want := []EventType{SessionFailed, BrowserCreateFailed, BrowserDeleted}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestLoadHooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	os.WriteFile(path, []byte(`[{"name":"chat","url":"https://chat.example.com/hook","secret":"x","events":["session.started","session.failed"]}]`), 0o600) //nolint:errcheck
+
+	hooks, err := LoadHooks(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(hooks) != 1 || hooks[0].Name != "chat" || len(hooks[0].Events) != 2 {
+		t.Fatalf("unexpected hooks: %+v", hooks)
+	}
+
+	os.WriteFile(path, []byte(`{`), 0o600) //nolint:errcheck
+	if _, err := LoadHooks(path); err == nil {
+		t.Fatal("expected invalid JSON to fail")
+	}
+}
+
+func testRequest(method, name string) *http.Request {
+	req := httptest.NewRequest(method, "/api/v1/webhooks/"+name+"/test", nil)
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("name", name)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+}
+
+func TestHandlerTestSendsSampleEvent(t *testing.T) {
+	rcv, srv := newReceiver()
+	defer srv.Close()
+
+	d, _ := NewDispatcher([]Hook{{Name: "chat", URL: srv.URL, Secret: "s3cr3t"}})
+	defer d.Close()
+
+	rw := httptest.NewRecorder()
+	NewHandler(d).Test(rw, testRequest(http.MethodPost, "chat"))
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rw.Code)
+	}
+	var resp struct {
+		Delivered  bool `json:"delivered"`
+		StatusCode int  `json:"statusCode"`
+	}
+	if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !resp.Delivered || resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	if len(rcv.deliveries) != 1 || rcv.deliveries[0].header.Get(EventHeader) != string(Test) {
+		t.Fatalf("expected one webhook.test delivery, got %+v", rcv.deliveries)
+	}
+	if !Verify("s3cr3t", rcv.deliveries[0].body, rcv.deliveries[0].header.Get(SignatureHeader)) {
+		t.Fatal("expected the test event to be signed")
+	}
+}
+
+func TestHandlerTestReportsFailure(t *testing.T) {
+	_, srv := newReceiver(http.StatusInternalServerError)
+	defer srv.Close()
+
+	d, _ := NewDispatcher([]Hook{{Name: "chat", URL: srv.URL}})
+	defer d.Close()
+
+	rw := httptest.NewRecorder()
+	NewHandler(d).Test(rw, testRequest(http.MethodPost, "chat"))
+
+	var resp struct {
+		Delivered  bool   `json:"delivered"`
+		StatusCode int    `json:"statusCode"`
+		Error      string `json:"error"`
+	}
+	json.NewDecoder(rw.Body).Decode(&resp) //nolint:errcheck
+	if resp.Delivered || resp.StatusCode != http.StatusInternalServerError || resp.Error == "" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHandlerTestUnknownWebhook(t *testing.T) {
+	d, _ := NewDispatcher(nil)
+	defer d.Close()
+
+	rw := httptest.NewRecorder()
+	NewHandler(d).Test(rw, testRequest(http.MethodPost, "missing"))
+
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rw.Code)
+	}
+}
+
+func TestHandlerRequiresAdmin(t *testing.T) {
+	rcv, srv := newReceiver()
+	defer srv.Close()
+
+	d, _ := NewDispatcher([]Hook{{Name: "chat", URL: srv.URL}})
+	defer d.Close()
+	h := NewHandler(d, WithAdminCheck(func(*http.Request) bool { return false }))
+
+	rw := httptest.NewRecorder()
+	h.List(rw, httptest.NewRequest(http.MethodGet, "/api/v1/webhooks", nil))
+	if rw.Code != http.StatusForbidden || strings.Contains(rw.Body.String(), srv.URL) {
+		t.Fatalf("expected 403 without the url, got %d: %s", rw.Code, rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	h.Test(rw, testRequest(http.MethodPost, "chat"))
+	if rw.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rw.Code)
+	}
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	if len(rcv.deliveries) != 0 {
+		t.Fatalf("expected no test delivery, got %d", len(rcv.deliveries))
+	}
+}
+
+func testutilValue(webhook, result string) float64 {
+	return testutil.ToFloat64(metrics.WebhookDeliveries.WithLabelValues(webhook, result))
+}
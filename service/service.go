@@ -7,13 +7,26 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	logctx "github.com/alcounit/browser-controller/pkg/log"
+	"github.com/alcounit/browser-ui/pkg/audit"
+	"github.com/alcounit/browser-ui/pkg/catalog"
+	"github.com/alcounit/browser-ui/pkg/cluster"
+	"github.com/alcounit/browser-ui/pkg/csrf"
+	"github.com/alcounit/browser-ui/pkg/history"
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	"github.com/alcounit/browser-ui/pkg/ratelimit"
+	"github.com/alcounit/browser-ui/pkg/rfb"
+	"github.com/alcounit/browser-ui/pkg/share"
 	"github.com/alcounit/browser-ui/pkg/types"
+	"github.com/alcounit/browser-ui/pkg/vncconn"
 	"github.com/alcounit/seleniferous/v2/pkg/store"
 	"github.com/alcounit/selenosis/v2/pkg/auth"
 	"github.com/alcounit/selenosis/v2/pkg/selenium"
@@ -23,57 +36,162 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	browserv1 "github.com/alcounit/browser-controller/apis/browser/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	browserclient "github.com/alcounit/browser-service/pkg/client/browser"
 )
 
 type Service struct {
-	namespace           string
-	client              browserclient.Client
-	sessionStore        store.Store[*types.Session]
-	configStore         store.Store[types.BrowserVersions]
-	browserStartTimeout time.Duration
+	namespace    string
+	namespaces   []string
+	client       browserclient.Client
+	clusters     *cluster.Registry
+	sessionStore store.Store[*types.Session]
+	configStore  store.Store[types.BrowserVersions]
+	catalogStore store.Store[types.BrowserConfigCatalog]
+	// browserStartTimeout is shared by copies of the Service so config
+	// reloads reach every handler.
+	browserStartTimeout *atomic.Int64
+	recorder            history.Recorder
+	auditor             audit.Auditor
+	checkOrigin         func(*http.Request) bool
+	vncPasswords        func(*types.Session) (string, bool)
+	vncProxy            VNCProxyConfig
+	vncConns            *vncconn.Registry
+	isAdmin             func(*http.Request) bool
 }
 
 type wsConn interface {
 	ReadMessage() (int, []byte, error)
 	WriteMessage(int, []byte) error
+	WriteControl(int, []byte, time.Time) error
+	SetReadDeadline(time.Time) error
+	SetWriteDeadline(time.Time) error
+	SetReadLimit(int64)
+	SetPongHandler(func(string) error)
 	Close() error
 }
 
-var wsUpgrade = func(rw http.ResponseWriter, req *http.Request) (wsConn, error) {
+var wsUpgrade = func(rw http.ResponseWriter, req *http.Request, compress bool) (wsConn, error) {
 	upgrader := websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool { return true },
+		// RouteVNC checks the origin before upgrading.
+		CheckOrigin:       func(r *http.Request) bool { return true },
+		EnableCompression: compress,
 	}
 	return upgrader.Upgrade(rw, req, nil)
 }
 
-var wsDial = func(target string) (wsConn, error) {
-	dialer := websocket.Dialer{}
+var wsDial = func(target string, compress bool) (wsConn, error) {
+	dialer := websocket.Dialer{EnableCompression: compress}
 	conn, _, err := dialer.Dial(target, nil)
 	return conn, err
 }
 
+// vncHandshakeTimeout bounds the RFB handshake RouteVNC completes on behalf of
+// the client when it knows the VNC password.
+var vncHandshakeTimeout = 10 * time.Second
+
 var httpClient interface {
 	Do(*http.Request) (*http.Response, error)
 } = http.DefaultClient
 
-func NewService(client browserclient.Client, namespace string, sessionStore store.Store[*types.Session], configStore store.Store[types.BrowserVersions], browserStartTimeout time.Duration) *Service {
-	return &Service{
+type Option func(*Service)
+
+// WithRecorder makes the service record who created, deleted or watched a browser.
+func WithRecorder(recorder history.Recorder) Option {
+	return func(s *Service) { s.recorder = recorder }
+}
+
+// WithAuditor makes the service emit audit events for browser and VNC actions.
+func WithAuditor(auditor audit.Auditor) Option {
+	return func(s *Service) { s.auditor = auditor }
+}
+
+// WithNamespaces sets the namespaces CreateBrowser may start browsers in. The
+// namespace passed to NewService is the default and is always allowed.
+func WithNamespaces(namespaces ...string) Option {
+	return func(s *Service) { s.namespaces = append(s.namespaces, namespaces...) }
+}
+
+// WithOriginCheck decides which Origin headers may open a VNC websocket. By
+// default only the server's own origin is accepted.
+func WithOriginCheck(check func(*http.Request) bool) Option {
+	return func(s *Service) { s.checkOrigin = check }
+}
+
+// WithClusters sends each browser request to the browser-service of the cluster
+// the browser runs in. The client passed to NewService is not used then.
+func WithClusters(registry *cluster.Registry) Option {
+	return func(s *Service) { s.clusters = registry }
+}
+
+// WithVNCPasswords makes RouteVNC answer VNC authentication itself for
+// sessions lookup returns a password for. The client is offered no security
+// and never sees the password.
+func WithVNCPasswords(lookup func(*types.Session) (string, bool)) Option {
+	return func(s *Service) { s.vncPasswords = lookup }
+}
+
+// WithVNCProxy sets the timeouts, buffering and compression of proxied VNC
+// connections; DefaultVNCProxy is used otherwise.
+func WithVNCProxy(cfg VNCProxyConfig) Option {
+	return func(s *Service) { s.vncProxy = cfg }
+}
+
+// WithVNCConnections registers the VNC connections RouteVNC proxies in
+// registry, where they can be listed and force-closed.
+func WithVNCConnections(registry *vncconn.Registry) Option {
+	return func(s *Service) { s.vncConns = registry }
+}
+
+// WithCatalog validates CreateBrowser versions against the BrowserConfigs of
+// the target cluster and namespace only. Without it the versions of every
+// config are accepted, wherever the browser is started.
+func WithCatalog(catalogStore store.Store[types.BrowserConfigCatalog]) Option {
+	return func(s *Service) { s.catalogStore = catalogStore }
+}
+
+// WithAdminCheck limits RouteVNC connections that get a server-side VNC
+// password to the session owner and requests isAdmin accepts. Without it
+// every request may connect.
+func WithAdminCheck(isAdmin func(*http.Request) bool) Option {
+	return func(s *Service) { s.isAdmin = isAdmin }
+}
+
+func NewService(client browserclient.Client, namespace string, sessionStore store.Store[*types.Session], configStore store.Store[types.BrowserVersions], browserStartTimeout time.Duration, opts ...Option) *Service {
+	s := &Service{
 		namespace:           namespace,
+		namespaces:          []string{namespace},
 		client:              client,
 		sessionStore:        sessionStore,
 		configStore:         configStore,
-		browserStartTimeout: browserStartTimeout,
+		browserStartTimeout: new(atomic.Int64),
+		recorder:            history.Discard,
+		auditor:             audit.Discard,
+		checkOrigin:         csrf.NewOrigins().Allowed,
+		vncProxy:            DefaultVNCProxy,
+		vncConns:            vncconn.NewRegistry(),
+		isAdmin:             func(*http.Request) bool { return true },
 	}
+	s.browserStartTimeout.Store(int64(browserStartTimeout))
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SetBrowserStartTimeout changes how long CreateBrowser waits for new
+// browsers; it is safe to call while requests are being served.
+func (s *Service) SetBrowserStartTimeout(timeout time.Duration) {
+	s.browserStartTimeout.Store(int64(timeout))
 }
 
 func (s Service) GetBrowser(rw http.ResponseWriter, req *http.Request) {
 	log := logctx.FromContext(req.Context())
 
 	browserId := chi.URLParam(req, "browserId")
-	session, ok := s.sessionStore.Get(browserId)
+	session, ok := s.lookupSession(req, browserId)
 	if !ok {
 		log.Error().Str("browserId", browserId).Msgf("unknown browserId")
 		http.Error(rw, "session not found", http.StatusNotFound)
@@ -81,6 +199,12 @@ func (s Service) GetBrowser(rw http.ResponseWriter, req *http.Request) {
 
 	}
 
+	if _, ok := s.vncPassword(session); ok {
+		managed := *session
+		managed.VNCPasswordManaged = true
+		session = &managed
+	}
+
 	rw.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(rw).Encode(session); err != nil {
 		log.Error().Err(err).Msg("failed to encode session response")
@@ -90,6 +214,68 @@ func (s Service) GetBrowser(rw http.ResponseWriter, req *http.Request) {
 	log.Info().Str("browserId", browserId).Msg("session retrived")
 }
 
+// resolveVersion maps the requested version of browserName to one the
+// BrowserConfigs of clusterName and namespace offer, see catalog.Resolve. It
+// returns the offered versions, newest first, when there is none. Until any
+// config is known every version is let through.
+func (s *Service) resolveVersion(clusterName, namespace, browserName, requested string) (string, []string, bool) {
+	var available []string
+	add := func(versions []string) {
+		for _, v := range versions {
+			if !slices.Contains(available, v) {
+				available = append(available, v)
+			}
+		}
+	}
+
+	if s.catalogStore != nil {
+		configs := s.catalogStore.List()
+		if len(configs) == 0 {
+			return requested, nil, true
+		}
+		for _, cfg := range configs {
+			if cfg.Cluster != clusterName || cfg.Namespace != namespace {
+				continue
+			}
+			add(slices.Collect(maps.Keys(cfg.Browsers[browserName])))
+		}
+	} else {
+		configs := s.configStore.List()
+		if len(configs) == 0 {
+			return requested, nil, true
+		}
+		for _, cfg := range configs {
+			add(cfg[browserName])
+		}
+	}
+	catalog.SortVersions(available)
+
+	if version, ok := catalog.Resolve(available, requested); ok {
+		return version, nil, true
+	}
+	return "", available, false
+}
+
+// writeUnknownVersion answers 400 with the versions of browserName that can
+// be started instead.
+func writeUnknownVersion(rw http.ResponseWriter, browserName, requested string, available []string) {
+	message := fmt.Sprintf("browser %s version %s is not configured", browserName, requested)
+	if len(available) == 0 {
+		message = fmt.Sprintf("browser %s is not configured", browserName)
+	}
+	response := struct {
+		Error             string   `json:"error"`
+		AvailableVersions []string `json:"availableVersions"`
+	}{
+		Error:             message,
+		AvailableVersions: append([]string{}, available...),
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(rw).Encode(&response) //nolint:errcheck
+}
+
 func (s *Service) GetStatus(rw http.ResponseWriter, req *http.Request) {
 	log := logctx.FromContext(req.Context())
 
@@ -125,14 +311,33 @@ func (s *Service) GetStatus(rw http.ResponseWriter, req *http.Request) {
 func (s *Service) CreateBrowser(rw http.ResponseWriter, req *http.Request) {
 	log := logctx.FromContext(req.Context())
 
+	start := time.Now()
+	outcome := metrics.OutcomeError
+	action := history.Action{Type: history.ActionCreate}
+	defer func() {
+		metrics.CreateBrowserDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+		action.Outcome = outcome
+		s.recordAction(req, action)
+		s.audit(req, audit.Event{
+			Type:           audit.BrowserCreate,
+			BrowserId:      action.BrowserId,
+			BrowserName:    action.BrowserName,
+			BrowserVersion: action.BrowserVersion,
+			Outcome:        outcome,
+		})
+	}()
+
 	if req.Body == nil {
 		log.Error().Msg("request body is required")
+		outcome = metrics.OutcomeBadRequest
 		http.Error(rw, "request body is required", http.StatusBadRequest)
 		return
 	}
 	defer req.Body.Close()
 
 	var request struct {
+		Cluster          string         `json:"cluster"`
+		Namespace        string         `json:"namespace"`
 		BrowserName      string         `json:"browserName"`
 		BrowserVersion   string         `json:"browserVersion"`
 		SelenosisOptions map[string]any `json:"selenosisOptions"`
@@ -140,15 +345,53 @@ func (s *Service) CreateBrowser(rw http.ResponseWriter, req *http.Request) {
 
 	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
 		log.Error().Err(err).Msg("failed to decode create browser request")
+		outcome = metrics.OutcomeBadRequest
 		http.Error(rw, "invalid request body", http.StatusBadRequest)
 		return
 	}
 
 	if request.BrowserName == "" || request.BrowserVersion == "" {
 		log.Error().Msg("browserName and browserVersion are required")
+		outcome = metrics.OutcomeBadRequest
 		http.Error(rw, "browserName and browserVersion are required", http.StatusBadRequest)
 		return
 	}
+	action.BrowserName = request.BrowserName
+	action.BrowserVersion = request.BrowserVersion
+
+	namespace := request.Namespace
+	if namespace == "" {
+		namespace = s.namespace
+	}
+	if !slices.Contains(s.namespaces, namespace) {
+		log.Error().Str("namespace", namespace).Msg("namespace is not allowed")
+		outcome = metrics.OutcomeBadRequest
+		http.Error(rw, "namespace is not allowed", http.StatusBadRequest)
+		return
+	}
+	action.Namespace = namespace
+
+	client, clusterName, ok := s.clientFor(request.Cluster)
+	if !ok {
+		log.Error().Str("cluster", request.Cluster).Msg("unknown cluster")
+		outcome = metrics.OutcomeBadRequest
+		http.Error(rw, "unknown cluster", http.StatusBadRequest)
+		return
+	}
+	action.Cluster = clusterName
+
+	version, available, ok := s.resolveVersion(clusterName, namespace, request.BrowserName, request.BrowserVersion)
+	if !ok {
+		log.Error().Str("cluster", clusterName).Str("namespace", namespace).Str("browserName", request.BrowserName).Str("browserVersion", request.BrowserVersion).Msg("browser version is not configured")
+		outcome = metrics.OutcomeBadRequest
+		writeUnknownVersion(rw, request.BrowserName, request.BrowserVersion, available)
+		return
+	}
+	if version != request.BrowserVersion {
+		log.Info().Str("browserName", request.BrowserName).Str("requested", request.BrowserVersion).Str("browserVersion", version).Msg("browser version resolved")
+	}
+	request.BrowserVersion = version
+	action.BrowserVersion = version
 
 	template := browserv1.Browser{
 		ObjectMeta: metav1.ObjectMeta{
@@ -173,23 +416,36 @@ func (s *Service) CreateBrowser(rw http.ResponseWriter, req *http.Request) {
 	template.ObjectMeta.Annotations, err = setSelenosisOptions(template.ObjectMeta.Annotations, request.SelenosisOptions)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to set selenosis options annotation")
+		outcome = metrics.OutcomeBadRequest
 		http.Error(rw, "invalid selenosis options", http.StatusBadRequest)
 		return
 	}
 
-	browser, err := s.client.Create(req.Context(), s.namespace, &template)
+	browser, err := client.Create(req.Context(), namespace, &template)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to create browser")
 		http.Error(rw, "failed to create browser", http.StatusInternalServerError)
 		return
 	}
+	action.BrowserId = browser.GetName()
 
-	ctx, cancel := context.WithTimeout(req.Context(), s.browserStartTimeout)
+	ctx, cancel := context.WithTimeout(req.Context(), time.Duration(s.browserStartTimeout.Load()))
 	defer cancel()
 
-	session, err := waitForSession(ctx, browser.GetName(), s.sessionStore)
+	key := types.StoreKey(clusterName, namespace, browser.GetName())
+	if browser.GetNamespace() != "" {
+		key = types.StoreKey(clusterName, browser.GetNamespace(), browser.GetName())
+	}
+	session, err := waitForSession(ctx, key, s.sessionStore)
 	if err != nil {
+		var failed *browserFailedError
+		if errors.As(err, &failed) {
+			log.Error().Err(err).Str("browserName", request.BrowserName).Msg("browser failed to start")
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		log.Error().Err(err).Str("browserName", request.BrowserName).Msg("session did not become available in time")
+		outcome = metrics.OutcomeTimeout
 		http.Error(rw, "session did not become available in time", http.StatusInternalServerError)
 		return
 	}
@@ -250,7 +506,8 @@ func (s *Service) CreateBrowser(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	log.Info().Str("browserName", request.BrowserName).Str("browserVersion", request.BrowserVersion).Msg("browser created")
+	outcome = metrics.OutcomeSuccess
+	log.Info().Str("cluster", clusterName).Str("namespace", namespace).Str("browserName", request.BrowserName).Str("browserVersion", request.BrowserVersion).Msg("browser created")
 
 }
 
@@ -259,20 +516,64 @@ func (s *Service) DeleteBrowser(rw http.ResponseWriter, req *http.Request) {
 
 	browserId := chi.URLParam(req, "browserId")
 
-	session, ok := s.sessionStore.Get(browserId)
+	session, ok := s.lookupSession(req, browserId)
 	if !ok {
 		log.Error().Str("browserId", browserId).Msgf("unknown browserId")
+		s.audit(req, audit.Event{Type: audit.BrowserDelete, BrowserId: browserId, Outcome: metrics.OutcomeBadRequest})
 		http.Error(rw, "session not found", http.StatusNotFound)
 		return
 
 	}
 
+	action := history.Action{
+		Type:           history.ActionDelete,
+		Cluster:        session.Cluster,
+		Namespace:      session.Namespace,
+		BrowserId:      browserId,
+		BrowserName:    session.BrowserName,
+		BrowserVersion: session.BrowserVersion,
+		Outcome:        metrics.OutcomeError,
+	}
+	defer func() {
+		s.recordAction(req, action)
+		s.audit(req, audit.Event{
+			Type:           audit.BrowserDelete,
+			BrowserId:      action.BrowserId,
+			BrowserName:    action.BrowserName,
+			BrowserVersion: action.BrowserVersion,
+			Outcome:        action.Outcome,
+		})
+	}()
+
 	if !session.StartedManually {
+		action.Outcome = metrics.OutcomeBadRequest
 		log.Error().Str("browserId", browserId).Str("sessionId", session.SessionId).Msgf("cannot delete session that was not started manually")
 		http.Error(rw, "cannot delete session that was not started manually", http.StatusBadRequest)
 		return
 	}
 
+	if !session.Ready() {
+		// No pod IP yet, so there is no WebDriver session to end: drop the Browser itself.
+		client, _, ok := s.clientFor(session.Cluster)
+		if !ok {
+			log.Error().Str("browserId", browserId).Str("cluster", session.Cluster).Msg("unknown cluster")
+			http.Error(rw, "failed to delete browser", http.StatusInternalServerError)
+			return
+		}
+		namespace := session.Namespace
+		if namespace == "" {
+			namespace = s.namespace
+		}
+		if err := client.Delete(req.Context(), namespace, browserId); err != nil {
+			log.Error().Err(err).Str("browserId", browserId).Msg("failed to delete pending browser")
+			http.Error(rw, "failed to delete browser", http.StatusInternalServerError)
+			return
+		}
+		action.Outcome = metrics.OutcomeSuccess
+		rw.WriteHeader(http.StatusOK)
+		return
+	}
+
 	reqUrl := &url.URL{
 		Scheme: "http",
 		Host:   net.JoinHostPort(session.BrowserIP, "4445"),
@@ -303,6 +604,7 @@ func (s *Service) DeleteBrowser(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	action.Outcome = metrics.OutcomeSuccess
 	rw.WriteHeader(http.StatusOK)
 }
 
@@ -311,16 +613,60 @@ func (s *Service) RouteVNC(rw http.ResponseWriter, req *http.Request) {
 
 	browserId := chi.URLParam(req, "browserId")
 
-	session, ok := s.sessionStore.Get(browserId)
+	connect := audit.Event{Type: audit.VNCConnect, BrowserId: browserId, Outcome: metrics.OutcomeBadRequest}
+
+	if !s.checkOrigin(req) {
+		log.Warn().Str("browserId", browserId).Str("origin", req.Header.Get("Origin")).Msg("vnc websocket from disallowed origin rejected")
+		connect.Outcome = audit.OutcomeDenied
+		s.audit(req, connect)
+		http.Error(rw, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	// Share links open the session they were signed for; the cluster and
+	// namespace query parameters are not consulted for them.
+	viewer, shared := share.FromContext(req.Context())
+	var session *types.Session
+	var ok bool
+	if shared {
+		session, ok = s.sessionStore.Get(viewer.Link.Key())
+	} else {
+		session, ok = s.lookupSession(req, browserId)
+	}
 	if !ok {
 		log.Error().Str("browserId", browserId).Msgf("unknown browserId")
+		s.audit(req, connect)
 		http.Error(rw, "invalid session", http.StatusBadRequest)
 		return
 	}
+	connect.BrowserName = session.BrowserName
+	connect.BrowserVersion = session.BrowserVersion
+
+	if !session.Ready() {
+		log.Error().Str("browserId", browserId).Str("phase", string(session.Phase)).Msg("session is not ready")
+		s.audit(req, connect)
+		http.Error(rw, "session is not ready", http.StatusConflict)
+		return
+	}
+
+	// A server-side password gives whoever connects full control without
+	// knowing it, so only the owner and admins get it. Share link viewers
+	// were let in by one of them, with the link's view-only setting.
+	owner, _ := auth.OwnerFrom(req.Context())
+	password, managed := s.vncPassword(session)
+	if managed && !shared && !s.isAdmin(req) && (session.Owner == "" || session.Owner != owner.Name) {
+		log.Warn().Str("browserId", browserId).Str("owner", owner.Name).Msg("vnc connection to another user's session rejected")
+		connect.Outcome = audit.OutcomeDenied
+		s.audit(req, connect)
+		http.Error(rw, "only the session owner may connect to its vnc", http.StatusForbidden)
+		return
+	}
 
-	client, err := wsUpgrade(rw, req)
+	client, err := wsUpgrade(rw, req, s.vncProxy.Compression)
 	if err != nil {
 		log.Err(err).Str("browserId", browserId).Msg("client ws upgrade failed")
+		connect.Outcome = metrics.OutcomeError
+		s.audit(req, connect)
 		return
 	}
 	defer client.Close()
@@ -331,72 +677,313 @@ func (s *Service) RouteVNC(rw http.ResponseWriter, req *http.Request) {
 		Path:   fmt.Sprintf("/selenosis/v1/vnc/%s", session.SessionId),
 	}
 
-	backend, err := wsDial(targetURL.String())
+	backend, err := wsDial(targetURL.String(), s.vncProxy.Compression)
 	if err != nil {
 		log.Err(err).Str("browserId", browserId).Str("url", targetURL.String()).Msg("backend ws dial failed")
+		connect.Outcome = metrics.OutcomeError
+		s.audit(req, connect)
 		return
 	}
 	defer backend.Close()
 
-	log.Info().Str("browserId", browserId).Msg("ws connection established")
-
-	errCh := make(chan error, 2)
-
-	go func() {
-		for {
-			mt, data, err := client.ReadMessage()
-			if err != nil {
-				if isNormalWSDisconnect(err) {
-					errCh <- nil
-					return
-				}
-				errCh <- err
-				return
-			}
-
-			if err := backend.WriteMessage(mt, data); err != nil {
-				errCh <- err
-				return
-			}
+	// Viewers who came through a view-only share link only get to watch.
+	var viewOnly *rfb.ViewOnly
+	var revoked <-chan struct{}
+	if shared {
+		revoked = viewer.Done()
+		if viewer.Link.ViewOnly {
+			viewOnly = &rfb.ViewOnly{}
 		}
-	}()
+	}
 
-	go func() {
-		for {
-			mt, data, err := backend.ReadMessage()
-			if err != nil {
-
-				if isNormalWSDisconnect(err) {
-					errCh <- nil
-					return
-				}
-				errCh <- err
-				return
-			}
+	account := vncconn.Connection{
+		BrowserId:      browserId,
+		BrowserName:    session.BrowserName,
+		BrowserVersion: session.BrowserVersion,
+		Owner:          owner.Name,
+		SessionOwner:   session.Owner,
+		SourceIP:       ratelimit.RequestIP(req),
+		ViewOnly:       viewOnly != nil,
+	}
+	if shared {
+		account.ShareID = viewer.Link.ID
+	}
 
-			if err := client.WriteMessage(mt, data); err != nil {
-				errCh <- err
-				return
+	// The connection is registered before any handshake, so admins and the
+	// shutdown drain see, and can end, connections still authenticating.
+	proxy := newVNCProxy(s.vncProxy, client, backend, viewOnly)
+	tracker := s.vncConns.Open(account, func(reason string) { proxy.stop(forcedClose(reason)) })
+	proxy.track(tracker)
+
+	// With a server-side password the client and the backend each see only
+	// their own half of the handshake.
+	if managed {
+		if err := handshakeVNC(proxy, password, revoked); err != nil {
+			reason, code := vncconn.CloseBackendError, 0
+			if errors.Is(err, errClientHandshake) {
+				reason = vncconn.CloseClientError
+			}
+			select {
+			case <-proxy.done:
+				reason, code = proxy.result.closeReason(), proxy.result.code
+			default:
 			}
+			account = tracker.Finish(reason, code)
+			log.Err(err).Str("browserId", browserId).Str("connectionId", account.ID).Str("closeReason", reason).Msg("vnc handshake failed")
+			connect.Outcome = metrics.OutcomeError
+			connect.Details = map[string]any{"error": err.Error(), "connectionId": account.ID, "closeReason": reason}
+			s.audit(req, connect)
+			return
 		}
-	}()
+	}
 
-	err = <-errCh
+	log.Info().Str("browserId", browserId).Msg("ws connection established")
+	s.recordAction(req, history.Action{
+		Type:           history.ActionVNC,
+		Cluster:        session.Cluster,
+		Namespace:      session.Namespace,
+		BrowserId:      browserId,
+		BrowserName:    session.BrowserName,
+		BrowserVersion: session.BrowserVersion,
+		Outcome:        metrics.OutcomeSuccess,
+	})
+	connect.Outcome = metrics.OutcomeSuccess
+	s.audit(req, connect)
+
+	metrics.VNCConnectionsOpen.Inc()
+	defer metrics.VNCConnectionsOpen.Dec()
+
+	proxy.start()
+	select {
+	case <-proxy.done:
+	case <-revoked:
+		log.Info().Str("browserId", browserId).Str("shareId", viewer.Link.ID).Msg("share link expired or was revoked, closing vnc connection")
+		proxy.stop(&closeError{code: websocket.ClosePolicyViolation, text: "share link expired or revoked", reason: reasonRevoked})
+	}
+	result := proxy.close()
+	err = result.err
+	account = tracker.Finish(result.closeReason(), result.code)
+	duration := account.EndedAt.Sub(account.StartedAt)
+	metrics.VNCConnectionDuration.WithLabelValues(account.CloseReason).Observe(duration.Seconds())
+
+	disconnect := connect
+	disconnect.Type = audit.VNCDisconnect
+	disconnect.Details = map[string]any{
+		"connectionId":    account.ID,
+		"startedAt":       account.StartedAt,
+		"endedAt":         account.EndedAt,
+		"durationSeconds": duration.Seconds(),
+		"clientToServer":  account.ClientToServer,
+		"serverToClient":  account.ServerToClient,
+		"closeCode":       result.code,
+		"closeReason":     account.CloseReason,
+		"closeMessage":    result.text,
+	}
+	if err != nil {
+		disconnect.Outcome = metrics.OutcomeError
+		disconnect.Details["error"] = err.Error()
+	}
+	s.audit(req, disconnect)
 
 	switch err {
 	case nil:
 		log.Info().
 			Str("browserId", browserId).
+			Str("connectionId", account.ID).
+			Str("closeReason", account.CloseReason).
+			Dur("duration", duration).
+			Int64("bytesToServer", account.ClientToServer.Bytes).
+			Int64("bytesToClient", account.ServerToClient.Bytes).
 			Msg("vnc connection closed")
 
 	default:
 		log.Error().
 			Err(err).
 			Str("browserId", browserId).
+			Str("connectionId", account.ID).
+			Str("closeReason", account.CloseReason).
+			Int("closeCode", result.code).
 			Msg("vnc connection terminated with error")
 	}
 }
 
+// vncPassword returns the password RouteVNC answers the VNC server of session
+// with, if the server knows one.
+func (s *Service) vncPassword(session *types.Session) (string, bool) {
+	if s.vncPasswords == nil {
+		return "", false
+	}
+	return s.vncPasswords(session)
+}
+
+// errClientHandshake marks handshake failures on the viewer's side.
+var errClientHandshake = errors.New("client handshake")
+
+// handshakeVNC runs authenticateVNC for proxy before it is started. Stopping
+// the proxy meanwhile, through the vncconn registry or a revoked share link,
+// closes both sides so the handshake fails at once instead of running out
+// its timeout.
+func handshakeVNC(proxy *vncProxy, password string, revoked <-chan struct{}) error {
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-finished:
+			return
+		case <-revoked:
+			proxy.stop(&closeError{code: websocket.ClosePolicyViolation, text: "share link expired or revoked", reason: reasonRevoked})
+		case <-proxy.done:
+		}
+		proxy.client.Close()
+		proxy.backend.Close()
+	}()
+	return authenticateVNC(proxy.client, proxy.backend, password, proxy.viewOnly)
+}
+
+// authenticateVNC authenticates to backend with password and completes the
+// handshake with client offering no security, up to ClientInit. A backend
+// that refuses the password is reported to the client as a failure reason.
+// Whatever either side sent past its handshake is forwarded, client data
+// through viewOnly when set.
+func authenticateVNC(client, backend wsConn, password string, viewOnly *rfb.ViewOnly) error {
+	timer := time.AfterFunc(vncHandshakeTimeout, func() {
+		client.Close()
+		backend.Close()
+	})
+	defer timer.Stop()
+
+	clientStream, backendStream := &wsStream{conn: client}, &wsStream{conn: backend}
+	if _, err := rfb.Authenticate(backendStream, password); err != nil {
+		rfb.Reject(clientStream, "the server could not authenticate to the browser's VNC server") //nolint:errcheck
+		return fmt.Errorf("authenticate to backend: %w", err)
+	}
+	if err := rfb.Accept(clientStream); err != nil {
+		return fmt.Errorf("%w: %w", errClientHandshake, err)
+	}
+
+	clientRest := clientStream.buf
+	if viewOnly != nil {
+		viewOnly.HandshakeDone()
+		var err error
+		if clientRest, err = viewOnly.Filter(clientRest); err != nil {
+			return err
+		}
+	}
+	if len(clientRest) > 0 {
+		if _, err := backendStream.Write(clientRest); err != nil {
+			return err
+		}
+	}
+	if len(backendStream.buf) > 0 {
+		if _, err := clientStream.Write(backendStream.buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wsStream reads and writes a websocket as a byte stream of binary messages.
+type wsStream struct {
+	conn wsConn
+	buf  []byte
+}
+
+func (w *wsStream) Read(p []byte) (int, error) {
+	for len(w.buf) == 0 {
+		_, data, err := w.conn.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		w.buf = data
+	}
+	n := copy(p, w.buf)
+	w.buf = w.buf[n:]
+	return n, nil
+}
+
+func (w *wsStream) Write(p []byte) (int, error) {
+	if err := w.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// lookupSession finds the session of browserId, optionally narrowed down by the
+// cluster and namespace query parameters. Without them the default cluster and
+// namespace are tried first; otherwise exactly one Browser must match.
+func (s *Service) lookupSession(req *http.Request, browserId string) (*types.Session, bool) {
+	query := req.URL.Query()
+	clusterName, namespace := query.Get("cluster"), query.Get("namespace")
+
+	if clusterName == "" && namespace == "" {
+		if session, ok := s.sessionStore.Get(types.StoreKey(s.defaultCluster(), s.namespace, browserId)); ok {
+			return session, true
+		}
+	}
+
+	var found *types.Session
+	for _, session := range s.sessionStore.List() {
+		if session.BrowserId != browserId ||
+			(clusterName != "" && session.Cluster != clusterName) ||
+			(namespace != "" && session.Namespace != namespace) {
+			continue
+		}
+		if found != nil {
+			logctx.FromContext(req.Context()).Error().Str("browserId", browserId).Msg("browserId matches several browsers, cluster or namespace parameter is required")
+			return nil, false
+		}
+		found = session
+	}
+	return found, found != nil
+}
+
+// clientFor returns the browser-service client of the named cluster and the
+// cluster's name; an empty name selects the default cluster.
+func (s *Service) clientFor(name string) (browserclient.Client, string, bool) {
+	if s.clusters == nil {
+		return s.client, "", name == ""
+	}
+	backend, ok := s.clusters.Get(name)
+	if !ok {
+		return nil, "", false
+	}
+	return backend.Browsers, backend.Name, true
+}
+
+func (s *Service) defaultCluster() string {
+	if s.clusters == nil {
+		return ""
+	}
+	return s.clusters.Default().Name
+}
+
+// LookupSession returns the session browserId names, taking the cluster and
+// namespace query parameters into account.
+func (s *Service) LookupSession(req *http.Request, browserId string) (*types.Session, bool) {
+	return s.lookupSession(req, browserId)
+}
+
+func (s *Service) audit(req *http.Request, ev audit.Event) {
+	if owner, ok := auth.OwnerFrom(req.Context()); ok {
+		ev.Owner = owner.Name
+	}
+	if viewer, ok := share.FromContext(req.Context()); ok {
+		details := map[string]any{"shareId": viewer.Link.ID}
+		maps.Copy(details, ev.Details)
+		ev.Details = details
+	}
+	ev.SourceIP = ratelimit.RequestIP(req)
+	s.auditor.Audit(req.Context(), ev)
+}
+
+func (s *Service) recordAction(req *http.Request, action history.Action) {
+	if owner, ok := auth.OwnerFrom(req.Context()); ok {
+		action.User = owner.Name
+	}
+	action.Time = time.Now()
+	s.recorder.RecordAction(req.Context(), action)
+}
+
 func isNormalWSDisconnect(err error) bool {
 	if err == nil {
 		return false
@@ -436,16 +1023,46 @@ func setSelenosisOptions(ann map[string]string, opts map[string]any) (map[string
 	return ann, nil
 }
 
-func waitForSession(ctx context.Context, browserName string, store store.Store[*types.Session]) (*types.Session, error) {
+type browserFailedError struct {
+	browserName string
+	reason      string
+	message     string
+}
+
+func (e *browserFailedError) Error() string {
+	msg := fmt.Sprintf("browser %s failed to start", e.browserName)
+	if e.reason != "" {
+		msg += ": " + e.reason
+	}
+	if e.message != "" {
+		msg += ": " + e.message
+	}
+	return msg
+}
+
+// waitForSession polls the store until the session has a pod IP. A browser
+// that reaches the Failed phase first is reported as *browserFailedError.
+func waitForSession(ctx context.Context, key string, store store.Store[*types.Session]) (*types.Session, error) {
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
+	start := time.Now()
 	for {
 		select {
 		case <-ctx.Done():
-			return nil, fmt.Errorf("timeout waiting for session: %s", browserName)
+			metrics.WaitForSessionDuration.WithLabelValues(metrics.OutcomeTimeout).Observe(time.Since(start).Seconds())
+			return nil, fmt.Errorf("timeout waiting for session: %s", key)
 		case <-ticker.C:
-			if session, ok := store.Get(browserName); ok {
+			session, ok := store.Get(key)
+			if !ok {
+				continue
+			}
+			if session.Phase == corev1.PodFailed {
+				metrics.WaitForSessionDuration.WithLabelValues(metrics.OutcomeError).Observe(time.Since(start).Seconds())
+				return nil, &browserFailedError{browserName: session.BrowserName, reason: session.Reason, message: session.Message}
+			}
+			if session.Ready() {
+				metrics.WaitForSessionDuration.WithLabelValues(metrics.OutcomeSuccess).Observe(time.Since(start).Seconds())
 				return session, nil
 			}
 		}
@@ -8,17 +8,30 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	browserv1 "github.com/alcounit/browser-controller/apis/browser/v1"
 	browserclient "github.com/alcounit/browser-service/pkg/client/browser"
 	"github.com/alcounit/browser-service/pkg/event"
+	"github.com/alcounit/browser-ui/pkg/audit"
+	"github.com/alcounit/browser-ui/pkg/cluster"
+	"github.com/alcounit/browser-ui/pkg/csrf"
+	"github.com/alcounit/browser-ui/pkg/history"
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	"github.com/alcounit/browser-ui/pkg/rfb"
+	"github.com/alcounit/browser-ui/pkg/share"
 	"github.com/alcounit/browser-ui/pkg/types"
+	"github.com/alcounit/browser-ui/pkg/vncconn"
 	"github.com/alcounit/seleniferous/v2/pkg/store"
 	"github.com/alcounit/selenosis/v2/pkg/auth"
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -154,6 +167,9 @@ type fakeWSConn struct {
 	writeCh  chan fakeWSMessage
 	closed   bool
 	writeErr error
+
+	mu         sync.Mutex
+	closeFrame []byte
 }
 
 func newFakeWSConn() *fakeWSConn {
@@ -182,6 +198,30 @@ func (c *fakeWSConn) WriteMessage(mt int, data []byte) error {
 	return nil
 }
 
+func (c *fakeWSConn) WriteControl(mt int, data []byte, _ time.Time) error {
+	if mt == websocket.CloseMessage {
+		c.mu.Lock()
+		c.closeFrame = data
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// closeCode returns the code of the close frame sent to the connection.
+func (c *fakeWSConn) closeCode() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.closeFrame) < 2 {
+		return 0
+	}
+	return int(c.closeFrame[0])<<8 | int(c.closeFrame[1])
+}
+
+func (c *fakeWSConn) SetReadDeadline(time.Time) error   { return nil }
+func (c *fakeWSConn) SetWriteDeadline(time.Time) error  { return nil }
+func (c *fakeWSConn) SetReadLimit(int64)                {}
+func (c *fakeWSConn) SetPongHandler(func(string) error) {}
+
 func (c *fakeWSConn) Close() error {
 	c.closed = true
 	return nil
@@ -193,10 +233,10 @@ func TestRouteVNCSuccessProxy(t *testing.T) {
 
 	prevUpgrade := wsUpgrade
 	prevDial := wsDial
-	wsUpgrade = func(rw http.ResponseWriter, req *http.Request) (wsConn, error) {
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
 		return clientConn, nil
 	}
-	wsDial = func(target string) (wsConn, error) {
+	wsDial = func(target string, _ bool) (wsConn, error) {
 		return backendConn, nil
 	}
 	defer func() {
@@ -223,8 +263,6 @@ func TestRouteVNCSuccessProxy(t *testing.T) {
 
 	clientConn.readCh <- fakeWSMessage{mt: websocket.TextMessage, data: []byte("ping")}
 	backendConn.readCh <- fakeWSMessage{mt: websocket.TextMessage, data: []byte("pong")}
-	close(clientConn.readCh)
-	close(backendConn.readCh)
 
 	select {
 	case msg := <-backendConn.writeCh:
@@ -244,6 +282,9 @@ func TestRouteVNCSuccessProxy(t *testing.T) {
 		t.Fatalf("timeout waiting for client write")
 	}
 
+	close(clientConn.readCh)
+	close(backendConn.readCh)
+
 	select {
 	case <-done:
 	case <-time.After(500 * time.Millisecond):
@@ -257,10 +298,10 @@ func TestRouteVNCClientReadError(t *testing.T) {
 
 	prevUpgrade := wsUpgrade
 	prevDial := wsDial
-	wsUpgrade = func(rw http.ResponseWriter, req *http.Request) (wsConn, error) {
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
 		return clientConn, nil
 	}
-	wsDial = func(target string) (wsConn, error) {
+	wsDial = func(target string, _ bool) (wsConn, error) {
 		return backendConn, nil
 	}
 	defer func() {
@@ -301,10 +342,10 @@ func TestRouteVNCBackendReadError(t *testing.T) {
 
 	prevUpgrade := wsUpgrade
 	prevDial := wsDial
-	wsUpgrade = func(rw http.ResponseWriter, req *http.Request) (wsConn, error) {
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
 		return clientConn, nil
 	}
-	wsDial = func(target string) (wsConn, error) {
+	wsDial = func(target string, _ bool) (wsConn, error) {
 		return backendConn, nil
 	}
 	defer func() {
@@ -346,10 +387,10 @@ func TestRouteVNCBackendWriteError(t *testing.T) {
 
 	prevUpgrade := wsUpgrade
 	prevDial := wsDial
-	wsUpgrade = func(rw http.ResponseWriter, req *http.Request) (wsConn, error) {
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
 		return clientConn, nil
 	}
-	wsDial = func(target string) (wsConn, error) {
+	wsDial = func(target string, _ bool) (wsConn, error) {
 		return backendConn, nil
 	}
 	defer func() {
@@ -392,10 +433,10 @@ func TestRouteVNCClientWriteError(t *testing.T) {
 
 	prevUpgrade := wsUpgrade
 	prevDial := wsDial
-	wsUpgrade = func(rw http.ResponseWriter, req *http.Request) (wsConn, error) {
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
 		return clientConn, nil
 	}
-	wsDial = func(target string) (wsConn, error) {
+	wsDial = func(target string, _ bool) (wsConn, error) {
 		return backendConn, nil
 	}
 	defer func() {
@@ -433,7 +474,7 @@ func TestRouteVNCClientWriteError(t *testing.T) {
 
 func TestRouteVNCUpgradeFailure(t *testing.T) {
 	prevUpgrade := wsUpgrade
-	wsUpgrade = func(rw http.ResponseWriter, req *http.Request) (wsConn, error) {
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
 		return nil, errors.New("upgrade failed")
 	}
 	defer func() {
@@ -459,10 +500,10 @@ func TestRouteVNCBackendDialFailure(t *testing.T) {
 
 	prevUpgrade := wsUpgrade
 	prevDial := wsDial
-	wsUpgrade = func(rw http.ResponseWriter, req *http.Request) (wsConn, error) {
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
 		return clientConn, nil
 	}
-	wsDial = func(target string) (wsConn, error) {
+	wsDial = func(target string, _ bool) (wsConn, error) {
 		return nil, errors.New("dial failed")
 	}
 	defer func() {
@@ -491,7 +532,7 @@ func TestDefaultWSUpgradeFailure(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
 	rw := httptest.NewRecorder()
 
-	if _, err := wsUpgrade(rw, req); err == nil {
+	if _, err := wsUpgrade(rw, req, false); err == nil {
 		t.Fatalf("expected upgrade error")
 	}
 }
@@ -499,7 +540,7 @@ func TestDefaultWSUpgradeFailure(t *testing.T) {
 func TestDefaultWSUpgradeCheckOrigin(t *testing.T) {
 	// Use a real HTTP test server so that CheckOrigin is invoked.
 	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		conn, err := wsUpgrade(rw, req)
+		conn, err := wsUpgrade(rw, req, true)
 		if err != nil {
 			return
 		}
@@ -515,8 +556,87 @@ func TestDefaultWSUpgradeCheckOrigin(t *testing.T) {
 	conn.Close()
 }
 
+func TestRouteVNCRejectsCrossOriginWebsocket(t *testing.T) {
+	upgraded := false
+	prevUpgrade := wsUpgrade
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
+		upgraded = true
+		return nil, errors.New("upgrade not expected")
+	}
+	defer func() { wsUpgrade = prevUpgrade }()
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1"})
+	auditor := &recordingAuditor{}
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithAuditor(auditor))
+
+	req := requestWithParam(http.MethodGet, "/api/v1/browsers/browser-1/vnc", "browserId", "browser-1")
+	req.Header.Set("Origin", "https://evil.example")
+	rw := httptest.NewRecorder()
+
+	svc.RouteVNC(rw, req)
+
+	if rw.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rw.Code)
+	}
+	if upgraded {
+		t.Fatalf("expected no websocket upgrade for a cross-origin request")
+	}
+	if len(auditor.events) != 1 || auditor.events[0].Outcome != audit.OutcomeDenied {
+		t.Fatalf("expected one denied audit event, got %+v", auditor.events)
+	}
+}
+
+func TestRouteVNCAllowsConfiguredOrigin(t *testing.T) {
+	upgraded := false
+	prevUpgrade := wsUpgrade
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
+		upgraded = true
+		return nil, errors.New("stop after upgrade")
+	}
+	defer func() { wsUpgrade = prevUpgrade }()
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1"})
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second,
+		WithOriginCheck(csrf.NewOrigins("https://ui.example.com").Allowed))
+
+	for _, origin := range []string{"https://ui.example.com", "http://example.com"} {
+		upgraded = false
+		req := requestWithParam(http.MethodGet, "/api/v1/browsers/browser-1/vnc", "browserId", "browser-1")
+		req.Header.Set("Origin", origin)
+
+		svc.RouteVNC(httptest.NewRecorder(), req)
+
+		if !upgraded {
+			t.Fatalf("expected websocket upgrade for origin %s", origin)
+		}
+	}
+}
+
+func TestRouteVNCCrossOriginDialFails(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1"})
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second)
+
+	router := chi.NewRouter()
+	router.Get("/api/v1/browsers/{browserId}/vnc", svc.RouteVNC)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// A page on another site opening a websocket to the logged-in user's UI.
+	wsURL := "ws" + server.URL[len("http"):] + "/api/v1/browsers/browser-1/vnc"
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {"https://evil.example"}})
+	if err == nil {
+		t.Fatalf("expected cross-origin websocket to be rejected")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %+v", http.StatusForbidden, resp)
+	}
+}
+
 func TestDefaultWSDialFailure(t *testing.T) {
-	if _, err := wsDial("ws://127.0.0.1:0"); err == nil {
+	if _, err := wsDial("ws://127.0.0.1:0", false); err == nil {
 		t.Fatalf("expected dial error")
 	}
 }
@@ -545,10 +665,14 @@ type fakeBrowserClient struct {
 	createErr   error
 	browser     *browserv1.Browser
 	lastCreated *browserv1.Browser
+	deleteErr   error
+	lastDeleted string
+	namespace   string
 }
 
 func (c *fakeBrowserClient) Create(ctx context.Context, namespace string, browser *browserv1.Browser) (*browserv1.Browser, error) {
 	c.lastCreated = browser
+	c.namespace = namespace
 	if c.createErr != nil {
 		return nil, c.createErr
 	}
@@ -563,7 +687,9 @@ func (c *fakeBrowserClient) Get(ctx context.Context, namespace, name string) (*b
 }
 
 func (c *fakeBrowserClient) Delete(ctx context.Context, namespace, name string) error {
-	panic("not used")
+	c.lastDeleted = name
+	c.namespace = namespace
+	return c.deleteErr
 }
 
 func (c *fakeBrowserClient) List(ctx context.Context, namespace string) ([]*browserv1.Browser, error) {
@@ -649,6 +775,82 @@ func TestCreateBrowserClientCreateError(t *testing.T) {
 	}
 }
 
+func TestCreateBrowserResolvesVersionAliases(t *testing.T) {
+	configs := store.NewDefaultStore[types.BrowserVersions]()
+	configs.Set("default/cfg-1", types.BrowserVersions{"chrome": {"145.0", "146.0.7680"}})
+	configs.Set("default/cfg-2", types.BrowserVersions{"chrome": {"146.0", "147.0"}})
+
+	for requested, want := range map[string]string{"146": "146.0.7680", "latest": "147.0", "145.0": "145.0"} {
+		cl := &fakeBrowserClient{createErr: errors.New("stop after create")}
+		svc := NewService(cl, "default", store.NewDefaultStore[*types.Session](), configs, 5*time.Second)
+		body := `{"browserName":"chrome","browserVersion":"` + requested + `"}`
+		svc.CreateBrowser(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/browsers", strings.NewReader(body)))
+
+		if cl.lastCreated == nil || cl.lastCreated.Spec.BrowserVersion != want {
+			t.Fatalf("expected %q to be created as %q, got %+v", requested, want, cl.lastCreated)
+		}
+	}
+}
+
+func TestCreateBrowserRejectsUnknownVersion(t *testing.T) {
+	configs := store.NewDefaultStore[types.BrowserVersions]()
+	configs.Set("default/cfg-1", types.BrowserVersions{"chrome": {"145.0", "146.0"}})
+	cl := &fakeBrowserClient{}
+	svc := NewService(cl, "default", store.NewDefaultStore[*types.Session](), configs, 5*time.Second)
+
+	body := `{"browserName":"chrome","browserVersion":"999"}`
+	rw := httptest.NewRecorder()
+	svc.CreateBrowser(rw, httptest.NewRequest(http.MethodPost, "/browsers", strings.NewReader(body)))
+
+	if rw.Code != http.StatusBadRequest || cl.lastCreated != nil {
+		t.Fatalf("expected 400 without creating a browser, got %d", rw.Code)
+	}
+	var response struct {
+		Error             string   `json:"error"`
+		AvailableVersions []string `json:"availableVersions"`
+	}
+	if err := json.NewDecoder(rw.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.AvailableVersions) != 2 || response.AvailableVersions[0] != "146.0" || response.AvailableVersions[1] != "145.0" {
+		t.Fatalf("expected available versions newest first, got %+v", response)
+	}
+
+	rw = httptest.NewRecorder()
+	svc.CreateBrowser(rw, httptest.NewRequest(http.MethodPost, "/browsers", strings.NewReader(`{"browserName":"opera","browserVersion":"1.0"}`)))
+	if rw.Code != http.StatusBadRequest || !strings.Contains(rw.Body.String(), "browser opera is not configured") {
+		t.Fatalf("expected an unknown browser to be rejected, got %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+func TestCreateBrowserValidatesAgainstTargetNamespace(t *testing.T) {
+	catalogs := store.NewDefaultStore[types.BrowserConfigCatalog]()
+	catalogs.Set("default/cfg", types.BrowserConfigCatalog{Namespace: "default", Name: "cfg", Browsers: map[string]map[string]types.BrowserImage{
+		"chrome": {"145.0": {}},
+	}})
+	catalogs.Set("qa/cfg", types.BrowserConfigCatalog{Namespace: "qa", Name: "cfg", Browsers: map[string]map[string]types.BrowserImage{
+		"chrome": {"146.0": {}},
+	}})
+
+	cl := &fakeBrowserClient{createErr: errors.New("stop after create")}
+	svc := NewService(cl, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), 5*time.Second,
+		WithNamespaces("qa"), WithCatalog(catalogs))
+
+	rw := httptest.NewRecorder()
+	svc.CreateBrowser(rw, httptest.NewRequest(http.MethodPost, "/browsers", strings.NewReader(`{"browserName":"chrome","browserVersion":"146.0"}`)))
+	if rw.Code != http.StatusBadRequest || cl.lastCreated != nil {
+		t.Fatalf("expected a version of another namespace to be rejected, got %d", rw.Code)
+	}
+	if !strings.Contains(rw.Body.String(), `"availableVersions":["145.0"]`) {
+		t.Fatalf("expected only the default namespace's versions, got %s", rw.Body.String())
+	}
+
+	svc.CreateBrowser(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/browsers", strings.NewReader(`{"namespace":"qa","browserName":"chrome","browserVersion":"146"}`)))
+	if cl.lastCreated == nil || cl.lastCreated.Spec.BrowserVersion != "146.0" {
+		t.Fatalf("expected 146 to resolve in qa, got %+v", cl.lastCreated)
+	}
+}
+
 func TestCreateBrowserWaitForSessionTimeout(t *testing.T) {
 	// Use a very short timeout so waitForSession times out immediately.
 	// The session store is empty so waitForSession will never find the session.
@@ -680,7 +882,7 @@ func TestCreateBrowserBuildRequestError(t *testing.T) {
 	}
 
 	// BrowserIP with control char → url.URL.String() → invalid URL escape
-	st.Set("browser-bad-ip", &types.Session{
+	st.Set("default/browser-bad-ip", &types.Session{
 		SessionId: "sess-bad-ip",
 		BrowserId: "browser-bad-ip",
 		BrowserIP: "127.0.0.1\x01",
@@ -713,7 +915,7 @@ func TestCreateBrowserHTTPClientError(t *testing.T) {
 		},
 	}
 
-	st.Set("browser-xyz", &types.Session{
+	st.Set("default/browser-xyz", &types.Session{
 		SessionId: "sess-xyz",
 		BrowserId: "browser-xyz",
 		BrowserIP: "127.0.0.1",
@@ -748,7 +950,7 @@ func TestCreateBrowserSeleniferous500(t *testing.T) {
 		},
 	}
 
-	st.Set("browser-abc", &types.Session{
+	st.Set("default/browser-abc", &types.Session{
 		SessionId: "sess-abc",
 		BrowserId: "browser-abc",
 		BrowserIP: "127.0.0.1",
@@ -786,7 +988,7 @@ func TestCreateBrowserEncodeError(t *testing.T) {
 		},
 	}
 
-	st.Set("browser-enc", &types.Session{
+	st.Set("default/browser-enc", &types.Session{
 		SessionId: "sess-enc",
 		BrowserId: "browser-enc",
 		BrowserIP: "127.0.0.1",
@@ -825,7 +1027,7 @@ func TestCreateBrowserSuccess(t *testing.T) {
 		},
 	}
 
-	st.Set("browser-ok", &types.Session{
+	st.Set("default/browser-ok", &types.Session{
 		SessionId: "sess-ok",
 		BrowserId: "browser-ok",
 		BrowserIP: "127.0.0.1",
@@ -862,7 +1064,7 @@ func TestCreateBrowserSuccess(t *testing.T) {
 
 func TestWaitForSessionAlreadyInStore(t *testing.T) {
 	st := store.NewDefaultStore[*types.Session]()
-	st.Set("browser-1", &types.Session{SessionId: "sess-1"})
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserIP: "127.0.0.1"})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -949,7 +1151,7 @@ func TestCreateBrowserSetsOwnerLabel(t *testing.T) {
 		},
 	}
 
-	st.Set("browser-owner", &types.Session{
+	st.Set("default/browser-owner", &types.Session{
 		SessionId: "sess-owner",
 		BrowserId: "browser-owner",
 		BrowserIP: "127.0.0.1",
@@ -994,7 +1196,7 @@ func TestCreateBrowserNoOwnerLabel(t *testing.T) {
 		},
 	}
 
-	st.Set("browser-noowner", &types.Session{
+	st.Set("default/browser-noowner", &types.Session{
 		SessionId: "sess-noowner",
 		BrowserId: "browser-noowner",
 		BrowserIP: "127.0.0.1",
@@ -1194,3 +1396,971 @@ func TestDeleteBrowserBuildRequestError(t *testing.T) {
 		t.Fatalf("expected status 500, got %d", rw.Code)
 	}
 }
+
+func TestRouteVNCRecordsProxiedBytes(t *testing.T) {
+	clientConn := newFakeWSConn()
+	backendConn := newFakeWSConn()
+
+	prevUpgrade := wsUpgrade
+	prevDial := wsDial
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
+		return clientConn, nil
+	}
+	wsDial = func(target string, _ bool) (wsConn, error) {
+		return backendConn, nil
+	}
+	defer func() {
+		wsUpgrade = prevUpgrade
+		wsDial = prevDial
+	}()
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1"})
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second)
+
+	toBackend := metrics.VNCBytesProxied.WithLabelValues(metrics.DirectionClientToBackend)
+	toClient := metrics.VNCBytesProxied.WithLabelValues(metrics.DirectionBackendToClient)
+	beforeToBackend := testutil.ToFloat64(toBackend)
+	beforeToClient := testutil.ToFloat64(toClient)
+
+	req := requestWithParam(http.MethodGet, "/api/v1/browsers/browser-1/vnc", "browserId", "browser-1")
+	done := make(chan struct{})
+	go func() {
+		svc.RouteVNC(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	clientConn.readCh <- fakeWSMessage{mt: websocket.BinaryMessage, data: []byte("1234")}
+	<-backendConn.writeCh
+	backendConn.readCh <- fakeWSMessage{mt: websocket.BinaryMessage, data: []byte("123456")}
+	<-clientConn.writeCh
+	close(clientConn.readCh)
+	close(backendConn.readCh)
+
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("timeout waiting for handler to finish")
+	}
+
+	if got := testutil.ToFloat64(toBackend) - beforeToBackend; got != 4 {
+		t.Fatalf("expected 4 bytes client to backend, got %v", got)
+	}
+	if got := testutil.ToFloat64(toClient) - beforeToClient; got != 6 {
+		t.Fatalf("expected 6 bytes backend to client, got %v", got)
+	}
+}
+
+func histogramSampleCount(t *testing.T, o prometheus.Observer) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := o.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatalf("failed to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestCreateBrowserRecordsBadRequestOutcome(t *testing.T) {
+	svc := NewService(&fakeBrowserClient{}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), 5*time.Second)
+
+	observer := metrics.CreateBrowserDuration.WithLabelValues(metrics.OutcomeBadRequest)
+	before := histogramSampleCount(t, observer)
+
+	req := httptest.NewRequest(http.MethodPost, "/browsers", strings.NewReader(`{"browserName":""}`))
+	svc.CreateBrowser(httptest.NewRecorder(), req)
+
+	if got := histogramSampleCount(t, observer) - before; got != 1 {
+		t.Fatalf("expected 1 bad_request observation, got %d", got)
+	}
+}
+
+func TestWaitForSessionWaitsForPendingSession(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{BrowserId: "browser-1", Phase: corev1.PodPending})
+
+	go func() {
+		time.Sleep(600 * time.Millisecond)
+		st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1", Phase: corev1.PodRunning})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sess, err := waitForSession(ctx, "browser-1", st)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if sess.SessionId != "sess-1" {
+		t.Fatalf("expected sessionId sess-1, got %q", sess.SessionId)
+	}
+}
+
+func TestWaitForSessionFailedBrowser(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{BrowserId: "browser-1", BrowserName: "chrome", Phase: corev1.PodFailed, Reason: "ErrImagePull", Message: "not found"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := waitForSession(ctx, "browser-1", st)
+	var failed *browserFailedError
+	if !errors.As(err, &failed) {
+		t.Fatalf("expected browserFailedError, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "browser chrome failed") || !strings.Contains(err.Error(), "ErrImagePull") || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected browser name, reason and message in error, got %q", err.Error())
+	}
+}
+
+func TestDeleteBrowserPendingDeletesBrowser(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("b1", &types.Session{BrowserId: "b1", StartedManually: true, Phase: corev1.PodPending})
+
+	cl := &fakeBrowserClient{}
+	svc := NewService(cl, "default", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second)
+
+	req := requestWithParam(http.MethodDelete, "/browsers/b1", "browserId", "b1")
+	rw := httptest.NewRecorder()
+
+	svc.DeleteBrowser(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rw.Code)
+	}
+	if cl.lastDeleted != "b1" {
+		t.Fatalf("expected Browser b1 to be deleted, got %q", cl.lastDeleted)
+	}
+}
+
+func TestDeleteBrowserPendingClientError(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("b1", &types.Session{BrowserId: "b1", StartedManually: true, Phase: corev1.PodPending})
+
+	svc := NewService(&fakeBrowserClient{deleteErr: errors.New("delete error")}, "default", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second)
+
+	req := requestWithParam(http.MethodDelete, "/browsers/b1", "browserId", "b1")
+	rw := httptest.NewRecorder()
+
+	svc.DeleteBrowser(rw, req)
+
+	if rw.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", rw.Code)
+	}
+}
+
+func TestRouteVNCPendingSession(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("b1", &types.Session{BrowserId: "b1", Phase: corev1.PodPending})
+
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second)
+	req := requestWithParam(http.MethodGet, "/vnc/b1", "browserId", "b1")
+	rw := httptest.NewRecorder()
+
+	svc.RouteVNC(rw, req)
+
+	if rw.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", rw.Code)
+	}
+}
+
+type recordingRecorder struct {
+	actions []history.Action
+}
+
+func (r *recordingRecorder) RecordSession(context.Context, history.SessionEvent) {}
+
+func (r *recordingRecorder) RecordAction(_ context.Context, action history.Action) {
+	r.actions = append(r.actions, action)
+}
+
+func TestDeleteBrowserRecordsAction(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("b1", &types.Session{BrowserId: "b1", BrowserName: "chrome", BrowserVersion: "123", StartedManually: true, Phase: corev1.PodPending})
+
+	rec := &recordingRecorder{}
+	svc := NewService(&fakeBrowserClient{}, "default", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithRecorder(rec))
+
+	req := requestWithParam(http.MethodDelete, "/browsers/b1", "browserId", "b1")
+	req = req.WithContext(auth.WithOwner(req.Context(), auth.Owner{Name: "alice"}))
+	rw := httptest.NewRecorder()
+
+	svc.DeleteBrowser(rw, req)
+
+	if len(rec.actions) != 1 {
+		t.Fatalf("expected 1 recorded action, got %d", len(rec.actions))
+	}
+	action := rec.actions[0]
+	if action.Type != history.ActionDelete || action.User != "alice" || action.BrowserId != "b1" || action.BrowserName != "chrome" {
+		t.Fatalf("unexpected action: %+v", action)
+	}
+	if action.Outcome != metrics.OutcomeSuccess {
+		t.Fatalf("expected outcome %q, got %q", metrics.OutcomeSuccess, action.Outcome)
+	}
+	if action.Time.IsZero() {
+		t.Fatal("expected action time to be set")
+	}
+}
+
+func TestDeleteBrowserNotManualRecordsBadRequest(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("b1", &types.Session{BrowserId: "b1", StartedManually: false})
+
+	rec := &recordingRecorder{}
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithRecorder(rec))
+
+	req := requestWithParam(http.MethodDelete, "/browsers/b1", "browserId", "b1")
+	rw := httptest.NewRecorder()
+
+	svc.DeleteBrowser(rw, req)
+
+	if len(rec.actions) != 1 || rec.actions[0].Outcome != metrics.OutcomeBadRequest {
+		t.Fatalf("expected a bad_request delete action, got %+v", rec.actions)
+	}
+}
+
+func TestCreateBrowserRecordsAction(t *testing.T) {
+	rec := &recordingRecorder{}
+	svc := NewService(&fakeBrowserClient{createErr: errors.New("create error")}, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithRecorder(rec))
+
+	req := httptest.NewRequest(http.MethodPost, "/browsers", strings.NewReader(`{"browserName":"chrome","browserVersion":"123"}`))
+	req = req.WithContext(auth.WithOwner(req.Context(), auth.Owner{Name: "alice"}))
+	rw := httptest.NewRecorder()
+
+	svc.CreateBrowser(rw, req)
+
+	if len(rec.actions) != 1 {
+		t.Fatalf("expected 1 recorded action, got %d", len(rec.actions))
+	}
+	action := rec.actions[0]
+	if action.Type != history.ActionCreate || action.User != "alice" || action.BrowserName != "chrome" || action.BrowserVersion != "123" {
+		t.Fatalf("unexpected action: %+v", action)
+	}
+	if action.Outcome != metrics.OutcomeError {
+		t.Fatalf("expected outcome %q, got %q", metrics.OutcomeError, action.Outcome)
+	}
+}
+
+type recordingAuditor struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (a *recordingAuditor) Audit(_ context.Context, ev audit.Event) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, ev)
+}
+
+func TestRouteVNCAuditsConnectAndDisconnect(t *testing.T) {
+	clientConn := newFakeWSConn()
+	backendConn := newFakeWSConn()
+
+	prevUpgrade := wsUpgrade
+	prevDial := wsDial
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
+		return clientConn, nil
+	}
+	wsDial = func(target string, _ bool) (wsConn, error) {
+		return backendConn, nil
+	}
+	defer func() {
+		wsUpgrade = prevUpgrade
+		wsDial = prevDial
+	}()
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1", BrowserName: "chrome"})
+
+	auditor := &recordingAuditor{}
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithAuditor(auditor))
+
+	req := requestWithParam(http.MethodGet, "/api/v1/browsers/browser-1/vnc", "browserId", "browser-1")
+	req = req.WithContext(auth.WithOwner(req.Context(), auth.Owner{Name: "alice"}))
+	req.RemoteAddr = "10.0.0.7:51000"
+	rw := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		svc.RouteVNC(rw, req)
+		close(done)
+	}()
+
+	close(clientConn.readCh)
+	close(backendConn.readCh)
+
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("timeout waiting for handler to finish")
+	}
+
+	auditor.mu.Lock()
+	defer auditor.mu.Unlock()
+	if len(auditor.events) != 2 {
+		t.Fatalf("expected connect and disconnect events, got %+v", auditor.events)
+	}
+
+	connect, disconnect := auditor.events[0], auditor.events[1]
+	if connect.Type != audit.VNCConnect || connect.Outcome != metrics.OutcomeSuccess {
+		t.Fatalf("unexpected connect event: %+v", connect)
+	}
+	if connect.Owner != "alice" || connect.SourceIP != "10.0.0.7" || connect.BrowserId != "browser-1" || connect.BrowserName != "chrome" {
+		t.Fatalf("expected owner, source IP and browser on connect, got %+v", connect)
+	}
+	if disconnect.Type != audit.VNCDisconnect || disconnect.Owner != "alice" {
+		t.Fatalf("unexpected disconnect event: %+v", disconnect)
+	}
+	if _, ok := disconnect.Details["durationSeconds"]; !ok {
+		t.Fatalf("expected disconnect duration, got %+v", disconnect.Details)
+	}
+}
+
+func TestRouteVNCAuditsRejectedConnect(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("b1", &types.Session{BrowserId: "b1", Phase: corev1.PodPending})
+
+	auditor := &recordingAuditor{}
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithAuditor(auditor))
+
+	req := requestWithParam(http.MethodGet, "/browsers/b1/vnc", "browserId", "b1")
+	rw := httptest.NewRecorder()
+
+	svc.RouteVNC(rw, req)
+
+	if len(auditor.events) != 1 || auditor.events[0].Type != audit.VNCConnect || auditor.events[0].Outcome != metrics.OutcomeBadRequest {
+		t.Fatalf("expected a rejected vnc.connect event, got %+v", auditor.events)
+	}
+}
+
+func TestDeleteBrowserAuditsUnknownBrowser(t *testing.T) {
+	auditor := &recordingAuditor{}
+	svc := NewService(nil, "", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithAuditor(auditor))
+
+	req := requestWithParam(http.MethodDelete, "/browsers/missing", "browserId", "missing")
+	rw := httptest.NewRecorder()
+
+	svc.DeleteBrowser(rw, req)
+
+	if len(auditor.events) != 1 || auditor.events[0].Type != audit.BrowserDelete || auditor.events[0].BrowserId != "missing" {
+		t.Fatalf("expected a browser.delete event for missing, got %+v", auditor.events)
+	}
+}
+
+func TestCreateBrowserRejectsNamespaceNotAllowed(t *testing.T) {
+	cl := &fakeBrowserClient{}
+	svc := NewService(cl, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithNamespaces("team-a"))
+	body := `{"namespace":"team-b","browserName":"chrome","browserVersion":"123"}`
+	rw := httptest.NewRecorder()
+
+	svc.CreateBrowser(rw, httptest.NewRequest(http.MethodPost, "/browsers", strings.NewReader(body)))
+
+	if rw.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rw.Code)
+	}
+	if cl.lastCreated != nil {
+		t.Fatalf("expected no browser to be created")
+	}
+}
+
+func TestCreateBrowserUsesRequestedNamespace(t *testing.T) {
+	cl := &fakeBrowserClient{}
+	svc := NewService(cl, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), time.Millisecond, WithNamespaces("team-a"))
+	body := `{"namespace":"team-a","browserName":"chrome","browserVersion":"123"}`
+
+	svc.CreateBrowser(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/browsers", strings.NewReader(body)))
+
+	if cl.namespace != "team-a" {
+		t.Fatalf("expected browser to be created in team-a, got %q", cl.namespace)
+	}
+}
+
+func TestGetBrowserSameNameInTwoNamespaces(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("team-a/b1", &types.Session{Namespace: "team-a", BrowserId: "b1", SessionId: "s1"})
+	st.Set("team-b/b1", &types.Session{Namespace: "team-b", BrowserId: "b1", SessionId: "s2"})
+	svc := NewService(nil, "default", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second)
+
+	rw := httptest.NewRecorder()
+	svc.GetBrowser(rw, requestWithParam(http.MethodGet, "/browsers/b1", "browserId", "b1"))
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for an ambiguous browserId, got %d", rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	svc.GetBrowser(rw, requestWithParam(http.MethodGet, "/browsers/b1?namespace=team-b", "browserId", "b1"))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rw.Code)
+	}
+	var got types.Session
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got.Namespace != "team-b" || got.SessionId != "s2" {
+		t.Fatalf("expected team-b session s2, got %q %q", got.Namespace, got.SessionId)
+	}
+}
+
+func TestDeleteBrowserUsesSessionNamespace(t *testing.T) {
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("team-a/b1", &types.Session{Namespace: "team-a", BrowserId: "b1", StartedManually: true, Phase: corev1.PodPending})
+	cl := &fakeBrowserClient{}
+	svc := NewService(cl, "default", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second)
+
+	rw := httptest.NewRecorder()
+	svc.DeleteBrowser(rw, requestWithParam(http.MethodDelete, "/browsers/b1", "browserId", "b1"))
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rw.Code)
+	}
+	if cl.lastDeleted != "b1" || cl.namespace != "team-a" {
+		t.Fatalf("expected b1 to be deleted in team-a, got %q in %q", cl.lastDeleted, cl.namespace)
+	}
+}
+
+func TestCreateBrowserRoutesToCluster(t *testing.T) {
+	eu, us := &fakeBrowserClient{}, &fakeBrowserClient{}
+	registry, err := cluster.NewRegistry(&cluster.Backend{Name: "eu", Browsers: eu}, &cluster.Backend{Name: "us", Browsers: us})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	svc := NewService(nil, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), time.Millisecond, WithClusters(registry))
+
+	body := `{"cluster":"us","browserName":"chrome","browserVersion":"123"}`
+	svc.CreateBrowser(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/browsers", strings.NewReader(body)))
+
+	if us.lastCreated == nil || eu.lastCreated != nil {
+		t.Fatalf("expected browser to be created in cluster us only")
+	}
+}
+
+func TestCreateBrowserUnknownCluster(t *testing.T) {
+	registry, err := cluster.NewRegistry(&cluster.Backend{Name: "eu", Browsers: &fakeBrowserClient{}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	svc := NewService(nil, "default", store.NewDefaultStore[*types.Session](), store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithClusters(registry))
+
+	body := `{"cluster":"asia","browserName":"chrome","browserVersion":"123"}`
+	rw := httptest.NewRecorder()
+	svc.CreateBrowser(rw, httptest.NewRequest(http.MethodPost, "/browsers", strings.NewReader(body)))
+
+	if rw.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rw.Code)
+	}
+}
+
+func TestDeleteBrowserRoutesToSessionCluster(t *testing.T) {
+	eu, us := &fakeBrowserClient{}, &fakeBrowserClient{}
+	registry, err := cluster.NewRegistry(&cluster.Backend{Name: "eu", Browsers: eu}, &cluster.Backend{Name: "us", Browsers: us})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("us/default/b1", &types.Session{Cluster: "us", Namespace: "default", BrowserId: "b1", StartedManually: true, Phase: corev1.PodPending})
+	svc := NewService(nil, "default", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithClusters(registry))
+
+	rw := httptest.NewRecorder()
+	svc.DeleteBrowser(rw, requestWithParam(http.MethodDelete, "/browsers/b1?cluster=us", "browserId", "b1"))
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rw.Code)
+	}
+	if us.lastDeleted != "b1" || eu.lastDeleted != "" {
+		t.Fatalf("expected b1 to be deleted in cluster us only, got eu=%q us=%q", eu.lastDeleted, us.lastDeleted)
+	}
+}
+
+func sharedVNCRequest(t *testing.T, registry *share.Registry, viewOnly bool) (*http.Request, share.Link) {
+	t.Helper()
+	token, link := registry.Create("alice", &types.Session{BrowserId: "browser-1"}, time.Minute, viewOnly, 0)
+	viewer, err := registry.Join("browser-1", token)
+	if err != nil {
+		t.Fatalf("expected to join share link, got %v", err)
+	}
+	req := requestWithParam(http.MethodGet, "/api/v1/share/browser-1/vnc", "browserId", "browser-1")
+	return req.WithContext(share.WithViewer(req.Context(), viewer)), link
+}
+
+func TestRouteVNCShareIgnoresNamespaceParameter(t *testing.T) {
+	prevUpgrade := wsUpgrade
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
+		t.Fatalf("expected no upgrade for a link to another namespace")
+		return nil, nil
+	}
+	defer func() { wsUpgrade = prevUpgrade }()
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("default/browser-1", &types.Session{SessionId: "sess-1", Namespace: "default", BrowserId: "browser-1", BrowserIP: "127.0.0.1"})
+	svc := NewService(nil, "default", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second)
+
+	registry := share.NewRegistry()
+	token, _ := registry.Create("alice", &types.Session{Namespace: "qa", BrowserId: "browser-1"}, time.Minute, true, 0)
+	viewer, err := registry.Join("browser-1", token)
+	if err != nil {
+		t.Fatalf("expected to join share link, got %v", err)
+	}
+	req := requestWithParam(http.MethodGet, "/api/v1/share/browser-1/vnc?namespace=default", "browserId", "browser-1")
+	req = req.WithContext(share.WithViewer(req.Context(), viewer))
+
+	rw := httptest.NewRecorder()
+	svc.RouteVNC(rw, req)
+	if rw.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rw.Code)
+	}
+}
+
+func TestRouteVNCViewOnlyShareDropsInput(t *testing.T) {
+	clientConn := newFakeWSConn()
+	backendConn := newFakeWSConn()
+
+	prevUpgrade := wsUpgrade
+	prevDial := wsDial
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
+		return clientConn, nil
+	}
+	wsDial = func(target string, _ bool) (wsConn, error) {
+		return backendConn, nil
+	}
+	defer func() {
+		wsUpgrade = prevUpgrade
+		wsDial = prevDial
+	}()
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1"})
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second)
+
+	req, _ := sharedVNCRequest(t, share.NewRegistry(), true)
+
+	done := make(chan struct{})
+	go func() {
+		svc.RouteVNC(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	keyEvent := []byte{4, 1, 0, 0, 0, 0, 0xff, 0x0d}
+	updateRequest := []byte{3, 1, 0, 0, 0, 0, 4, 0, 3, 0}
+	for _, data := range [][]byte{[]byte("RFB 003.008\n"), {1, 1}, keyEvent, updateRequest} {
+		clientConn.readCh <- fakeWSMessage{mt: websocket.BinaryMessage, data: data}
+	}
+
+	var forwarded [][]byte
+	for len(forwarded) < 3 {
+		select {
+		case msg := <-backendConn.writeCh:
+			forwarded = append(forwarded, msg.data)
+		case <-time.After(500 * time.Millisecond):
+			t.Fatalf("timeout waiting for backend write, got %v", forwarded)
+		}
+	}
+	if string(forwarded[2]) != string(updateRequest) {
+		t.Fatalf("expected the key event to be dropped, got %v", forwarded)
+	}
+
+	close(clientConn.readCh)
+	close(backendConn.readCh)
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("timeout waiting for handler to finish")
+	}
+}
+
+func TestRouteVNCClosesRevokedShare(t *testing.T) {
+	clientConn := newFakeWSConn()
+	backendConn := newFakeWSConn()
+
+	prevUpgrade := wsUpgrade
+	prevDial := wsDial
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
+		return clientConn, nil
+	}
+	wsDial = func(target string, _ bool) (wsConn, error) {
+		return backendConn, nil
+	}
+	defer func() {
+		wsUpgrade = prevUpgrade
+		wsDial = prevDial
+		close(clientConn.readCh)
+		close(backendConn.readCh)
+	}()
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1"})
+	auditor := &recordingAuditor{}
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithAuditor(auditor))
+
+	registry := share.NewRegistry()
+	req, link := sharedVNCRequest(t, registry, false)
+
+	done := make(chan struct{})
+	go func() {
+		svc.RouteVNC(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	clientConn.readCh <- fakeWSMessage{mt: websocket.BinaryMessage, data: []byte("anything")}
+	select {
+	case msg := <-backendConn.writeCh:
+		if string(msg.data) != "anything" {
+			t.Fatalf("expected input to pass a full-control link, got %q", msg.data)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("timeout waiting for backend write")
+	}
+
+	if _, err := registry.Revoke(link.Key(), link.ID); err != nil {
+		t.Fatalf("expected revoke to succeed, got %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("expected revoking the link to close the connection")
+	}
+
+	auditor.mu.Lock()
+	defer auditor.mu.Unlock()
+	if len(auditor.events) != 2 || auditor.events[0].Details["shareId"] != link.ID || auditor.events[1].Details["shareId"] != link.ID {
+		t.Fatalf("expected audit events to carry the share id, got %+v", auditor.events)
+	}
+}
+
+func TestRouteVNCAccountsAndForceCloses(t *testing.T) {
+	clientConn := newFakeWSConn()
+	backendConn := newFakeWSConn()
+
+	prevUpgrade := wsUpgrade
+	prevDial := wsDial
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
+		return clientConn, nil
+	}
+	wsDial = func(target string, _ bool) (wsConn, error) {
+		return backendConn, nil
+	}
+	defer func() {
+		wsUpgrade = prevUpgrade
+		wsDial = prevDial
+		close(clientConn.readCh)
+		close(backendConn.readCh)
+	}()
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1", BrowserName: "chrome"})
+	auditor := &recordingAuditor{}
+	conns := vncconn.NewRegistry()
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithAuditor(auditor), WithVNCConnections(conns))
+
+	req := requestWithParam(http.MethodGet, "/api/v1/browsers/browser-1/vnc", "browserId", "browser-1")
+	req = req.WithContext(auth.WithOwner(req.Context(), auth.Owner{Name: "alice"}))
+
+	done := make(chan struct{})
+	go func() {
+		svc.RouteVNC(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	clientConn.readCh <- fakeWSMessage{mt: websocket.BinaryMessage, data: []byte("input")}
+	<-backendConn.writeCh
+
+	var open []vncconn.Connection
+	for deadline := time.Now().Add(500 * time.Millisecond); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if open = conns.List(); len(open) == 1 && open[0].ClientToServer.Messages == 1 {
+			break
+		}
+	}
+	if len(open) != 1 || open[0].Owner != "alice" || open[0].BrowserId != "browser-1" || open[0].ClientToServer != (vncconn.Traffic{Bytes: 5, Messages: 1}) {
+		t.Fatalf("expected the open connection with its traffic, got %+v", open)
+	}
+
+	if _, err := conns.Close(open[0].ID); err != nil {
+		t.Fatalf("expected force-close to succeed, got %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("expected force-close to end the connection")
+	}
+	if clientConn.closeCode() != websocket.ClosePolicyViolation {
+		t.Fatalf("expected close code %d, got %d", websocket.ClosePolicyViolation, clientConn.closeCode())
+	}
+	if n := len(conns.List()); n != 0 {
+		t.Fatalf("expected the connection to leave the registry, got %d", n)
+	}
+
+	auditor.mu.Lock()
+	defer auditor.mu.Unlock()
+	disconnect := auditor.events[len(auditor.events)-1]
+	if disconnect.Type != audit.VNCDisconnect || disconnect.Details["closeReason"] != vncconn.CloseForced || disconnect.Details["connectionId"] != open[0].ID {
+		t.Fatalf("expected the disconnect to record the forced close, got %+v", disconnect)
+	}
+	if traffic := disconnect.Details["clientToServer"]; traffic != (vncconn.Traffic{Bytes: 5, Messages: 1}) {
+		t.Fatalf("expected the disconnect to record the traffic, got %+v", traffic)
+	}
+}
+
+func TestRouteVNCDrainsOnShutdown(t *testing.T) {
+	clientConn := newFakeWSConn()
+	backendConn := newFakeWSConn()
+
+	prevUpgrade := wsUpgrade
+	prevDial := wsDial
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
+		return clientConn, nil
+	}
+	wsDial = func(target string, _ bool) (wsConn, error) {
+		return backendConn, nil
+	}
+	defer func() {
+		wsUpgrade = prevUpgrade
+		wsDial = prevDial
+		close(clientConn.readCh)
+		close(backendConn.readCh)
+	}()
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1"})
+	conns := vncconn.NewRegistry()
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithVNCConnections(conns))
+
+	done := make(chan struct{})
+	go func() {
+		svc.RouteVNC(httptest.NewRecorder(), requestWithParam(http.MethodGet, "/api/v1/browsers/browser-1/vnc", "browserId", "browser-1"))
+		close(done)
+	}()
+
+	clientConn.readCh <- fakeWSMessage{mt: websocket.BinaryMessage, data: []byte("input")}
+	<-backendConn.writeCh
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := conns.Drain(ctx); err != nil {
+		t.Fatalf("expected the connection to drain, got %v", err)
+	}
+	<-done
+	if clientConn.closeCode() != websocket.CloseServiceRestart || backendConn.closeCode() != websocket.CloseServiceRestart {
+		t.Fatalf("expected close code %d on both sides, got %d and %d", websocket.CloseServiceRestart, clientConn.closeCode(), backendConn.closeCode())
+	}
+}
+
+func TestRouteVNCAnswersVNCAuthentication(t *testing.T) {
+	clientConn := newFakeWSConn()
+	backendConn := newFakeWSConn()
+
+	prevUpgrade := wsUpgrade
+	prevDial := wsDial
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
+		return clientConn, nil
+	}
+	wsDial = func(target string, _ bool) (wsConn, error) {
+		return backendConn, nil
+	}
+	defer func() {
+		wsUpgrade = prevUpgrade
+		wsDial = prevDial
+	}()
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1", BrowserName: "chrome"})
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second,
+		WithVNCPasswords(func(sess *types.Session) (string, bool) { return "s3cr3t", sess.BrowserName == "chrome" }))
+
+	rw := httptest.NewRecorder()
+	svc.GetBrowser(rw, requestWithParam(http.MethodGet, "/api/v1/browsers/browser-1", "browserId", "browser-1"))
+	if !strings.Contains(rw.Body.String(), `"vncPasswordManaged":true`) {
+		t.Fatalf("expected session to be marked as password managed, got %s", rw.Body.String())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		svc.RouteVNC(httptest.NewRecorder(), requestWithParam(http.MethodGet, "/api/v1/browsers/browser-1/vnc", "browserId", "browser-1"))
+		close(done)
+	}()
+
+	challenge := []byte("0123456789abcdef")
+	for _, data := range [][]byte{[]byte("RFB 003.008\n"), {1, 2}, challenge, {0, 0, 0, 0}} {
+		backendConn.readCh <- fakeWSMessage{mt: websocket.BinaryMessage, data: data}
+	}
+	for _, data := range [][]byte{[]byte("RFB 003.008\n"), {1}, {1}} {
+		clientConn.readCh <- fakeWSMessage{mt: websocket.BinaryMessage, data: data}
+	}
+
+	read := func(conn *fakeWSConn, n int) [][]byte {
+		var got [][]byte
+		for len(got) < n {
+			select {
+			case msg := <-conn.writeCh:
+				got = append(got, msg.data)
+			case <-time.After(500 * time.Millisecond):
+				t.Fatalf("timeout waiting for write, got %v", got)
+			}
+		}
+		return got
+	}
+
+	toBackend := read(backendConn, 4)
+	if string(toBackend[2]) != string(rfb.EncryptChallenge("s3cr3t", challenge)) {
+		t.Fatalf("expected the challenge to be answered with the password, got %v", toBackend)
+	}
+	if string(toBackend[3]) != "\x01" {
+		t.Fatalf("expected ClientInit to be forwarded, got %v", toBackend)
+	}
+	toClient := read(clientConn, 3)
+	if string(toClient[1]) != "\x01\x01" || string(toClient[2]) != "\x00\x00\x00\x00" {
+		t.Fatalf("expected the client to be offered no security, got %v", toClient)
+	}
+
+	close(clientConn.readCh)
+	close(backendConn.readCh)
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("timeout waiting for handler to finish")
+	}
+}
+
+func TestRouteVNCRejectsClientWhenBackendRefusesPassword(t *testing.T) {
+	clientConn := newFakeWSConn()
+	backendConn := newFakeWSConn()
+
+	prevUpgrade := wsUpgrade
+	prevDial := wsDial
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
+		return clientConn, nil
+	}
+	wsDial = func(target string, _ bool) (wsConn, error) {
+		return backendConn, nil
+	}
+	defer func() {
+		wsUpgrade = prevUpgrade
+		wsDial = prevDial
+	}()
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1"})
+	auditor := &recordingAuditor{}
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithAuditor(auditor),
+		WithVNCPasswords(func(*types.Session) (string, bool) { return "wrong", true }))
+
+	for _, data := range [][]byte{[]byte("RFB 003.008\n"), {1, 2}, []byte("0123456789abcdef"), {0, 0, 0, 1}} {
+		backendConn.readCh <- fakeWSMessage{mt: websocket.BinaryMessage, data: data}
+	}
+	close(backendConn.readCh)
+	clientConn.readCh <- fakeWSMessage{mt: websocket.BinaryMessage, data: []byte("RFB 003.008\n")}
+
+	svc.RouteVNC(httptest.NewRecorder(), requestWithParam(http.MethodGet, "/api/v1/browsers/browser-1/vnc", "browserId", "browser-1"))
+
+	<-clientConn.writeCh
+	msg := <-clientConn.writeCh
+	if msg.data[0] != 0 || !strings.Contains(string(msg.data), "could not authenticate") {
+		t.Fatalf("expected the client to be refused with a reason, got %q", msg.data)
+	}
+
+	auditor.mu.Lock()
+	defer auditor.mu.Unlock()
+	if len(auditor.events) != 1 || auditor.events[0].Outcome != metrics.OutcomeError {
+		t.Fatalf("expected one failed connect event, got %+v", auditor.events)
+	}
+}
+
+func TestRouteVNCManagedPasswordRequiresOwnerOrAdmin(t *testing.T) {
+	prevUpgrade := wsUpgrade
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
+		t.Fatalf("expected no upgrade for another user's session")
+		return nil, nil
+	}
+	defer func() { wsUpgrade = prevUpgrade }()
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1", Owner: "alice"})
+	auditor := &recordingAuditor{}
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second, WithAuditor(auditor),
+		WithAdminCheck(func(*http.Request) bool { return false }),
+		WithVNCPasswords(func(*types.Session) (string, bool) { return "s3cr3t", true }))
+
+	req := requestWithParam(http.MethodGet, "/api/v1/browsers/browser-1/vnc", "browserId", "browser-1")
+	req = req.WithContext(auth.WithOwner(req.Context(), auth.Owner{Name: "bob"}))
+	rw := httptest.NewRecorder()
+	svc.RouteVNC(rw, req)
+
+	if rw.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d", rw.Code)
+	}
+	auditor.mu.Lock()
+	defer auditor.mu.Unlock()
+	if len(auditor.events) != 1 || auditor.events[0].Outcome != audit.OutcomeDenied {
+		t.Fatalf("expected one denied connect event, got %+v", auditor.events)
+	}
+}
+
+// hangingWSConn never delivers a message; Close makes reads fail, as on a
+// real websocket.
+type hangingWSConn struct {
+	*fakeWSConn
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newHangingWSConn() *hangingWSConn {
+	return &hangingWSConn{fakeWSConn: newFakeWSConn(), closed: make(chan struct{})}
+}
+
+func (c *hangingWSConn) ReadMessage() (int, []byte, error) {
+	<-c.closed
+	return 0, nil, io.ErrClosedPipe
+}
+
+func (c *hangingWSConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func TestRouteVNCTracksAndDrainsPendingHandshake(t *testing.T) {
+	clientConn := newHangingWSConn()
+	backendConn := newHangingWSConn()
+
+	prevUpgrade := wsUpgrade
+	prevDial := wsDial
+	wsUpgrade = func(rw http.ResponseWriter, req *http.Request, _ bool) (wsConn, error) {
+		return clientConn, nil
+	}
+	wsDial = func(target string, _ bool) (wsConn, error) {
+		return backendConn, nil
+	}
+	defer func() {
+		wsUpgrade = prevUpgrade
+		wsDial = prevDial
+	}()
+
+	st := store.NewDefaultStore[*types.Session]()
+	st.Set("browser-1", &types.Session{SessionId: "sess-1", BrowserId: "browser-1", BrowserIP: "127.0.0.1", BrowserName: "chrome"})
+	conns := vncconn.NewRegistry()
+	svc := NewService(nil, "", st, store.NewDefaultStore[types.BrowserVersions](), 5*time.Second,
+		WithVNCConnections(conns),
+		WithVNCPasswords(func(*types.Session) (string, bool) { return "s3cr3t", true }))
+
+	done := make(chan struct{})
+	go func() {
+		svc.RouteVNC(httptest.NewRecorder(), requestWithParam(http.MethodGet, "/api/v1/browsers/browser-1/vnc", "browserId", "browser-1"))
+		close(done)
+	}()
+
+	deadline := time.After(time.Second)
+	for conns.Len() == 0 {
+		select {
+		case <-deadline:
+			t.Fatalf("expected the connection to be tracked during the handshake")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := conns.Drain(ctx); err != nil {
+		t.Fatalf("expected the pending handshake to be drained, got %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected RouteVNC to return after the drain")
+	}
+}
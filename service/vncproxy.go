@@ -0,0 +1,307 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/metrics"
+	"github.com/alcounit/browser-ui/pkg/rfb"
+	"github.com/alcounit/browser-ui/pkg/vncconn"
+	"github.com/gorilla/websocket"
+)
+
+// VNCProxyConfig bounds how RouteVNC proxies a connection, so that a stuck
+// or dead side ends it instead of stalling the other one.
+type VNCProxyConfig struct {
+	// WriteTimeout is how long one message may take to reach a side before
+	// that side is considered stuck.
+	WriteTimeout time.Duration
+	// PingInterval is how often both sides are pinged. A side that sends
+	// nothing, not even a pong, for PongTimeout is considered dead.
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+	// QueueSize is how many messages are buffered in each direction before
+	// reading from the sending side pauses.
+	QueueSize int
+	// MaxMessageSize caps a single message read from either side.
+	MaxMessageSize int64
+	// Compression negotiates permessage-deflate with both sides.
+	Compression bool
+}
+
+var DefaultVNCProxy = VNCProxyConfig{
+	WriteTimeout:   10 * time.Second,
+	PingInterval:   30 * time.Second,
+	PongTimeout:    75 * time.Second,
+	QueueSize:      64,
+	MaxMessageSize: 32 << 20,
+}
+
+// closeError is why a proxied connection ended. Code and text go to both
+// sides in a close frame; err is nil for an orderly close. side is the side
+// that ended it, if either did.
+type closeError struct {
+	code   int
+	text   string
+	reason string
+	side   string
+	err    error
+}
+
+func (e *closeError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return e.text
+}
+
+// Reasons are the reason label of metrics.VNCDisconnects.
+const (
+	reasonNormal   = "normal"
+	reasonTimeout  = "timeout"
+	reasonSlow     = "slow"
+	reasonTooBig   = "too_big"
+	reasonRevoked  = "revoked"
+	reasonForced   = "closed_by_admin"
+	reasonShutdown = "shutdown"
+	reasonProtocol = "protocol"
+	reasonError    = "error"
+)
+
+// closeReason is the vncconn close reason of the connection.
+func (e *closeError) closeReason() string {
+	switch {
+	case e.reason == reasonNormal:
+		return vncconn.CloseNormal
+	case e.reason == reasonRevoked:
+		return vncconn.CloseRevoked
+	case e.reason == reasonForced:
+		return vncconn.CloseForced
+	case e.reason == reasonShutdown:
+		return vncconn.CloseShutdown
+	case e.side == "viewer":
+		return vncconn.CloseClientError
+	default:
+		return vncconn.CloseBackendError
+	}
+}
+
+// forcedClose ends a connection closed through the vncconn registry.
+func forcedClose(reason string) *closeError {
+	if reason == vncconn.CloseShutdown {
+		return &closeError{code: websocket.CloseServiceRestart, text: "server restarting", reason: reasonShutdown}
+	}
+	return &closeError{code: websocket.ClosePolicyViolation, text: "closed by an administrator", reason: reasonForced}
+}
+
+func readError(side string, err error) *closeError {
+	switch {
+	case isNormalWSDisconnect(err):
+		return &closeError{code: websocket.CloseNormalClosure, text: side + " disconnected", reason: reasonNormal, side: side}
+	case errors.Is(err, websocket.ErrReadLimit):
+		return &closeError{code: websocket.CloseMessageTooBig, text: "message from " + side + " too big", reason: reasonTooBig, side: side, err: fmt.Errorf("read from %s: %w", side, err)}
+	case isTimeout(err):
+		return &closeError{code: websocket.CloseGoingAway, text: side + " stopped responding", reason: reasonTimeout, side: side, err: fmt.Errorf("read from %s: %w", side, err)}
+	default:
+		return &closeError{code: websocket.CloseInternalServerErr, text: side + " connection failed", reason: reasonError, side: side, err: fmt.Errorf("read from %s: %w", side, err)}
+	}
+}
+
+func writeError(side string, err error) *closeError {
+	if isTimeout(err) {
+		return &closeError{code: websocket.CloseTryAgainLater, text: side + " is not keeping up", reason: reasonSlow, side: side, err: fmt.Errorf("write to %s: %w", side, err)}
+	}
+	return &closeError{code: websocket.CloseInternalServerErr, text: side + " connection failed", reason: reasonError, side: side, err: fmt.Errorf("write to %s: %w", side, err)}
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+type wsMessage struct {
+	mt   int
+	data []byte
+	// end is set on the last message of a direction, after which its
+	// reader stopped.
+	end *closeError
+}
+
+// vncProxy copies messages both ways through bounded queues, so that a reader
+// only waits for its writer when the queue is full and a writer gives up
+// after WriteTimeout. The first side to fail or close ends the connection.
+type vncProxy struct {
+	cfg             VNCProxyConfig
+	client, backend wsConn
+	viewOnly        *rfb.ViewOnly
+	// clientToServer and serverToClient count what was written each way.
+	clientToServer, serverToClient *vncconn.Counter
+
+	once   sync.Once
+	done   chan struct{}
+	result *closeError
+	wg     sync.WaitGroup
+}
+
+func newVNCProxy(cfg VNCProxyConfig, client, backend wsConn, viewOnly *rfb.ViewOnly) *vncProxy {
+	return &vncProxy{
+		cfg:            cfg,
+		client:         client,
+		backend:        backend,
+		viewOnly:       viewOnly,
+		clientToServer: &vncconn.Counter{},
+		serverToClient: &vncconn.Counter{},
+		done:           make(chan struct{}),
+	}
+}
+
+// track counts the traffic of the connection into tracker. It must be called
+// before start.
+func (p *vncProxy) track(tracker *vncconn.Tracker) {
+	p.clientToServer = &tracker.ClientToServer
+	p.serverToClient = &tracker.ServerToClient
+}
+
+func (p *vncProxy) start() {
+	var filter func([]byte) ([]byte, error)
+	if p.viewOnly != nil {
+		filter = p.viewOnly.Filter
+	}
+	p.pipe(p.client, p.backend, "viewer", "browser", metrics.DirectionClientToBackend, p.clientToServer, filter)
+	p.pipe(p.backend, p.client, "browser", "viewer", metrics.DirectionBackendToClient, p.serverToClient, nil)
+	if p.cfg.PingInterval > 0 {
+		p.wg.Add(1)
+		go p.ping()
+	}
+}
+
+// stop ends the connection with result unless it has already ended.
+func (p *vncProxy) stop(result *closeError) {
+	p.once.Do(func() {
+		p.result = result
+		close(p.done)
+	})
+}
+
+// close sends the close frame to both sides, closes them and waits for the
+// writers. It returns why the connection ended.
+func (p *vncProxy) close() *closeError {
+	<-p.done
+	msg := websocket.FormatCloseMessage(p.result.code, p.result.text)
+	deadline := time.Now().Add(time.Second)
+	for _, conn := range []wsConn{p.client, p.backend} {
+		conn.WriteControl(websocket.CloseMessage, msg, deadline) //nolint:errcheck // the side may be gone
+		conn.Close()
+	}
+	p.wg.Wait()
+	metrics.VNCDisconnects.WithLabelValues(p.result.reason).Inc()
+	return p.result
+}
+
+func (p *vncProxy) pipe(src, dst wsConn, srcName, dstName, direction string, counter *vncconn.Counter, filter func([]byte) ([]byte, error)) {
+	src.SetReadLimit(p.cfg.MaxMessageSize)
+	p.extendRead(src)
+	src.SetPongHandler(func(string) error {
+		p.extendRead(src)
+		return nil
+	})
+
+	queue := make(chan wsMessage, p.cfg.QueueSize)
+
+	go func() {
+		for {
+			mt, data, err := src.ReadMessage()
+			if err != nil {
+				p.enqueue(queue, wsMessage{end: readError(srcName, err)})
+				return
+			}
+			p.extendRead(src)
+
+			if filter != nil {
+				if data, err = filter(data); err != nil {
+					p.enqueue(queue, wsMessage{end: &closeError{
+						code:   websocket.CloseUnsupportedData,
+						text:   "unsupported VNC message",
+						reason: reasonProtocol,
+						side:   srcName,
+						err:    err,
+					}})
+					return
+				}
+				if len(data) == 0 {
+					continue
+				}
+			}
+			if !p.enqueue(queue, wsMessage{mt: mt, data: data}) {
+				return
+			}
+		}
+	}()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for {
+			select {
+			case msg := <-queue:
+				if msg.end != nil {
+					p.stop(msg.end)
+					return
+				}
+				dst.SetWriteDeadline(time.Now().Add(p.cfg.WriteTimeout)) //nolint:errcheck
+				if err := dst.WriteMessage(msg.mt, msg.data); err != nil {
+					p.stop(writeError(dstName, err))
+					return
+				}
+				counter.Add(len(msg.data))
+				metrics.VNCBytesProxied.WithLabelValues(direction).Add(float64(len(msg.data)))
+				metrics.VNCMessagesProxied.WithLabelValues(direction).Inc()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+}
+
+// enqueue waits for room in queue, and reports false once the connection
+// has ended.
+func (p *vncProxy) enqueue(queue chan<- wsMessage, msg wsMessage) bool {
+	select {
+	case queue <- msg:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+// extendRead gives conn another PongTimeout to send something.
+func (p *vncProxy) extendRead(conn wsConn) {
+	if p.cfg.PongTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(p.cfg.PongTimeout)) //nolint:errcheck
+	}
+}
+
+func (p *vncProxy) ping() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.cfg.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			deadline := time.Now().Add(p.cfg.WriteTimeout)
+			if err := p.client.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				p.stop(writeError("viewer", err))
+				return
+			}
+			if err := p.backend.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				p.stop(writeError("browser", err))
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alcounit/browser-ui/pkg/vncconn"
+	"github.com/gorilla/websocket"
+)
+
+func TestVNCProxyClosesWhenViewerIsStuck(t *testing.T) {
+	client := newFakeWSConn()
+	client.writeErr = os.ErrDeadlineExceeded
+	backend := newFakeWSConn()
+	defer close(client.readCh)
+	defer close(backend.readCh)
+
+	p := newVNCProxy(DefaultVNCProxy, client, backend, nil)
+	p.start()
+	backend.readCh <- fakeWSMessage{mt: websocket.BinaryMessage, data: []byte("update")}
+
+	result := p.close()
+	if result.code != websocket.CloseTryAgainLater || result.reason != reasonSlow || result.closeReason() != vncconn.CloseClientError || result.err == nil {
+		t.Fatalf("expected the stuck viewer to end the connection, got %+v", result)
+	}
+	if backend.closeCode() != websocket.CloseTryAgainLater || client.closeCode() != websocket.CloseTryAgainLater {
+		t.Fatalf("expected close frames to both sides, got %d and %d", client.closeCode(), backend.closeCode())
+	}
+}
+
+func TestVNCProxyClosesWhenBackendStopsResponding(t *testing.T) {
+	client := newFakeWSConn()
+	backend := newFakeWSConn()
+	defer close(client.readCh)
+
+	p := newVNCProxy(DefaultVNCProxy, client, backend, nil)
+	p.start()
+	backend.readCh <- fakeWSMessage{err: os.ErrDeadlineExceeded}
+
+	result := p.close()
+	if result.code != websocket.CloseGoingAway || result.text != "browser stopped responding" || result.closeReason() != vncconn.CloseBackendError {
+		t.Fatalf("expected the dead backend to be reported, got %+v", result)
+	}
+	if client.closeCode() != websocket.CloseGoingAway {
+		t.Fatalf("expected the viewer to get close code %d, got %d", websocket.CloseGoingAway, client.closeCode())
+	}
+}
+
+func TestVNCProxyPausesReadingWhenQueueIsFull(t *testing.T) {
+	client := newFakeWSConn()
+	backend := newFakeWSConn()
+	backend.writeCh = make(chan fakeWSMessage)
+
+	cfg := DefaultVNCProxy
+	cfg.QueueSize = 1
+	p := newVNCProxy(cfg, client, backend, nil)
+	p.start()
+
+	// One message is being written, one is queued and one is held by the
+	// reader; the fourth must stay unread.
+	for i := 0; i < 4; i++ {
+		client.readCh <- fakeWSMessage{mt: websocket.BinaryMessage, data: []byte{byte(i)}}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(client.readCh); n != 1 {
+		t.Fatalf("expected reading to pause with one message left, got %d", n)
+	}
+
+	for i := 0; i < 4; i++ {
+		select {
+		case msg := <-backend.writeCh:
+			if msg.data[0] != byte(i) {
+				t.Fatalf("expected message %d, got %d", i, msg.data[0])
+			}
+		case <-time.After(500 * time.Millisecond):
+			t.Fatalf("timeout waiting for message %d", i)
+		}
+	}
+
+	close(client.readCh)
+	close(backend.readCh)
+	if result := p.close(); result.reason != reasonNormal || result.err != nil {
+		t.Fatalf("expected a normal close, got %+v", result)
+	}
+}
@@ -1,5 +1,5 @@
 import React from 'react';
-import { Routes, Route, Navigate, useNavigate } from 'react-router-dom';
+import { Routes, Route, Navigate, useNavigate, useSearchParams } from 'react-router-dom';
 import { Dashboard } from './pages/Dashboard';
 import { VNCView } from './pages/VNCView';
 import { StartBrowser } from './pages/StartBrowser';
@@ -7,9 +7,12 @@ import { LoginPage } from './pages/LoginPage';
 
 interface AuthConfig {
   authEnabled: boolean;
+  // 'proxy' when a reverse proxy in front of the UI logs users in.
+  loginMode?: 'form' | 'proxy';
+  logoutUrl?: string;
 }
 
-const AuthContext = React.createContext<{ authEnabled: boolean; onUnauthorized: () => void }>({
+const AuthContext = React.createContext<AuthConfig & { onUnauthorized: () => void }>({
   authEnabled: false,
   onUnauthorized: () => {},
 });
@@ -43,7 +46,14 @@ function ProtectedRoute({ children }: { children: React.ReactNode }) {
   return <>{children}</>;
 }
 
-function AppRoutes({ authEnabled }: { authEnabled: boolean }) {
+// Share links open a single session without logging in.
+function SessionRoute() {
+  const [searchParams] = useSearchParams();
+  if (searchParams.get('share')) return <VNCView />;
+  return <ProtectedRoute><VNCView /></ProtectedRoute>;
+}
+
+function AppRoutes({ config }: { config: AuthConfig }) {
   const navigate = useNavigate();
 
   const onUnauthorized = React.useCallback(() => {
@@ -51,12 +61,12 @@ function AppRoutes({ authEnabled }: { authEnabled: boolean }) {
   }, [navigate]);
 
   return (
-    <AuthContext.Provider value={{ authEnabled, onUnauthorized }}>
+    <AuthContext.Provider value={{ ...config, onUnauthorized }}>
       <Routes>
         <Route path="/" element={<Navigate to="/ui/" replace />} />
         <Route path="/ui/login" element={<LoginPage />} />
         <Route path="/ui/" element={<ProtectedRoute><Dashboard /></ProtectedRoute>} />
-        <Route path="/session/:id" element={<ProtectedRoute><VNCView /></ProtectedRoute>} />
+        <Route path="/session/:id" element={<SessionRoute />} />
         <Route path="/ui/start" element={<ProtectedRoute><StartBrowser /></ProtectedRoute>} />
         <Route path="*" element={<Navigate to="/ui/" replace />} />
       </Routes>
@@ -76,7 +86,7 @@ function App() {
 
   if (config === null) return null;
 
-  return <AppRoutes authEnabled={config.authEnabled} />;
+  return <AppRoutes config={config} />;
 }
 
 export default App;
@@ -1,18 +1,20 @@
 import React from "react";
 import { useQuery, useMutation } from '@tanstack/react-query';
 import { Link, useNavigate } from 'react-router-dom';
-import { formatUptime, getBrowserIcon } from '../utils';
+import { csrfHeaders, formatUptime, getBrowserIcon } from '../utils';
 import { useAuth } from '../App';
 
 const buildNumber = __BUILD_NUMBER__;
 
 interface Session {
-  sessionId: string;
+  sessionId: string | null;
   browserId: string;
   browserName: string;
   browserVersion: string;
   startTime: string;
   phase: 'Running' | 'Pending' | 'Failed' | 'Succeeded';
+  reason?: string;
+  message?: string;
   startedManually: boolean;
 }
 
@@ -36,7 +38,7 @@ const fetchStatus = async (onUnauthorized: () => void): Promise<StatusResponse>
 const startBrowser = async (payload: { browserName: string; browserVersion: string }): Promise<{ browserId: string }> => {
   const res = await fetch('/api/v1/browsers', {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: { 'Content-Type': 'application/json', ...csrfHeaders() },
     body: JSON.stringify(payload),
   });
   if (!res.ok) throw new Error('Failed to start browser');
@@ -126,7 +128,7 @@ const VersionList: React.FC<VersionListProps> = ({ versions, browserName, starti
 
 export const Dashboard: React.FC = () => {
   const navigate = useNavigate();
-  const { authEnabled, onUnauthorized } = useAuth();
+  const { authEnabled, loginMode, logoutUrl, onUnauthorized } = useAuth();
 
   const { data, isLoading } = useQuery({
     queryKey: ['status'],
@@ -136,7 +138,7 @@ export const Dashboard: React.FC = () => {
 
   const logoutMutation = useMutation({
     mutationFn: async () => {
-      await fetch('/api/v1/auth/logout', { method: 'POST' });
+      await fetch('/api/v1/auth/logout', { method: 'POST', headers: csrfHeaders() });
     },
     onSuccess: () => navigate('/ui/login', { replace: true }),
   });
@@ -183,7 +185,7 @@ export const Dashboard: React.FC = () => {
 
   const deleteMutation = useMutation({
     mutationFn: async (browserId: string) => {
-      const res = await fetch(`/api/v1/browsers/${browserId}`, { method: 'DELETE' });
+      const res = await fetch(`/api/v1/browsers/${browserId}`, { method: 'DELETE', headers: csrfHeaders() });
       if (!res.ok) throw new Error('Failed to delete browser');
     },
     onMutate: (browserId) => {
@@ -279,10 +281,10 @@ export const Dashboard: React.FC = () => {
           )}
         </div>
 
-        {authEnabled && (
+        {authEnabled && (loginMode !== 'proxy' || logoutUrl) && (
           <button
             className="logout-btn"
-            onClick={() => logoutMutation.mutate()}
+            onClick={() => (loginMode === 'proxy' && logoutUrl ? window.location.assign(logoutUrl) : logoutMutation.mutate())}
             disabled={logoutMutation.isPending}
           >
             {logoutMutation.isPending ? 'Signing out…' : 'Sign out'}
@@ -338,14 +340,20 @@ export const Dashboard: React.FC = () => {
                 <div className="browser-details">
                   <div className="detail-row">
                     <span className="detail-label">Session ID</span>
-                    <span className="detail-value browser-id" title={browser.sessionId}>
-                      {browser.sessionId}
+                    <span className="detail-value browser-id" title={browser.sessionId ?? undefined}>
+                      {browser.sessionId ?? '—'}
                     </span>
                   </div>
                   <div className="detail-row">
                     <span className="detail-label">Status</span>
                     <span className="detail-value">{browser.phase}</span>
                   </div>
+                  {browser.phase !== 'Running' && (browser.reason || browser.message) && (
+                    <div className="detail-row">
+                      <span className="detail-label">Reason</span>
+                      <span className="detail-value" title={browser.message}>{browser.reason || browser.message}</span>
+                    </div>
+                  )}
                 </div>
 
                 <div className="browser-meta">
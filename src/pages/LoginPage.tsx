@@ -1,8 +1,11 @@
 import React from 'react';
 import { useNavigate } from 'react-router-dom';
+import { csrfHeaders } from '../utils';
+import { useAuth } from '../App';
 
 export const LoginPage: React.FC = () => {
   const navigate = useNavigate();
+  const { loginMode } = useAuth();
   const [username, setUsername] = React.useState('');
   const [password, setPassword] = React.useState('');
   const [error, setError] = React.useState('');
@@ -15,13 +18,17 @@ export const LoginPage: React.FC = () => {
     try {
       const res = await fetch('/api/v1/auth/login', {
         method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
+        headers: { 'Content-Type': 'application/json', ...csrfHeaders() },
         body: JSON.stringify({ username, password }),
       });
       if (res.status === 401) {
         setError('Invalid username or password');
         return;
       }
+      if (res.status === 429) {
+        setError('Too many failed attempts, please try again later');
+        return;
+      }
       if (!res.ok) {
         setError('Login failed, please try again');
         return;
@@ -34,6 +41,22 @@ export const LoginPage: React.FC = () => {
     }
   };
 
+  if (loginMode === 'proxy') {
+    return (
+      <div className="login-page">
+        <div className="login-card">
+          <div className="login-title">SELENOSIS-UI</div>
+          <div className="login-form">
+            <div className="login-error">Sign-in is handled by the proxy in front of this UI, and it did not identify you.</div>
+            <button className="login-btn" type="button" onClick={() => window.location.assign('/ui/')}>
+              Sign in again
+            </button>
+          </div>
+        </div>
+      </div>
+    );
+  }
+
   return (
     <div className="login-page">
       <div className="login-card">
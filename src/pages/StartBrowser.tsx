@@ -1,32 +1,52 @@
 import React from "react";
 import { useQuery, useMutation } from "@tanstack/react-query";
 import { Link, useNavigate } from "react-router-dom";
-import { getBrowserIcon } from "../utils";
+import { csrfHeaders, getBrowserIcon } from "../utils";
 
 interface Session {
   browserId: string;
 }
 
-interface StatusResponse {
-  activeSessions: unknown[];
-  supportedBrowsers: Record<string, string[]>[];
+interface CatalogVersion {
+  version: string;
+  image: string;
+  resources?: { requests?: Record<string, string>; limits?: Record<string, string> };
+  vnc?: boolean;
+  video?: boolean;
+  default?: boolean;
+  latest?: boolean;
 }
 
-interface BrowserEntry {
+interface CatalogBrowser {
   name: string;
-  versions: string[];
+  defaultVersion: string;
+  latestVersion: string;
+  versions: CatalogVersion[];
 }
 
-const fetchStatus = async (): Promise<StatusResponse> => {
-  const res = await fetch("/api/v1/status");
-  if (!res.ok) throw new Error("Failed to fetch status");
+const fetchCatalog = async (): Promise<{ browsers: CatalogBrowser[] }> => {
+  const res = await fetch("/api/v1/browsers/catalog");
+  if (!res.ok) throw new Error("Failed to fetch browser catalog");
   return res.json();
 };
 
+const versionLabel = (v: CatalogVersion) => {
+  const markers = [v.latest && "latest", v.default && "default"].filter(Boolean);
+  return markers.length ? `${v.version} (${markers.join(", ")})` : v.version;
+};
+
+const resourcesLabel = (v: CatalogVersion) => {
+  const requests = v.resources?.requests ?? {};
+  return [requests.cpu && `${requests.cpu} CPU`, requests.memory && `${requests.memory} memory`].filter(Boolean).join(", ");
+};
+
+const featureLabel = (name: string, enabled?: boolean) =>
+  enabled === undefined ? null : `${name} ${enabled ? "on" : "off"}`;
+
 const startBrowser = async (payload: { browserName: string; browserVersion: string }): Promise<Session> => {
   const res = await fetch("/api/v1/browsers", {
     method: "POST",
-    headers: { "Content-Type": "application/json" },
+    headers: { "Content-Type": "application/json", ...csrfHeaders() },
     body: JSON.stringify(payload),
   });
   if (!res.ok) throw new Error("Failed to start browser");
@@ -36,29 +56,17 @@ const startBrowser = async (payload: { browserName: string; browserVersion: stri
 export const StartBrowser: React.FC = () => {
   const navigate = useNavigate();
   const { data, isLoading } = useQuery({
-    queryKey: ["status"],
-    queryFn: fetchStatus,
+    queryKey: ["catalog"],
+    queryFn: fetchCatalog,
   });
 
-  const browsers: BrowserEntry[] = React.useMemo(() => {
-    const map = new Map<string, Set<string>>();
-    (data?.supportedBrowsers ?? []).forEach((cfg) => {
-      Object.entries(cfg).forEach(([name, versions]) => {
-        if (!map.has(name)) map.set(name, new Set());
-        versions.forEach((v) => map.get(name)!.add(v));
-      });
-    });
-    return [...map.entries()].map(([name, vSet]) => ({
-      name,
-      versions: [...vSet].sort((a, b) => b.localeCompare(a, undefined, { numeric: true })),
-    }));
-  }, [data]);
+  const browsers = data?.browsers ?? [];
 
   const [selected, setSelected] = React.useState<Record<string, string>>({});
   const [startingBrowser, setStartingBrowser] = React.useState<string | null>(null);
 
   const getVersion = (name: string) =>
-    selected[name] ?? browsers.find((b) => b.name === name)?.versions[0] ?? "";
+    selected[name] ?? browsers.find((b) => b.name === name)?.defaultVersion ?? "";
 
   const mutation = useMutation({
     mutationFn: startBrowser,
@@ -98,41 +106,51 @@ export const StartBrowser: React.FC = () => {
         )}
 
         <div className="start-browser-grid">
-          {browsers.map((browser) => (
-            <div key={browser.name} className="browser-card">
-              <div className="browser-header-row">
-                <div className="browser-icon">{getBrowserIcon(browser.name)}</div>
-                <div>
-                  <div className="browser-name">{browser.name}</div>
-                  <div className="browser-version">{browser.versions.length} version{browser.versions.length !== 1 ? "s" : ""}</div>
+          {browsers.map((browser) => {
+            const current = browser.versions.find((v) => v.version === getVersion(browser.name));
+            const details = current
+              ? [current.image, resourcesLabel(current), featureLabel("VNC", current.vnc), featureLabel("video", current.video)].filter(Boolean)
+              : [];
+            return (
+              <div key={browser.name} className="browser-card">
+                <div className="browser-header-row">
+                  <div className="browser-icon">{getBrowserIcon(browser.name)}</div>
+                  <div>
+                    <div className="browser-name">{browser.name}</div>
+                    <div className="browser-version">{browser.versions.length} version{browser.versions.length !== 1 ? "s" : ""}</div>
+                  </div>
                 </div>
-              </div>
 
-              <div className="start-browser-version-row">
-                <label className="start-browser-label">Version</label>
-                <select
-                  className="start-browser-select"
-                  value={getVersion(browser.name)}
-                  onChange={(e) => setSelected((prev) => ({ ...prev, [browser.name]: e.target.value }))}
-                >
-                  {browser.versions.map((v) => (
-                    <option key={v} value={v}>{v}</option>
-                  ))}
-                </select>
-              </div>
+                <div className="start-browser-version-row">
+                  <label className="start-browser-label">Version</label>
+                  <select
+                    className="start-browser-select"
+                    value={getVersion(browser.name)}
+                    onChange={(e) => setSelected((prev) => ({ ...prev, [browser.name]: e.target.value }))}
+                  >
+                    {browser.versions.map((v) => (
+                      <option key={v.version} value={v.version}>{versionLabel(v)}</option>
+                    ))}
+                  </select>
+                </div>
 
-              <div className="browser-meta">
-                <span />
-                <button
-                  className="vnc-button"
-                  disabled={startingBrowser !== null}
-                  onClick={() => handleStart(browser.name)}
-                >
-                  {startingBrowser === browser.name ? "STARTING…" : "START"}
-                </button>
+                {details.length > 0 && (
+                  <div className="browser-version">{details.join(" · ")}</div>
+                )}
+
+                <div className="browser-meta">
+                  <span />
+                  <button
+                    className="vnc-button"
+                    disabled={startingBrowser !== null}
+                    onClick={() => handleStart(browser.name)}
+                  >
+                    {startingBrowser === browser.name ? "STARTING…" : "START"}
+                  </button>
+                </div>
               </div>
-            </div>
-          ))}
+            );
+          })}
 
           {browsers.length === 0 && (
             <div style={{ color: "#666", marginTop: 8 }}>No browsers configured.</div>
@@ -49,21 +49,21 @@ vi.mock("../assets/icons/clipboard-copy.svg", () => ({ default: "copy.svg" }));
 vi.mock("../assets/icons/send.svg", () => ({ default: "send.svg" }));
 vi.mock("../assets/icons/circle-x.svg", () => ({ default: "x.svg" }));
 
-function mockFetch(opts: { browserName?: string | undefined; browserVersion?: string; startTime?: string; password?: string | null; sessionOk?: boolean }) {
+function mockFetch(opts: { browserName?: string | undefined; browserVersion?: string; startTime?: string; password?: string | null; sessionOk?: boolean; managed?: boolean }) {
   if (opts.password && opts.browserName) {
     localStorage.setItem(NAME_PREFIX + opts.browserName, opts.password);
   }
   global.fetch = vi.fn(async () => {
     return {
       ok: opts.sessionOk !== false,
-      json: async () => ({ browserId: "id-1", browserName: opts.browserName, browserVersion: opts.browserVersion ?? "123", startTime: opts.startTime ?? "2020-01-01T00:00:00Z" }),
+      json: async () => ({ browserId: "id-1", browserName: opts.browserName, browserVersion: opts.browserVersion ?? "123", startTime: opts.startTime ?? "2020-01-01T00:00:00Z", vncPasswordManaged: opts.managed }),
     } as any;
   }) as any;
 }
 
-function renderView(id = "id-1") {
+function renderView(id = "id-1", search = "") {
   return render(
-    <MemoryRouter initialEntries={[`/ui/sessions/${id}`]}>
+    <MemoryRouter initialEntries={[`/ui/sessions/${id}${search}`]}>
       <Routes>
         <Route path="/ui/sessions/:id" element={<VNCView />} />
       </Routes>
@@ -112,6 +112,61 @@ describe("auto-connect with a saved password", () => {
   });
 });
 
+describe("share links", () => {
+  it("connects through the share route in view-only mode", async () => {
+    mockFetch({ browserName: "chrome", password: "secret" });
+    renderView("id-1", "?share=abc.def&viewOnly=1");
+
+    await waitFor(() => expect(rfb.instances.length).toBe(1));
+    expect(latest().url).toContain("/api/v1/share/id-1/vnc?token=abc.def");
+    expect(latest().viewOnly).toBe(true);
+  });
+
+  it("keeps input enabled for full-control links", async () => {
+    mockFetch({ browserName: "chrome", password: "secret" });
+    renderView("id-1", "?share=abc.def");
+
+    await waitFor(() => expect(rfb.instances.length).toBe(1));
+    expect(latest().viewOnly).toBe(false);
+  });
+});
+
+describe("server-managed passwords", () => {
+  it("connects without a password or prompt when the server manages it", async () => {
+    mockFetch({ browserName: "chrome", password: "secret", managed: true });
+    renderView();
+
+    await waitFor(() => expect(rfb.instances.length).toBe(1));
+    await emit("connect");
+    expect(screen.getByText("Connected")).toBeInTheDocument();
+    expect(latest().sendCredentials).not.toHaveBeenCalled();
+    expect(screen.queryByRole("dialog")).not.toBeInTheDocument();
+  });
+
+  it("shows the server's failure instead of prompting", async () => {
+    mockFetch({ browserName: "chrome", managed: true });
+    renderView();
+
+    await waitFor(() => expect(rfb.instances.length).toBe(1));
+    await emit("securityfailure", { reason: "could not authenticate" });
+    expect(rfb.instances.length).toBe(1);
+    expect(screen.queryByRole("dialog")).not.toBeInTheDocument();
+    expect(screen.getByText("VNC auth failed: could not authenticate")).toBeInTheDocument();
+  });
+
+  it("lets share viewers without a saved password try the server first, then prompts", async () => {
+    mockFetch({ browserName: "chrome", sessionOk: false });
+    renderView("id-1", "?share=abc.def");
+
+    await waitFor(() => expect(rfb.instances.length).toBe(1));
+    expect(screen.queryByRole("dialog")).not.toBeInTheDocument();
+
+    await emit("credentialsrequired");
+    expect(latest().sendCredentials).not.toHaveBeenCalled();
+    expect(await screen.findByRole("dialog")).toBeInTheDocument();
+  });
+});
+
 describe("saved-password retry ladder", () => {
   it("tries the version-saved password first, then falls through to the name-saved one on securityfailure", async () => {
     localStorage.setItem(VERSION_PREFIX + "chrome@123", "byversion");
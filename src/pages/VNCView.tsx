@@ -1,5 +1,5 @@
 import React, { useEffect, useRef, useState } from "react";
-import { useParams, useNavigate, useLocation } from "react-router-dom";
+import { useParams, useNavigate, useLocation, useSearchParams } from "react-router-dom";
 import RFB from "@novnc/novnc/lib/rfb";
 import { formatUptime } from "../utils";
 import {
@@ -28,6 +28,9 @@ export const VNCView: React.FC = () => {
   const { id } = useParams<{ id: string }>();
   const navigate = useNavigate();
   const location = useLocation();
+  const [searchParams] = useSearchParams();
+  const shareToken = searchParams.get("share");
+  const shareViewOnly = shareToken !== null && searchParams.get("viewOnly") === "1";
 
   const containerRef = useRef<HTMLDivElement | null>(null);
   const rfbRef = useRef<AnyRFB | null>(null);
@@ -52,6 +55,7 @@ export const VNCView: React.FC = () => {
   const pendingPwRef = useRef<string>("");
   const manualRef = useRef(false);
   const securityFailedRef = useRef(false);
+  const passwordManagedRef = useRef(false);
   const isMaximizedRef = useRef(false);
   const createRfbRef = useRef<(password: string, manual: boolean) => void>(() => {});
 
@@ -81,7 +85,9 @@ export const VNCView: React.FC = () => {
 
     const protocol = window.location.protocol === "https:" ? "wss" : "ws";
     const host = window.location.host;
-    const url = `${protocol}://${host}/api/v1/browsers/${id}/vnc`;
+    const url = shareToken
+      ? `${protocol}://${host}/api/v1/share/${id}/vnc?token=${encodeURIComponent(shareToken)}`
+      : `${protocol}://${host}/api/v1/browsers/${id}/vnc`;
 
     const rfb: AnyRFB = new (RFB as any)(containerRef.current, url, {
       scaleViewport: true,
@@ -90,6 +96,13 @@ export const VNCView: React.FC = () => {
 
     rfb.addEventListener("credentialsrequired", () => {
       const pw = pendingPwRef.current;
+      if (!pw && !manualRef.current) {
+        // Connected without a password, but the server asks for one after all.
+        teardownRfb();
+        manualRef.current = true;
+        setShowPrompt(true);
+        return;
+      }
       if (typeof rfb.sendCredentials === "function") {
         rfb.sendCredentials({ password: pw });
       } else {
@@ -97,7 +110,7 @@ export const VNCView: React.FC = () => {
       }
     });
 
-    rfb.viewOnly = false;
+    rfb.viewOnly = shareViewOnly;
     rfb.localCursor = true;
     rfb.clipViewport = false;
     rfb.viewportDrag = false;
@@ -113,6 +126,13 @@ export const VNCView: React.FC = () => {
     rfb.addEventListener("securityfailure", (event: any) => {
       securityFailedRef.current = true;
       const reason = event?.detail?.reason ?? "authentication failed";
+      if (passwordManagedRef.current) {
+        // The server answers VNC authentication itself; a password typed
+        // here would not help.
+        setStatus("Disconnected");
+        setNotice(`VNC auth failed: ${reason}`);
+        return;
+      }
       attemptRef.current = registerFailure(attemptRef.current, pendingPwRef.current);
       if (isHardCapReached(attemptRef.current)) {
         setStatus("Disconnected");
@@ -169,10 +189,11 @@ export const VNCView: React.FC = () => {
       try {
         const resp = await fetch(`/api/v1/browsers/${id}`);
         if (resp.ok) {
-          const session: { browserId: string; browserName?: string; browserVersion?: string; startTime?: string } = await resp.json();
+          const session: { browserId: string; browserName?: string; browserVersion?: string; startTime?: string; vncPasswordManaged?: boolean } = await resp.json();
           if (session.browserId === id) {
             browserNameRef.current = session.browserName ?? "";
             browserVersionRef.current = session.browserVersion ?? "";
+            passwordManagedRef.current = session.vncPasswordManaged === true;
             if (!sessionStartTime && session.startTime) {
               setSessionStartTime(session.startTime);
             }
@@ -182,6 +203,11 @@ export const VNCView: React.FC = () => {
 
       if (cancelled) return;
 
+      if (passwordManagedRef.current) {
+        createRfbRef.current("", false);
+        return;
+      }
+
       ladderRef.current = buildLadder(
         getSavedByVersion(browserNameRef.current, browserVersionRef.current),
         getSavedByName(browserNameRef.current),
@@ -190,6 +216,9 @@ export const VNCView: React.FC = () => {
       const first = nextCandidate(ladderRef.current, attemptRef.current.tried);
       if (first !== null) {
         createRfbRef.current(first, false);
+      } else if (shareToken) {
+        // Share viewers may not know the password; the server may know it.
+        createRfbRef.current("", false);
       } else {
         manualRef.current = true;
         setShowPrompt(true);
@@ -23,4 +23,12 @@ export function getBrowserIcon(name: string): string {
   if (n.includes('opera')) return 'O';
   if (n.includes('safari')) return 'S';
   return name[0].toUpperCase();
-}
\ No newline at end of file
+}
+const CSRF_COOKIE = 'browser_ui_csrf';
+
+// csrfHeaders echoes the CSRF cookie set by the server; every POST and DELETE
+// to /api/v1 must send it.
+export function csrfHeaders(): Record<string, string> {
+  const match = document.cookie.split('; ').find(c => c.startsWith(`${CSRF_COOKIE}=`));
+  return match ? { 'X-CSRF-Token': decodeURIComponent(match.slice(CSRF_COOKIE.length + 1)) } : {};
+}